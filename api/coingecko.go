@@ -3,12 +3,17 @@ package api
 import (
 	"awesomeProject/internal/models"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
 )
 
+// ErrCoinNotFound - провайдер не знает монету с таким символом
+var ErrCoinNotFound = errors.New("coin not found")
+
 type CoinGeckoApi struct {
 	apiKey     string
 	vsCurrency string
@@ -38,27 +43,87 @@ func (api *CoinGeckoApi) Init() error {
 
 }
 
-func (api *CoinGeckoApi) GetPriceCoin(symbol string) (float64, error) {
+func (api *CoinGeckoApi) GetPriceCoin(symbol string) (decimal.Decimal, error) {
 	url := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/markets?vs_currency=%s&symbols=%s&x_cg_demo_api_key=%s", api.vsCurrency, symbol, api.apiKey)
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return 0, fmt.Errorf("error creating request: %w", err)
+		return decimal.Zero, fmt.Errorf("error creating request: %w", err)
 	}
 	req.Header.Add("accept", "application/json")
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("error sending request: %w", err)
+		return decimal.Zero, fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("error getting coins price: status %s", resp.Status)
+		return decimal.Zero, fmt.Errorf("error getting coins price: status %s", resp.Status)
 	}
 	body, _ := io.ReadAll(resp.Body)
 
 	var coins []models.CoinData
 	if err := json.Unmarshal(body, &coins); err != nil {
-		return 0, fmt.Errorf("error unmarshalling coins response: %w", err)
+		return decimal.Zero, fmt.Errorf("error unmarshalling coins response: %w", err)
+	}
+	if len(coins) == 0 {
+		return decimal.Zero, fmt.Errorf("%w: %s", ErrCoinNotFound, symbol)
 	}
 
 	return coins[0].CurrentPrice, nil
 }
+
+// GetCoinMetadata возвращает описание монеты: название, идентификатор CoinGecko,
+// категорию, описание, логотип и число знаков после запятой токена
+func (api *CoinGeckoApi) GetCoinMetadata(symbol string) (*models.CoinMetadata, error) {
+	url := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/markets?vs_currency=%s&symbols=%s&x_cg_demo_api_key=%s", api.vsCurrency, symbol, api.apiKey)
+	var coins []models.CoinData
+	if err := api.getJSON(url, &coins); err != nil {
+		return nil, fmt.Errorf("error getting coin markets: %w", err)
+	}
+	if len(coins) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCoinNotFound, symbol)
+	}
+	coin := coins[0]
+
+	url = fmt.Sprintf("https://api.coingecko.com/api/v3/coins/%s?localization=false&tickers=false&market_data=false&community_data=false&developer_data=false&x_cg_demo_api_key=%s", coin.ID, api.apiKey)
+	var details models.CoinDetails
+	if err := api.getJSON(url, &details); err != nil {
+		return nil, fmt.Errorf("error getting coin details: %w", err)
+	}
+
+	metadata := &models.CoinMetadata{
+		Name:        coin.Name,
+		ProviderID:  coin.ID,
+		Description: details.Description.En,
+		LogoURL:     coin.Image,
+	}
+	if len(details.Categories) > 0 {
+		metadata.Category = details.Categories[0]
+	}
+	for _, platform := range details.DetailPlatforms {
+		if platform.DecimalPlace != nil {
+			metadata.Decimals = *platform.DecimalPlace
+			break
+		}
+	}
+	return metadata, nil
+}
+
+func (api *CoinGeckoApi) getJSON(url string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Add("accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error unmarshalling response: %w", err)
+	}
+	return nil
+}
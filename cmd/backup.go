@@ -0,0 +1,171 @@
+package main
+
+import (
+	"awesomeProject/internal/archive"
+	"awesomeProject/internal/backup"
+	"awesomeProject/internal/config"
+	"awesomeProject/internal/models"
+	"awesomeProject/internal/repository"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+const (
+	backupUsage  = "usage: main backup <file>"
+	restoreUsage = "usage: main restore [-dry-run] <file>"
+)
+
+// backupRepository - хранилище, из которого делается и в которое загружается резервная копия
+type backupRepository interface {
+	ExportCoins(ctx context.Context) ([]*models.TrackedCoin, error)
+	ExportPrices(ctx context.Context, coinID int64, after models.Timestamp, limit int) ([]*models.CryptoPrice, error)
+	AddCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error
+	GetCoin(ctx context.Context, symbol string) (*models.TrackedCoin, error)
+	SetCoinStatus(ctx context.Context, symbol string, status models.CoinStatus, actor *models.Actor) error
+	RemoveCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error
+	AddNewPrices(ctx context.Context, prices []*models.CryptoPrice) ([]error, error)
+}
+
+// runBackup сохраняет монеты, метаданные и историю цен хранилища из конфигурации в файл,
+// включая тики, перенесенные в архив.
+// Файл записывается целиком во временный и переименовывается после успешной выгрузки.
+func runBackup(cfg *config.Config, args []string, log *zap.Logger) error {
+	if len(args) != 1 {
+		return errors.New(backupUsage)
+	}
+	path := args[0]
+
+	repo, closeRepo, err := openRepository(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // После переименования удалять уже нечего
+
+	manifest, err := backup.Write(context.Background(), tmp, repo, storageDriver(cfg))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save backup: %w", err)
+	}
+
+	for _, file := range manifest.Files {
+		log.Info("Backed up", zap.String("file", file.Name), zap.Int64("records", file.Records), zap.String("sha256", file.SHA256))
+	}
+	return nil
+}
+
+// runRestore загружает резервную копию в пустое хранилище из конфигурации.
+// С -dry-run только проверяет копию: формат, строки и контрольные суммы.
+func runRestore(cfg *config.Config, args []string, log *zap.Logger) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "verify the backup without writing to storage")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errors.New(restoreUsage)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	b, err := backup.Open(file, info.Size())
+	if err != nil {
+		return err
+	}
+	log.Info("Opened backup",
+		zap.Int("version", b.Manifest.Version),
+		zap.Time("created_at", b.Manifest.CreatedAt),
+		zap.String("driver", b.Manifest.Driver))
+
+	var summary *backup.Summary
+	if *dryRun {
+		summary, err = b.Verify()
+	} else {
+		repo, closeRepo, openErr := openRepository(cfg, log)
+		if openErr != nil {
+			return openErr
+		}
+		defer closeRepo()
+		summary, err = b.Restore(context.Background(), repo)
+	}
+	if err != nil {
+		return err
+	}
+	message := "Backup restored"
+	if *dryRun {
+		message = "Backup verified, storage not changed"
+	}
+	log.Info(message, zap.Int64("coins", summary.Coins), zap.Int64("prices", summary.Prices))
+	return nil
+}
+
+func storageDriver(cfg *config.Config) string {
+	if cfg.Driver == "" {
+		return "postgres"
+	}
+	return cfg.Driver
+}
+
+// openRepository подключается к хранилищу из конфигурации без фоновых задач сервиса
+func openRepository(cfg *config.Config, log *zap.Logger) (backupRepository, func(), error) {
+	switch storageDriver(cfg) {
+	case "sqlite":
+		storage, err := repository.NewSQLiteStorage(cfg.Path, log)
+		if err != nil {
+			return nil, nil, err
+		}
+		return storage.NewRepository(), func() { storage.Close() }, nil
+	case "mysql":
+		storage, err := repository.NewMySQLStorage(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DbName, log)
+		if err != nil {
+			return nil, nil, err
+		}
+		return storage.NewRepository(), func() { storage.Close() }, nil
+	case "postgres":
+		// Без query_timeout: пакеты выгрузки и загрузки крупнее запросов сервиса
+		pool := repository.PoolConfig{MaxConns: cfg.MaxConns, MinConns: cfg.MinConns, StatementCacheCapacity: cfg.StatementCacheCapacity}
+		storage, err := repository.NewStorage(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DbName, cfg.SslMode, pool, nil, 0, log)
+		if err != nil {
+			return nil, nil, err
+		}
+		repo := storage.NewRepository()
+		if cfg.Archive.MaxAgeDays <= 0 {
+			return repo, func() { storage.Close() }, nil
+		}
+		// Тики старше max_age_days лежат в архиве: копия дочитывает их оттуда
+		store, err := newArchiveStore(cfg.Archive)
+		if err != nil {
+			storage.Close()
+			return nil, nil, fmt.Errorf("failed to open archive: %w", err)
+		}
+		return repo.WithArchive(archive.New(store, cfg.Archive.MaxAgeDays)), func() { storage.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
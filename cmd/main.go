@@ -2,14 +2,22 @@ package main
 
 import (
 	"awesomeProject/api"
+	"awesomeProject/internal/alerts"
+	"awesomeProject/internal/archive"
 	"awesomeProject/internal/config"
+	"awesomeProject/internal/models"
+	"awesomeProject/internal/ratelimit"
 	"awesomeProject/internal/repository"
 	"awesomeProject/internal/router"
 	"awesomeProject/internal/router/handler"
 	"awesomeProject/internal/scheduler"
 	"awesomeProject/internal/service"
 	logger "awesomeProject/pkg"
+	"context"
+	"fmt"
 	"go.uber.org/zap"
+	"math"
+	"os"
 )
 
 func main() {
@@ -25,24 +33,159 @@ func main() {
 	cfg := config.MustLoad()
 	log.Debug("Config initialized")
 
-	storage, err := repository.NewStorage(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DbName, cfg.SslMode, log)
-	if err != nil {
-		log.Fatal("Error initializing storage", zap.Error(err))
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(cfg, os.Args[2:], log); err != nil {
+				log.Fatal("Migration failed", zap.Error(err))
+			}
+			return
+		case "backup":
+			if err := runBackup(cfg, os.Args[2:], log); err != nil {
+				log.Fatal("Backup failed", zap.Error(err))
+			}
+			return
+		case "restore":
+			if err := runRestore(cfg, os.Args[2:], log); err != nil {
+				log.Fatal("Restore failed", zap.Error(err))
+			}
+			return
+		}
 	}
-	defer storage.Close()
-
-	repo := storage.NewRepository()
-	coinService := service.NewCoinService(repo)
-	coinHandler := handler.NewHandler(coinService)
-	rout := router.NewRouter(coinHandler, log)
 
 	geckoApi := api.NewCoinGeckoApi(cfg.ApiKey, cfg.VsCurrency, log)
 	if geckoApi.Init() != nil {
 		log.Fatal("Error initializing coingecko api", zap.Error(err))
 	}
-	pricePoller := scheduler.NewPricePoller(coinService, cfg.PriceUpdates, geckoApi, log)
+
+	var coinService *service.CoinService
+	var authService *service.AuthService
+	var limiter *ratelimit.Limiter
+	var alertEngine *alerts.Engine
+	switch cfg.Driver {
+	case "sqlite":
+		storage, err := repository.NewSQLiteStorage(cfg.Path, log)
+		if err != nil {
+			log.Fatal("Error initializing storage", zap.Error(err))
+		}
+		defer storage.Close()
+
+		repo := storage.NewRepository()
+		coinService = service.NewCoinService(repo, geckoApi, cfg.Timezone)
+		authService = service.NewAuthService(repo)
+		limiter = ratelimit.New(repo)
+		alertEngine = alerts.New(repo, log)
+	case "mysql":
+		storage, err := repository.NewMySQLStorage(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DbName, log)
+		if err != nil {
+			log.Fatal("Error initializing storage", zap.Error(err))
+		}
+		defer storage.Close()
+
+		repo := storage.NewRepository()
+		coinService = service.NewCoinService(repo, geckoApi, cfg.Timezone)
+		authService = service.NewAuthService(repo)
+		limiter = ratelimit.New(repo)
+		alertEngine = alerts.New(repo, log)
+	case "postgres", "":
+		pool := repository.PoolConfig{
+			MaxConns:               cfg.MaxConns,
+			MinConns:               cfg.MinConns,
+			StatementCacheCapacity: cfg.StatementCacheCapacity,
+			QueryTimeout:           cfg.QueryTimeout,
+		}
+		storage, err := repository.NewStorage(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DbName, cfg.SslMode, pool, cfg.Replicas, cfg.ReplicaCheckInterval, log)
+		if err != nil {
+			log.Fatal("Error initializing storage", zap.Error(err))
+		}
+		defer storage.Close()
+
+		repo := storage.NewRepository()
+		authService = service.NewAuthService(repo)
+		limiter = ratelimit.New(repo)
+		alertEngine = alerts.New(repo, log)
+		if cfg.Archive.MaxAgeDays > 0 {
+			store, err := newArchiveStore(cfg.Archive)
+			if err != nil {
+				log.Fatal("Error initializing archive", zap.Error(err))
+			}
+			priceArchive := archive.New(store, cfg.Archive.MaxAgeDays)
+			coinService = service.NewCoinService(repo.WithArchive(priceArchive), geckoApi, cfg.Timezone)
+
+			priceArchiver := scheduler.NewPriceArchiver(repo, priceArchive, cfg.Archive.CheckInterval, log)
+			go priceArchiver.Start()
+		} else {
+			coinService = service.NewCoinService(repo, geckoApi, cfg.Timezone)
+		}
+
+		partitionMaintainer := scheduler.NewPartitionMaintainer(repo, cfg.PremakeMonths, cfg.RetentionMonths, cfg.DropExpired, cfg.Archive.MaxAgeDays > 0, cfg.CheckInterval, log)
+		go partitionMaintainer.Start()
+	default:
+		log.Fatal("Unknown storage driver", zap.String("driver", cfg.Driver))
+	}
+
+	coinHandler := handler.NewHandler(coinService, authService)
+	limits := router.RateLimits{
+		Anonymous: rateLimit(cfg.RateLimit.Anonymous),
+		Scopes: map[models.Scope]ratelimit.Limit{
+			models.ScopeRead:  rateLimit(cfg.RateLimit.Read),
+			models.ScopeWrite: rateLimit(cfg.RateLimit.Write),
+			models.ScopeAdmin: rateLimit(cfg.RateLimit.Admin),
+		},
+	}
+	rout := router.NewRouter(coinHandler, authService, limiter, limits, cfg.AdminToken, log)
+
+	// Суточные квоты общие для всех экземпляров и переживают перезапуск
+	quotas := hasQuotas(cfg.RateLimit)
+	if quotas {
+		quotaSyncer := scheduler.NewQuotaSyncer(limiter, cfg.RateLimit.SyncInterval, log)
+		go quotaSyncer.Start()
+	}
+
+	// Снимок последних цен доступен сразу после запуска, не дожидаясь первого опроса
+	if err := coinService.LoadLatestPrices(context.Background()); err != nil {
+		log.Warn("Failed to load latest prices", zap.Error(err))
+	}
+
+	pricePoller := scheduler.NewPricePoller(coinService, alertEngine, cfg.PriceUpdates, geckoApi, log)
 	go pricePoller.Start(cfg.MaxConcurrent)
+
 	if err := rout.RunRouter(cfg.Address); err != nil {
 		log.Fatal("Error initializing router", zap.Error(err))
 	}
+	if quotas {
+		if err := limiter.Sync(context.Background()); err != nil {
+			log.Warn("Failed to save quota usage", zap.Error(err))
+		}
+	}
+}
+
+// rateLimit переводит ограничение из конфигурации; пустой burst равен частоте запросов
+func rateLimit(limit config.Limit) ratelimit.Limit {
+	burst := limit.Burst
+	if limit.Rate > 0 && burst <= 0 {
+		burst = max(int(math.Ceil(limit.Rate)), 1)
+	}
+	return ratelimit.Limit{Rate: limit.Rate, Burst: burst, DailyQuota: limit.DailyQuota}
+}
+
+func hasQuotas(cfg config.RateLimit) bool {
+	for _, limit := range []config.Limit{cfg.Anonymous, cfg.Read, cfg.Write, cfg.Admin} {
+		if limit.DailyQuota > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newArchiveStore открывает хранилище архива тиков
+func newArchiveStore(cfg config.Archive) (archive.Store, error) {
+	switch cfg.Store {
+	case "file", "":
+		return archive.NewFileStore(cfg.Path)
+	case "s3":
+		return archive.NewS3Store(cfg.S3.Endpoint, cfg.S3.Region, cfg.S3.Bucket, cfg.S3.AccessKey, cfg.S3.SecretKey)
+	default:
+		return nil, fmt.Errorf("unknown archive store %q", cfg.Store)
+	}
 }
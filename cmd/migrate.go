@@ -0,0 +1,83 @@
+package main
+
+import (
+	"awesomeProject/internal/config"
+	"awesomeProject/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const migrateUsage = "usage: main migrate up|down|status|redo"
+
+// runMigrate выполняет подкоманду migrate над хранилищем из конфигурации:
+//
+//	up     - применить все неприменные миграции
+//	down   - откатить последнюю миграцию
+//	status - показать состояние миграций
+//	redo   - откатить и заново применить последнюю миграцию
+func runMigrate(cfg *config.Config, args []string, log *zap.Logger) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+	switch args[0] {
+	case "up", "down", "redo", "status":
+	default:
+		return fmt.Errorf("unknown migrate command %q, %s", args[0], migrateUsage)
+	}
+
+	migrator, err := newMigrator(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		_, err = migrator.Up(ctx)
+	case "down":
+		_, err = migrator.Down(ctx)
+	case "redo":
+		_, err = migrator.Redo(ctx)
+	case "status":
+		err = printMigrationStatus(ctx, migrator)
+	}
+	return err
+}
+
+func newMigrator(cfg *config.Config, log *zap.Logger) (*repository.Migrator, error) {
+	switch cfg.Driver {
+	case "sqlite":
+		return repository.NewSQLiteMigrator(cfg.Path, log)
+	case "mysql":
+		return repository.NewMySQLMigrator(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DbName, log)
+	case "postgres", "":
+		return repository.NewPostgresMigrator(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DbName, cfg.SslMode, log)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+func printMigrationStatus(ctx context.Context, migrator *repository.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if !status.AppliedAt.IsZero() {
+			appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", appliedAt, status.Source.Path)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,319 @@
+// Package alerts проверяет правила оповещения пользователей о ценах после каждого
+// опроса провайдера: пороги цены, изменение за окно и пересечение скользящего среднего.
+package alerts
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// baseLookback - насколько раньше начала окна ищется цена, от которой считается изменение.
+// Если тиков раньше окна нет на этом отрезке, условие изменения не проверяется.
+const baseLookback = time.Hour
+
+// seedPage - размер страницы при загрузке истории цен монеты
+const seedPage = 1000
+
+var hundred = decimal.NewFromInt(100)
+
+// store - хранилище правил, их состояния и истории цен
+type store interface {
+	ListAlertRules(ctx context.Context, owner string) ([]*models.AlertRule, error)
+	UpdateAlertState(ctx context.Context, rule *models.AlertRule, event *models.AlertEvent) (bool, error)
+	ExportPrices(ctx context.Context, coinID int64, after models.Timestamp, limit int) ([]*models.CryptoPrice, error)
+}
+
+type tick struct {
+	ts    models.Timestamp
+	price decimal.Decimal
+}
+
+// series - недавние тики монеты по возрастанию времени, покрывающие span до последнего тика
+type series struct {
+	ticks []tick
+	span  time.Duration
+}
+
+// Engine проверяет правила на новых тиках и переводит их между состояниями.
+// Тики монет с правилами по окну держатся в памяти; при первом тике монеты или
+// при появлении правила с более длинным окном история загружается из хранилища.
+// Новое состояние записывается условно по версии правила, поэтому при нескольких
+// экземплярах сервиса каждое срабатывание попадает в историю один раз.
+type Engine struct {
+	store store
+	log   *zap.Logger
+
+	mu     sync.Mutex
+	series map[int64]*series // по coin_id
+}
+
+func New(store store, log *zap.Logger) *Engine {
+	return &Engine{store: store, log: log.Named("Alerts"), series: make(map[int64]*series)}
+}
+
+// Evaluate проверяет правила монет на тиках опроса prices, которые уже записаны в хранилище.
+// Ошибки отдельных правил не мешают остальным и возвращаются вместе.
+func (e *Engine) Evaluate(ctx context.Context, prices []*models.CryptoPrice) error {
+	if len(prices) == 0 {
+		return nil
+	}
+	rules, err := e.store.ListAlertRules(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	byCoin := make(map[int64][]*models.AlertRule)
+	for _, rule := range rules {
+		byCoin[rule.CoinID] = append(byCoin[rule.CoinID], rule)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Монеты без правил по окну больше не нужно держать в памяти
+	for coinID := range e.series {
+		if windowOf(byCoin[coinID]) == 0 {
+			delete(e.series, coinID)
+		}
+	}
+
+	var errs []error
+	for _, price := range prices {
+		coinRules := byCoin[price.CoinID]
+		if len(coinRules) == 0 {
+			continue
+		}
+		s, err := e.observe(ctx, price, windowOf(coinRules))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("coin %s: %w", price.Symbol, err))
+			continue
+		}
+		for _, rule := range coinRules {
+			if err := e.apply(ctx, rule, s, price); err != nil {
+				errs = append(errs, fmt.Errorf("alert rule %d: %w", rule.ID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// windowOf возвращает самое длинное окно среди правил
+func windowOf(rules []*models.AlertRule) time.Duration {
+	var window time.Duration
+	for _, rule := range rules {
+		if rule.Condition.Windowed() {
+			window = max(window, time.Duration(rule.Window))
+		}
+	}
+	return window
+}
+
+// observe добавляет тик в историю монеты и возвращает ее; для правил без окна история не нужна
+func (e *Engine) observe(ctx context.Context, price *models.CryptoPrice, window time.Duration) (*series, error) {
+	if window == 0 {
+		return nil, nil
+	}
+	span := window + baseLookback
+	s := e.series[price.CoinID]
+	if s == nil || s.span < span {
+		ticks, err := e.load(ctx, price, span)
+		if err != nil {
+			return nil, err
+		}
+		s = &series{ticks: ticks, span: span}
+		e.series[price.CoinID] = s
+	}
+	s.add(tick{ts: price.Timestamp, price: price.Price})
+	s.trim(price.Timestamp)
+	return s, nil
+}
+
+// load загружает тики монеты за span до момента тика price включительно
+func (e *Engine) load(ctx context.Context, price *models.CryptoPrice, span time.Duration) ([]tick, error) {
+	after := price.Timestamp - models.Timestamp(span.Milliseconds()) - 1
+	var ticks []tick
+	for {
+		page, err := e.store.ExportPrices(ctx, price.CoinID, after, seedPage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load price history: %w", err)
+		}
+		for _, p := range page {
+			if p.Timestamp > price.Timestamp {
+				return ticks, nil
+			}
+			ticks = append(ticks, tick{ts: p.Timestamp, price: p.Price})
+		}
+		if len(page) < seedPage {
+			return ticks, nil
+		}
+		after = page[len(page)-1].Timestamp
+	}
+}
+
+// add добавляет тик; тик в тот же момент заменяет записанный, более ранний пропускается
+func (s *series) add(t tick) {
+	if n := len(s.ticks); n > 0 {
+		last := s.ticks[n-1]
+		if t.ts < last.ts {
+			return
+		}
+		if t.ts == last.ts {
+			s.ticks[n-1] = t
+			return
+		}
+	}
+	s.ticks = append(s.ticks, t)
+}
+
+// trim убирает тики старше span, оставляя последний из них: от него считается изменение
+func (s *series) trim(now models.Timestamp) {
+	cutoff := now - models.Timestamp(s.span.Milliseconds())
+	i := 0
+	for i+1 < len(s.ticks) && s.ticks[i+1].ts <= cutoff {
+		i++
+	}
+	s.ticks = s.ticks[i:]
+}
+
+// before возвращает последний тик не позже ts
+func (s *series) before(ts models.Timestamp) (tick, bool) {
+	for i := len(s.ticks) - 1; i >= 0; i-- {
+		if s.ticks[i].ts <= ts {
+			return s.ticks[i], true
+		}
+	}
+	return tick{}, false
+}
+
+// average возвращает среднее тиков в окне (now - window, now]. Среднее не считается,
+// пока история не покрывает окно целиком.
+func (s *series) average(now models.Timestamp, window time.Duration) (decimal.Decimal, bool) {
+	start := now - models.Timestamp(window.Milliseconds())
+	if len(s.ticks) == 0 || s.ticks[0].ts > start {
+		return decimal.Decimal{}, false
+	}
+	sum, n := decimal.Zero, int64(0)
+	for _, t := range s.ticks {
+		if t.ts > start && t.ts <= now {
+			sum = sum.Add(t.price)
+			n++
+		}
+	}
+	if n == 0 {
+		return decimal.Decimal{}, false
+	}
+	return sum.Div(decimal.NewFromInt(n)), true
+}
+
+// check - результат проверки условия на тике
+type check struct {
+	met     bool            // Условие выполнено: взведенное правило срабатывает
+	cleared bool            // Условие перестало выполняться с запасом гистерезиса: сработавшее правило сбрасывается
+	value   decimal.Decimal // Значение условия для истории срабатываний
+}
+
+// evaluate проверяет условие правила на тике price. false - для проверки не хватает истории цен.
+func evaluate(rule *models.AlertRule, s *series, price *models.CryptoPrice) (check, bool) {
+	p, t := price.Price, rule.Threshold
+	margin := rule.Hysteresis.Div(hundred)
+	one := decimal.NewFromInt(1)
+	window := time.Duration(rule.Window)
+
+	switch rule.Condition {
+	case models.AlertPriceAbove:
+		return check{met: p.GreaterThanOrEqual(t), cleared: p.LessThan(t.Mul(one.Sub(margin))), value: p}, true
+	case models.AlertPriceBelow:
+		return check{met: p.LessThanOrEqual(t), cleared: p.GreaterThan(t.Mul(one.Add(margin))), value: p}, true
+	case models.AlertChangeAbove, models.AlertChangeBelow:
+		base, ok := s.before(price.Timestamp - models.Timestamp(window.Milliseconds()))
+		if !ok || base.price.IsZero() {
+			return check{}, false
+		}
+		change := p.Sub(base.price).Div(base.price).Mul(hundred)
+		if rule.Condition == models.AlertChangeAbove {
+			return check{met: change.GreaterThanOrEqual(t), cleared: change.LessThan(t.Sub(rule.Hysteresis)), value: change}, true
+		}
+		return check{met: change.LessThanOrEqual(t), cleared: change.GreaterThan(t.Add(rule.Hysteresis)), value: change}, true
+	case models.AlertCrossAboveMA, models.AlertCrossBelowMA:
+		average, ok := s.average(price.Timestamp, window)
+		if !ok {
+			return check{}, false
+		}
+		prev, ok := s.before(price.Timestamp - 1)
+		if !ok {
+			return check{}, false
+		}
+		if rule.Condition == models.AlertCrossAboveMA {
+			met := prev.price.LessThanOrEqual(average) && p.GreaterThan(average)
+			return check{met: met, cleared: p.LessThan(average.Mul(one.Sub(margin))), value: average}, true
+		}
+		met := prev.price.GreaterThanOrEqual(average) && p.LessThan(average)
+		return check{met: met, cleared: p.GreaterThan(average.Mul(one.Add(margin))), value: average}, true
+	}
+	return check{}, false
+}
+
+// apply переводит правило в новое состояние по тику price и записывает его.
+// Сработавшее правило сбрасывается, только когда условие перестает выполняться с запасом
+// гистерезиса, а снова взводится не раньше, чем через cooldown после срабатывания.
+func (e *Engine) apply(ctx context.Context, rule *models.AlertRule, s *series, price *models.CryptoPrice) error {
+	c, ok := evaluate(rule, s, price)
+	if !ok {
+		return nil
+	}
+	ts := price.Timestamp
+	cooledDown := rule.LastFiredAt == nil ||
+		ts >= *rule.LastFiredAt+models.Timestamp(time.Duration(rule.Cooldown).Milliseconds())
+
+	state := rule.State
+	if state == models.AlertCoolingDown && cooledDown {
+		state = models.AlertArmed
+	}
+	var event *models.AlertEvent
+	switch {
+	case state == models.AlertArmed && c.met:
+		state = models.AlertFired
+		event = &models.AlertEvent{RuleID: rule.ID, Coin: rule.Coin, Condition: rule.Condition, Price: price.Price, Value: c.value, FiredAt: ts}
+	case state == models.AlertFired && c.cleared && cooledDown:
+		state = models.AlertArmed
+	case state == models.AlertFired && c.cleared:
+		state = models.AlertCoolingDown
+	}
+	if state == rule.State {
+		return nil
+	}
+
+	updated := *rule
+	updated.State = state
+	updated.StateChangedAt = ts
+	if event != nil {
+		updated.LastFiredAt = &ts
+	}
+	ok, err := e.store.UpdateAlertState(ctx, &updated, event)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		e.log.Debug("Alert state was changed concurrently", zap.Int64("rule_id", rule.ID))
+		return nil
+	}
+	if event != nil {
+		e.log.Info("Alert fired",
+			zap.Int64("rule_id", rule.ID),
+			zap.String("owner", rule.Owner),
+			zap.String("coin", rule.Coin),
+			zap.String("condition", string(rule.Condition)),
+			zap.Stringer("price", price.Price),
+			zap.Stringer("value", c.value))
+	} else {
+		e.log.Debug("Alert state changed", zap.Int64("rule_id", rule.ID), zap.String("state", string(state)))
+	}
+	*rule = updated
+	return nil
+}
@@ -0,0 +1,231 @@
+package alerts
+
+import (
+	"awesomeProject/internal/models"
+	"awesomeProject/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// baseTs - полночь 2023-11-14 UTC
+const baseTs = models.Timestamp(1_699_920_000_000)
+
+const minute = models.Timestamp(60_000)
+
+var actor = &models.Actor{Name: "alice", RequestID: "req-alice"}
+
+func newStore(t *testing.T) (*repository.MemoryRepository, *models.TrackedCoin) {
+	t.Helper()
+	repo := repository.NewMemoryRepository()
+	if err := repo.AddCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, actor); err != nil {
+		t.Fatalf("AddCoin: %v", err)
+	}
+	coin, err := repo.GetCoin(t.Context(), "BTC")
+	if err != nil {
+		t.Fatalf("GetCoin: %v", err)
+	}
+	return repo, coin
+}
+
+func createRule(t *testing.T, repo *repository.MemoryRepository, coin *models.TrackedCoin, rule models.AlertRule) *models.AlertRule {
+	t.Helper()
+	rule.Owner = "alice"
+	rule.CoinID = coin.ID
+	rule.Coin = coin.Symbol
+	rule.State = models.AlertArmed
+	rule.StateChangedAt = baseTs
+	rule.CreatedAt = baseTs
+	if err := repo.CreateAlertRule(t.Context(), &rule); err != nil {
+		t.Fatalf("CreateAlertRule: %v", err)
+	}
+	return &rule
+}
+
+// savePrice записывает тик, как опрос записывает цены перед проверкой правил
+func savePrice(t *testing.T, repo *repository.MemoryRepository, coin *models.TrackedCoin, ts models.Timestamp, price string) *models.CryptoPrice {
+	t.Helper()
+	p := &models.CryptoPrice{CoinID: coin.ID, Symbol: coin.Symbol, Price: decimal.RequireFromString(price), Timestamp: ts}
+	if err := repo.AddNewPrice(t.Context(), p); err != nil {
+		t.Fatalf("AddNewPrice(%s): %v", price, err)
+	}
+	return p
+}
+
+func poll(t *testing.T, repo *repository.MemoryRepository, e *Engine, coin *models.TrackedCoin, ts models.Timestamp, price string) {
+	t.Helper()
+	p := savePrice(t, repo, coin, ts, price)
+	if err := e.Evaluate(t.Context(), []*models.CryptoPrice{p}); err != nil {
+		t.Fatalf("Evaluate(%s): %v", price, err)
+	}
+}
+
+func expectState(t *testing.T, repo *repository.MemoryRepository, id int64, want models.AlertState) {
+	t.Helper()
+	rule, err := repo.GetAlertRule(t.Context(), id)
+	if err != nil {
+		t.Fatalf("GetAlertRule(%d): %v", id, err)
+	}
+	if rule.State != want {
+		t.Fatalf("rule %d state = %s, want %s", id, rule.State, want)
+	}
+}
+
+func events(t *testing.T, repo *repository.MemoryRepository, id int64) []*models.AlertEvent {
+	t.Helper()
+	events, err := repo.GetAlertEvents(t.Context(), &models.AlertEventFilter{RuleID: id})
+	if err != nil {
+		t.Fatalf("GetAlertEvents(%d): %v", id, err)
+	}
+	return events
+}
+
+func TestPriceThresholdHysteresis(t *testing.T) {
+	repo, coin := newStore(t)
+	rule := createRule(t, repo, coin, models.AlertRule{
+		Condition:  models.AlertPriceAbove,
+		Threshold:  decimal.NewFromInt(100),
+		Hysteresis: decimal.NewFromInt(1),
+		Cooldown:   models.Duration(10 * time.Minute),
+	})
+	e := New(repo, zap.NewNop())
+
+	poll(t, repo, e, coin, baseTs, "99")
+	expectState(t, repo, rule.ID, models.AlertArmed)
+	poll(t, repo, e, coin, baseTs+minute, "101")
+	expectState(t, repo, rule.ID, models.AlertFired)
+
+	// Колебания у порога в пределах гистерезиса не сбрасывают правило
+	poll(t, repo, e, coin, baseTs+2*minute, "99.5")
+	poll(t, repo, e, coin, baseTs+3*minute, "100.5")
+	expectState(t, repo, rule.ID, models.AlertFired)
+	if got := events(t, repo, rule.ID); len(got) != 1 || got[0].FiredAt != baseTs+minute || !got[0].Price.Equal(decimal.NewFromInt(101)) {
+		t.Fatalf("events = %+v, want one at 101", got)
+	}
+
+	// Сброс до истечения паузы после срабатывания
+	poll(t, repo, e, coin, baseTs+4*minute, "98.9")
+	expectState(t, repo, rule.ID, models.AlertCoolingDown)
+	poll(t, repo, e, coin, baseTs+5*minute, "102")
+	expectState(t, repo, rule.ID, models.AlertCoolingDown)
+
+	// После паузы правило взводится и срабатывает на том же тике
+	poll(t, repo, e, coin, baseTs+11*minute, "102")
+	expectState(t, repo, rule.ID, models.AlertFired)
+	got := events(t, repo, rule.ID)
+	if len(got) != 2 || got[0].FiredAt != baseTs+11*minute {
+		t.Fatalf("events = %+v, want second at 11m", got)
+	}
+	if got[0].Coin != "BTC" || got[0].Condition != models.AlertPriceAbove || !got[0].Value.Equal(decimal.NewFromInt(102)) {
+		t.Errorf("event = %+v, want BTC price_above at 102", got[0])
+	}
+}
+
+func TestPercentChange(t *testing.T) {
+	repo, coin := newStore(t)
+	// История до первого опроса загружается из хранилища
+	savePrice(t, repo, coin, baseTs-70*minute, "100")
+	savePrice(t, repo, coin, baseTs-30*minute, "98")
+	rule := createRule(t, repo, coin, models.AlertRule{
+		Condition:  models.AlertChangeBelow,
+		Threshold:  decimal.NewFromInt(-5),
+		Window:     models.Duration(time.Hour),
+		Hysteresis: decimal.NewFromInt(2),
+	})
+	e := New(repo, zap.NewNop())
+
+	poll(t, repo, e, coin, baseTs, "96")
+	expectState(t, repo, rule.ID, models.AlertArmed)
+	poll(t, repo, e, coin, baseTs+minute, "94")
+	expectState(t, repo, rule.ID, models.AlertFired)
+	got := events(t, repo, rule.ID)
+	if len(got) != 1 || !got[0].Value.Equal(decimal.NewFromInt(-6)) {
+		t.Fatalf("events = %+v, want change -6%%", got)
+	}
+
+	// База смещается к 98: -4% не сбрасывает правило с запасом 2 п.п., +1% сбрасывает
+	poll(t, repo, e, coin, baseTs+35*minute, "94.08")
+	expectState(t, repo, rule.ID, models.AlertFired)
+	poll(t, repo, e, coin, baseTs+40*minute, "98.98")
+	expectState(t, repo, rule.ID, models.AlertArmed)
+}
+
+func TestPercentChangeNeedsHistory(t *testing.T) {
+	repo, coin := newStore(t)
+	rule := createRule(t, repo, coin, models.AlertRule{
+		Condition:  models.AlertChangeAbove,
+		Threshold:  decimal.NewFromInt(1),
+		Window:     models.Duration(time.Hour),
+		Hysteresis: decimal.NewFromInt(1),
+	})
+	e := New(repo, zap.NewNop())
+
+	poll(t, repo, e, coin, baseTs, "100")
+	poll(t, repo, e, coin, baseTs+minute, "150")
+	expectState(t, repo, rule.ID, models.AlertArmed)
+	poll(t, repo, e, coin, baseTs+60*minute, "150")
+	expectState(t, repo, rule.ID, models.AlertFired)
+}
+
+func TestMovingAverageCross(t *testing.T) {
+	repo, coin := newStore(t)
+	for i := range 10 {
+		savePrice(t, repo, coin, baseTs+models.Timestamp(i)*minute, "100")
+	}
+	rule := createRule(t, repo, coin, models.AlertRule{
+		Condition:  models.AlertCrossAboveMA,
+		Window:     models.Duration(5 * time.Minute),
+		Hysteresis: decimal.NewFromInt(1),
+	})
+	e := New(repo, zap.NewNop())
+
+	poll(t, repo, e, coin, baseTs+10*minute, "99")
+	expectState(t, repo, rule.ID, models.AlertArmed)
+	poll(t, repo, e, coin, baseTs+11*minute, "105")
+	expectState(t, repo, rule.ID, models.AlertFired)
+	got := events(t, repo, rule.ID)
+	// Среднее тиков за (6m, 11m]: 100, 100, 100, 99, 105
+	if len(got) != 1 || !got[0].Value.Equal(decimal.RequireFromString("100.8")) {
+		t.Fatalf("events = %+v, want one with average 100.8", got)
+	}
+
+	// Цена выше среднего больше не пересекает его
+	poll(t, repo, e, coin, baseTs+12*minute, "106")
+	expectState(t, repo, rule.ID, models.AlertFired)
+	if got := events(t, repo, rule.ID); len(got) != 1 {
+		t.Errorf("events = %d, want 1", len(got))
+	}
+}
+
+func TestConcurrentEngines(t *testing.T) {
+	repo, coin := newStore(t)
+	rule := createRule(t, repo, coin, models.AlertRule{
+		Condition:  models.AlertPriceBelow,
+		Threshold:  decimal.NewFromInt(50),
+		Hysteresis: decimal.NewFromInt(1),
+	})
+	first, second := New(repo, zap.NewNop()), New(repo, zap.NewNop())
+
+	// Оба экземпляра прочитали правило до того, как другой изменил его состояние
+	p := savePrice(t, repo, coin, baseTs, "49")
+	rules, err := repo.ListAlertRules(t.Context(), "")
+	if err != nil {
+		t.Fatalf("ListAlertRules: %v", err)
+	}
+	if err := first.apply(t.Context(), rules[0], nil, p); err != nil {
+		t.Fatalf("first apply: %v", err)
+	}
+	stale := *rule
+	if err := second.apply(t.Context(), &stale, nil, p); err != nil {
+		t.Fatalf("second apply: %v", err)
+	}
+	if err := second.Evaluate(t.Context(), []*models.CryptoPrice{p}); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	expectState(t, repo, rule.ID, models.AlertFired)
+	if got := events(t, repo, rule.ID); len(got) != 1 {
+		t.Errorf("events = %d, want 1", len(got))
+	}
+}
@@ -0,0 +1,317 @@
+package archive
+
+import (
+	"awesomeProject/internal/models"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Тики монеты за сутки UTC хранятся одним сжатым CSV-файлом
+// coin_prices/<coin_id>/YYYY/MM/DD.csv.gz со строками "timestamp_ms,price"
+// по возрастанию времени
+const (
+	keyPrefix = "coin_prices/"
+	dayLayout = "2006/01/02"
+	keySuffix = ".csv.gz"
+)
+
+// Archive - архив тиков старше срока хранения в базе
+type Archive struct {
+	store  Store
+	maxAge int // Срок хранения тиков в базе в сутках
+}
+
+func New(store Store, maxAgeDays int) *Archive {
+	return &Archive{store: store, maxAge: maxAgeDays}
+}
+
+// Cutoff возвращает границу архива на момент now: полночь UTC, раньше которой
+// тики переносятся в архив. Все архивные тики раньше этой границы.
+func (a *Archive) Cutoff(now time.Time) time.Time {
+	return dayStart(now).AddDate(0, 0, -a.maxAge)
+}
+
+func dayStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func coinPrefix(coinID int64) string {
+	return keyPrefix + strconv.FormatInt(coinID, 10) + "/"
+}
+
+func dayKey(coinID int64, day time.Time) string {
+	return coinPrefix(coinID) + day.UTC().Format(dayLayout) + keySuffix
+}
+
+// Write добавляет тики монеты за сутки day в архив. Тики, уже лежащие в архиве
+// с тем же временем, заменяются: повторная выгрузка тех же суток безопасна.
+func (a *Archive) Write(ctx context.Context, coinID int64, day time.Time, prices []*models.CryptoPrice) error {
+	key := dayKey(coinID, day)
+	existing, err := a.load(ctx, coinID, key)
+	if err != nil {
+		return err
+	}
+
+	merged := MergePrices(existing, prices)
+	data, err := encode(merged)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	if err := a.store.Put(ctx, key, data); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// Prices возвращает архивные тики монеты за период [from, to) по возрастанию времени
+func (a *Archive) Prices(ctx context.Context, coinID int64, from, to models.Timestamp) ([]*models.CryptoPrice, error) {
+	days, err := a.days(ctx, coinID)
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := dayStart(from.Time()), to.Time()
+	var prices []*models.CryptoPrice
+	for _, day := range days {
+		if day.Before(first) || !day.Before(last) {
+			continue
+		}
+		loaded, err := a.load(ctx, coinID, dayKey(coinID, day))
+		if err != nil {
+			return nil, err
+		}
+		for _, price := range loaded {
+			if price.Timestamp >= from && price.Timestamp < to {
+				prices = append(prices, price)
+			}
+		}
+	}
+	return prices, nil
+}
+
+// PricesAfter возвращает не меньше limit архивных тиков монеты позже after по возрастанию
+// времени, если они есть: сутки архива читаются целиком, пока тиков не наберется limit
+func (a *Archive) PricesAfter(ctx context.Context, coinID int64, after models.Timestamp, limit int) ([]*models.CryptoPrice, error) {
+	days, err := a.days(ctx, coinID)
+	if err != nil {
+		return nil, err
+	}
+
+	first := dayStart(after.Time())
+	var prices []*models.CryptoPrice
+	for _, day := range days {
+		if len(prices) >= limit {
+			break
+		}
+		if day.Before(first) {
+			continue
+		}
+		loaded, err := a.load(ctx, coinID, dayKey(coinID, day))
+		if err != nil {
+			return nil, err
+		}
+		for _, price := range loaded {
+			if price.Timestamp > after {
+				prices = append(prices, price)
+			}
+		}
+	}
+	return prices, nil
+}
+
+// Nearest возвращает ближайший к timestamp архивный тик монеты, при равном
+// расстоянии - более ранний. Если в архиве нет тиков монеты, возвращает nil.
+func (a *Archive) Nearest(ctx context.Context, coinID int64, timestamp models.Timestamp) (*models.CryptoPrice, error) {
+	days, err := a.days(ctx, coinID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ближайший тик лежит в сутках timestamp или в соседних непустых сутках архива
+	day := dayStart(timestamp.Time())
+	i := sort.Search(len(days), func(i int) bool { return !days[i].Before(day) })
+	lo, hi := max(i-1, 0), min(i+1, len(days))
+	if i < len(days) && days[i].Equal(day) {
+		hi = min(i+2, len(days))
+	}
+
+	var nearest *models.CryptoPrice
+	for _, day := range days[lo:hi] {
+		loaded, err := a.load(ctx, coinID, dayKey(coinID, day))
+		if err != nil {
+			return nil, err
+		}
+		for _, price := range loaded {
+			if nearest == nil || Closer(price.Timestamp, nearest.Timestamp, timestamp) {
+				nearest = price
+			}
+		}
+	}
+	return nearest, nil
+}
+
+// Closer сообщает, что момент a ближе к target, чем b; при равном расстоянии ближе более ранний
+func Closer(a, b, target models.Timestamp) bool {
+	da, db := distance(a, target), distance(b, target)
+	return da < db || (da == db && a < b)
+}
+
+func distance(a, b models.Timestamp) models.Timestamp {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// Count возвращает число архивных тиков монеты
+func (a *Archive) Count(ctx context.Context, coinID int64) (int64, error) {
+	days, err := a.days(ctx, coinID)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, day := range days {
+		loaded, err := a.load(ctx, coinID, dayKey(coinID, day))
+		if err != nil {
+			return 0, err
+		}
+		count += int64(len(loaded))
+	}
+	return count, nil
+}
+
+// DeleteCoin удаляет архив монеты и возвращает число удаленных суточных файлов
+func (a *Archive) DeleteCoin(ctx context.Context, coinID int64) (int, error) {
+	keys, err := a.store.List(ctx, coinPrefix(coinID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list archive: %w", err)
+	}
+	for i, key := range keys {
+		if err := a.store.Delete(ctx, key); err != nil {
+			return i, fmt.Errorf("failed to delete archive: %w", err)
+		}
+	}
+	return len(keys), nil
+}
+
+// days возвращает сутки, за которые в архиве есть тики монеты, по возрастанию
+func (a *Archive) days(ctx context.Context, coinID int64) ([]time.Time, error) {
+	prefix := coinPrefix(coinID)
+	keys, err := a.store.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	days := make([]time.Time, 0, len(keys))
+	for _, key := range keys {
+		name, ok := strings.CutSuffix(strings.TrimPrefix(key, prefix), keySuffix)
+		if !ok {
+			continue
+		}
+		day, err := time.Parse(dayLayout, name)
+		if err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// load читает суточный файл; отсутствующий файл - пустые сутки
+func (a *Archive) load(ctx context.Context, coinID int64, key string) ([]*models.CryptoPrice, error) {
+	data, err := a.store.Get(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	prices, err := decode(data, coinID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	return prices, nil
+}
+
+// MergePrices объединяет упорядоченные по времени тики; при совпадении
+// времени остается тик из update
+func MergePrices(current, update []*models.CryptoPrice) []*models.CryptoPrice {
+	merged := make([]*models.CryptoPrice, 0, len(current)+len(update))
+	i, j := 0, 0
+	for i < len(current) || j < len(update) {
+		switch {
+		case j == len(update) || (i < len(current) && current[i].Timestamp < update[j].Timestamp):
+			merged = append(merged, current[i])
+			i++
+		case i == len(current) || update[j].Timestamp < current[i].Timestamp:
+			merged = append(merged, update[j])
+			j++
+		default:
+			merged = append(merged, update[j])
+			i++
+			j++
+		}
+	}
+	return merged
+}
+
+func encode(prices []*models.CryptoPrice) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	w := bufio.NewWriter(zw)
+	for _, price := range prices {
+		w.WriteString(strconv.FormatInt(int64(price.Timestamp), 10))
+		w.WriteByte(',')
+		w.WriteString(price.Price.String())
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decode(data []byte, coinID int64) ([]*models.CryptoPrice, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var prices []*models.CryptoPrice
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		timestamp, price, ok := strings.Cut(scanner.Text(), ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %q", scanner.Text())
+		}
+		ms, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q: %w", timestamp, err)
+		}
+		value, err := decimal.NewFromString(price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price %q: %w", price, err)
+		}
+		prices = append(prices, &models.CryptoPrice{CoinID: coinID, Price: value, Timestamp: models.Timestamp(ms)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
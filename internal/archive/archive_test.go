@@ -0,0 +1,223 @@
+package archive
+
+import (
+	"awesomeProject/internal/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// day0 - полночь 2023-11-14 UTC
+var day0 = time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC)
+
+func tick(day int, offset time.Duration, price string) *models.CryptoPrice {
+	return &models.CryptoPrice{
+		CoinID:    1,
+		Price:     decimal.RequireFromString(price),
+		Timestamp: models.TimestampOf(day0.AddDate(0, 0, day).Add(offset)),
+	}
+}
+
+func prices(t *testing.T, got []*models.CryptoPrice) string {
+	t.Helper()
+	parts := make([]string, len(got))
+	for i, price := range got {
+		parts[i] = price.Timestamp.String() + "=" + price.Price.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+func testArchive(t *testing.T, store Store) {
+	ctx := t.Context()
+	a := New(store, 30)
+
+	if err := a.Write(ctx, 1, day0, []*models.CryptoPrice{tick(0, time.Hour, "1"), tick(0, 2*time.Hour, "2")}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	// Повторная выгрузка суток заменяет тики с тем же временем и добавляет новые
+	if err := a.Write(ctx, 1, day0, []*models.CryptoPrice{tick(0, 2*time.Hour, "2.5"), tick(0, 3*time.Hour+time.Millisecond, "3")}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := a.Write(ctx, 1, day0.AddDate(0, 0, 5), []*models.CryptoPrice{tick(5, 0, "5")}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := a.Write(ctx, 2, day0, []*models.CryptoPrice{tick(0, time.Hour, "100")}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	got, err := a.Prices(ctx, 1, models.TimestampOf(day0), models.TimestampOf(day0.AddDate(0, 0, 10)))
+	if err != nil {
+		t.Fatalf("Prices() error = %v", err)
+	}
+	want := "1699923600=1 1699927200=2.5 1699930800.001=3 1700352000=5"
+	if s := prices(t, got); s != want {
+		t.Errorf("Prices() = %s, want %s", s, want)
+	}
+
+	got, err = a.Prices(ctx, 1, tick(0, 2*time.Hour, "0").Timestamp, tick(5, 0, "0").Timestamp)
+	if err != nil {
+		t.Fatalf("Prices() error = %v", err)
+	}
+	if s, want := prices(t, got), "1699927200=2.5 1699930800.001=3"; s != want {
+		t.Errorf("Prices([2h, day 5)) = %s, want %s", s, want)
+	}
+
+	// Страница дочитывает сутки целиком и не заходит в следующие, если тиков уже достаточно
+	got, err = a.PricesAfter(ctx, 1, tick(0, time.Hour, "0").Timestamp, 1)
+	if err != nil {
+		t.Fatalf("PricesAfter() error = %v", err)
+	}
+	if s, want := prices(t, got), "1699927200=2.5 1699930800.001=3"; s != want {
+		t.Errorf("PricesAfter(1h, 1) = %s, want %s", s, want)
+	}
+	got, err = a.PricesAfter(ctx, 1, tick(0, 3*time.Hour+time.Millisecond, "0").Timestamp, 10)
+	if err != nil {
+		t.Fatalf("PricesAfter() error = %v", err)
+	}
+	if s, want := prices(t, got), "1700352000=5"; s != want {
+		t.Errorf("PricesAfter(last tick of day 0) = %s, want %s", s, want)
+	}
+
+	tests := []struct {
+		at   *models.CryptoPrice
+		want string
+	}{
+		{tick(-3, 0, "0"), "1"},             // раньше всего архива
+		{tick(0, 90*time.Minute, "0"), "1"}, // посередине - более ранний
+		{tick(0, 3*time.Hour, "0"), "3"},
+		{tick(2, 0, "0"), "3"}, // между сутками архива
+		{tick(3, 0, "0"), "5"},
+		{tick(40, 0, "0"), "5"}, // позже всего архива
+	}
+	for _, tt := range tests {
+		nearest, err := a.Nearest(ctx, 1, tt.at.Timestamp)
+		if err != nil {
+			t.Fatalf("Nearest(%s) error = %v", tt.at.Timestamp, err)
+		}
+		if nearest == nil || nearest.Price.String() != tt.want || nearest.CoinID != 1 {
+			t.Errorf("Nearest(%s) = %+v, want price %s", tt.at.Timestamp, nearest, tt.want)
+		}
+	}
+	if nearest, err := a.Nearest(ctx, 3, tick(0, 0, "0").Timestamp); err != nil || nearest != nil {
+		t.Errorf("Nearest(coin without archive) = %+v, %v, want nil", nearest, err)
+	}
+
+	if count, err := a.Count(ctx, 1); err != nil || count != 4 {
+		t.Errorf("Count() = %d, %v, want 4", count, err)
+	}
+
+	files, err := a.DeleteCoin(ctx, 1)
+	if err != nil || files != 2 {
+		t.Errorf("DeleteCoin() = %d, %v, want 2 files", files, err)
+	}
+	if nearest, err := a.Nearest(ctx, 1, tick(0, 0, "0").Timestamp); err != nil || nearest != nil {
+		t.Errorf("Nearest(after DeleteCoin) = %+v, %v, want nil", nearest, err)
+	}
+	if nearest, err := a.Nearest(ctx, 2, tick(0, 0, "0").Timestamp); err != nil || nearest == nil {
+		t.Errorf("Nearest(other coin) = %+v, %v, want price", nearest, err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	testArchive(t, store)
+}
+
+func TestS3Store(t *testing.T) {
+	server := httptest.NewServer(newFakeS3(t, "archive"))
+	defer server.Close()
+
+	store, err := NewS3Store(server.URL, "", "archive", "key", "secret")
+	if err != nil {
+		t.Fatalf("NewS3Store() error = %v", err)
+	}
+	testArchive(t, store)
+}
+
+func TestCutoff(t *testing.T) {
+	a := New(nil, 30)
+	got := a.Cutoff(time.Date(2024, 3, 31, 23, 59, 0, 0, time.FixedZone("MSK", 3*3600)))
+	if want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Cutoff() = %s, want %s", got, want)
+	}
+}
+
+// fakeS3 - бакет в памяти, отвечающий на PUT, GET, DELETE и ListObjectsV2
+type fakeS3 struct {
+	t       *testing.T
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeS3(t *testing.T, bucket string) *fakeS3 {
+	return &fakeS3{t: t, bucket: bucket, objects: map[string][]byte{}}
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") || r.Header.Get("X-Amz-Date") == "" {
+		s.t.Errorf("unsigned request %s %s", r.Method, r.URL)
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+s.bucket)
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key = strings.TrimPrefix(key, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[key] = data
+	case r.Method == http.MethodGet && key == "":
+		s.list(w, r.URL.Query())
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// list отдает ключи страницами по одному, чтобы проверить продолжение списка
+func (s *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, query.Get("prefix")) && key > query.Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult>`))
+	if len(keys) > 0 {
+		w.Write([]byte("<Contents><Key>" + keys[0] + "</Key></Contents>"))
+	}
+	if len(keys) > 1 {
+		w.Write([]byte("<IsTruncated>true</IsTruncated><NextContinuationToken>" + keys[0] + "</NextContinuationToken>"))
+	}
+	w.Write([]byte("</ListBucketResult>"))
+}
@@ -0,0 +1,260 @@
+package backup
+
+import (
+	"archive/zip"
+	"awesomeProject/internal/models"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Резервная копия - zip-архив с файлами:
+//
+//	coins.jsonl   - монеты с метаданными и состоянием, по строке на монету
+//	prices.jsonl  - история цен, по строке на тик, по монетам и по возрастанию времени
+//	manifest.json - формат, версия, число записей и SHA-256 каждого файла
+//
+// Идентификаторы монет и цен не сохраняются: при восстановлении цены
+// привязываются к монетам по символу, поэтому копию можно загрузить в любое хранилище.
+// API-ключи, списки наблюдения и доступ к ним, правила и события оповещений, квоты
+// и журнал аудита в копию не входят.
+const (
+	Format  = "crypto-currency-tracker-backup"
+	Version = 1
+
+	manifestFile = "manifest.json"
+	coinsFile    = "coins.jsonl"
+	pricesFile   = "prices.jsonl"
+
+	pageSize = 5000 // Тиков в одном запросе выгрузки и в одном пакете загрузки
+)
+
+// Manifest - описание резервной копии
+type Manifest struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Driver    string    `json:"driver"` // Хранилище, из которого сделана копия
+	Files     []File    `json:"files"`
+}
+
+// File - файл данных резервной копии
+type File struct {
+	Name    string `json:"name"`
+	Records int64  `json:"records"`
+	SHA256  string `json:"sha256"`
+}
+
+// coinRecord - строка coins.jsonl
+type coinRecord struct {
+	Symbol string            `json:"symbol"`
+	Status models.CoinStatus `json:"status"`
+	models.CoinMetadata
+}
+
+// priceRecord - строка prices.jsonl
+type priceRecord struct {
+	Coin      string           `json:"coin"`
+	Price     decimal.Decimal  `json:"price"`
+	Timestamp models.Timestamp `json:"timestamp"`
+}
+
+// source - хранилище, из которого делается копия
+type source interface {
+	ExportCoins(ctx context.Context) ([]*models.TrackedCoin, error)
+	ExportPrices(ctx context.Context, coinID int64, after models.Timestamp, limit int) ([]*models.CryptoPrice, error)
+}
+
+// Write записывает резервную копию хранилища src в w. driver сохраняется в манифесте.
+func Write(ctx context.Context, w io.Writer, src source, driver string) (*Manifest, error) {
+	coins, err := src.ExportCoins(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export coins: %w", err)
+	}
+
+	manifest := &Manifest{Format: Format, Version: Version, CreatedAt: time.Now().UTC(), Driver: driver}
+	zw := zip.NewWriter(w)
+
+	file, err := writeRecords(zw, coinsFile, func(write func(any) error) error {
+		for _, coin := range coins {
+			if err := write(coinRecord{Symbol: coin.Symbol, Status: coin.Status, CoinMetadata: coin.CoinMetadata}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifest.Files = append(manifest.Files, *file)
+
+	file, err = writeRecords(zw, pricesFile, func(write func(any) error) error {
+		for _, coin := range coins {
+			// Сервис не принимает тики раньше 1970 года, поэтому выгрузка начинается с 0
+			var after models.Timestamp
+			for {
+				prices, err := src.ExportPrices(ctx, coin.ID, after, pageSize)
+				if err != nil {
+					return fmt.Errorf("failed to export prices of %s: %w", coin.Symbol, err)
+				}
+				for _, price := range prices {
+					if err := write(priceRecord{Coin: coin.Symbol, Price: price.Price, Timestamp: price.Timestamp}); err != nil {
+						return err
+					}
+				}
+				if len(prices) < pageSize {
+					break
+				}
+				after = prices[len(prices)-1].Timestamp
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifest.Files = append(manifest.Files, *file)
+
+	mw, err := zw.Create(manifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", manifestFile, err)
+	}
+	encoder := json.NewEncoder(mw)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", manifestFile, err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish backup: %w", err)
+	}
+	return manifest, nil
+}
+
+// writeRecords записывает в архив файл JSON Lines, считая записи и контрольную сумму
+func writeRecords(zw *zip.Writer, name string, records func(write func(any) error) error) (*File, error) {
+	w, err := zw.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	sum := sha256.New()
+	buf := bufio.NewWriter(io.MultiWriter(w, sum))
+	encoder := json.NewEncoder(buf)
+
+	file := &File{Name: name}
+	err = records(func(record any) error {
+		file.Records++
+		return encoder.Encode(record)
+	})
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	file.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	return file, nil
+}
+
+// Backup - открытая для чтения резервная копия
+type Backup struct {
+	Manifest *Manifest
+	files    map[string]*zip.File
+}
+
+// Open читает манифест резервной копии и проверяет формат и версию
+func Open(r io.ReaderAt, size int64) (*Backup, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, file := range zr.File {
+		files[file.Name] = file
+	}
+
+	manifestEntry, ok := files[manifestFile]
+	if !ok {
+		return nil, fmt.Errorf("backup has no %s", manifestFile)
+	}
+	rc, err := manifestEntry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", manifestFile, err)
+	}
+	defer rc.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", manifestFile, err)
+	}
+	if manifest.Format != Format {
+		return nil, fmt.Errorf("unknown backup format %q", manifest.Format)
+	}
+	if manifest.Version < 1 || manifest.Version > Version {
+		return nil, fmt.Errorf("unsupported backup version %d, supported up to %d", manifest.Version, Version)
+	}
+	for _, name := range []string{coinsFile, pricesFile} {
+		if _, err := manifest.file(name); err != nil {
+			return nil, err
+		}
+		if _, ok := files[name]; !ok {
+			return nil, fmt.Errorf("backup has no %s", name)
+		}
+	}
+	return &Backup{Manifest: &manifest, files: files}, nil
+}
+
+func (m *Manifest) file(name string) (*File, error) {
+	for i := range m.Files {
+		if m.Files[i].Name == name {
+			return &m.Files[i], nil
+		}
+	}
+	return nil, fmt.Errorf("manifest has no %s", name)
+}
+
+// readRecords читает файл JSON Lines и передает каждую строку в decode.
+// Число записей и контрольная сумма сверяются с манифестом после чтения файла целиком.
+func (b *Backup) readRecords(name string, decode func(line []byte) error) error {
+	expected, err := b.Manifest.file(name)
+	if err != nil {
+		return err
+	}
+	rc, err := b.files[name].Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	sum := sha256.New()
+	scanner := bufio.NewScanner(io.TeeReader(rc, sum))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var records int64
+	for scanner.Scan() {
+		records++
+		if err := decode(scanner.Bytes()); err != nil {
+			return fmt.Errorf("%s:%d: %w", name, records, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	if got := hex.EncodeToString(sum.Sum(nil)); got != expected.SHA256 {
+		return fmt.Errorf("%w: %s has sha256 %s, manifest has %s", ErrChecksum, name, got, expected.SHA256)
+	}
+	if records != expected.Records {
+		return fmt.Errorf("%w: %s has %d records, manifest has %d", ErrChecksum, name, records, expected.Records)
+	}
+	return nil
+}
+
+// ErrChecksum - содержимое файла не совпадает с манифестом
+var ErrChecksum = errors.New("backup is corrupted")
@@ -0,0 +1,205 @@
+package backup
+
+import (
+	"archive/zip"
+	"awesomeProject/internal/models"
+	"awesomeProject/internal/repository"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+var actor = &models.Actor{Name: "test"}
+
+// seed заполняет хранилище монетами во всех состояниях и историей цен
+// длиннее одной страницы выгрузки
+func seed(t *testing.T, r *repository.MemoryRepository) {
+	t.Helper()
+	ctx := t.Context()
+	for _, symbol := range []string{"BTC", "ETH", "SOL", "DOGE"} {
+		coin := &models.TrackedCoin{Symbol: symbol, CoinMetadata: models.CoinMetadata{Name: symbol + " coin", ProviderID: strings.ToLower(symbol), Decimals: 8}}
+		if err := r.AddCoin(ctx, coin, actor); err != nil {
+			t.Fatalf("AddCoin(%s): %v", symbol, err)
+		}
+	}
+	btc, _ := r.GetCoin(ctx, "BTC")
+	eth, _ := r.GetCoin(ctx, "ETH")
+
+	var prices []*models.CryptoPrice
+	for i := range pageSize + 10 {
+		prices = append(prices, &models.CryptoPrice{CoinID: btc.ID, Price: decimal.New(int64(i+1), -18), Timestamp: models.Timestamp(1_700_000_000_000 + i*1500)})
+	}
+	prices = append(prices, &models.CryptoPrice{CoinID: eth.ID, Price: decimal.RequireFromString("2000.123456789012345678"), Timestamp: 1_700_000_000_001})
+	if _, err := r.AddNewPrices(ctx, prices); err != nil {
+		t.Fatalf("AddNewPrices: %v", err)
+	}
+
+	if err := r.SetCoinStatus(ctx, "ETH", models.CoinStatusPaused, actor); err != nil {
+		t.Fatalf("SetCoinStatus: %v", err)
+	}
+	if err := r.SetCoinStatus(ctx, "SOL", models.CoinStatusDelisted, actor); err != nil {
+		t.Fatalf("SetCoinStatus: %v", err)
+	}
+	if err := r.RemoveCoin(ctx, &models.TrackedCoin{Symbol: "DOGE"}, actor); err != nil {
+		t.Fatalf("RemoveCoin: %v", err)
+	}
+}
+
+// dump описывает монеты и цены хранилища без идентификаторов
+func dump(t *testing.T, r source) string {
+	t.Helper()
+	coins, err := r.ExportCoins(t.Context())
+	if err != nil {
+		t.Fatalf("ExportCoins: %v", err)
+	}
+	var b strings.Builder
+	for _, coin := range coins {
+		fmt.Fprintf(&b, "%s %s %+v\n", coin.Symbol, coin.Status, coin.CoinMetadata)
+		prices, err := r.ExportPrices(t.Context(), coin.ID, 0, 1_000_000)
+		if err != nil {
+			t.Fatalf("ExportPrices: %v", err)
+		}
+		for _, price := range prices {
+			fmt.Fprintf(&b, "  %s %s\n", price.Timestamp, price.Price)
+		}
+	}
+	return b.String()
+}
+
+func writeBackup(t *testing.T, src source) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	manifest, err := Write(t.Context(), &buf, src, "memory")
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := []File{{Name: coinsFile, Records: 4}, {Name: pricesFile, Records: pageSize + 11}}
+	for i := range manifest.Files {
+		manifest.Files[i].SHA256 = ""
+	}
+	if !reflect.DeepEqual(manifest.Files, want) {
+		t.Fatalf("manifest files = %+v, want %+v", manifest.Files, want)
+	}
+	return buf.Bytes()
+}
+
+func openBackup(t *testing.T, data []byte) *Backup {
+	t.Helper()
+	b, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return b
+}
+
+func TestBackupRestore(t *testing.T) {
+	src := repository.NewMemoryRepository()
+	seed(t, src)
+	data := writeBackup(t, src)
+
+	// Копия из одного хранилища загружается в другое
+	storage, err := repository.NewSQLiteStorage(filepath.Join(t.TempDir(), "coins.db"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	defer storage.Close()
+	dst := storage.NewRepository()
+
+	summary, err := openBackup(t, data).Restore(t.Context(), dst)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if *summary != (Summary{Coins: 4, Prices: pageSize + 11}) {
+		t.Errorf("Restore summary = %+v", summary)
+	}
+	if got, want := dump(t, dst), dump(t, src); got != want {
+		t.Errorf("restored storage:\n%s\nwant:\n%s", got, want)
+	}
+
+	_, err = openBackup(t, data).Restore(t.Context(), dst)
+	if !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("Restore into non-empty storage error = %v, want ErrNotEmpty", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	src := repository.NewMemoryRepository()
+	seed(t, src)
+	data := writeBackup(t, src)
+
+	summary, err := openBackup(t, data).Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if *summary != (Summary{Coins: 4, Prices: pageSize + 11}) {
+		t.Errorf("Verify summary = %+v", summary)
+	}
+
+	// Измененная цена не совпадает с контрольной суммой, хранилище не затрагивается
+	tampered := rewrite(t, data, pricesFile, func(content []byte) []byte {
+		return bytes.Replace(content, []byte(`"2000.123456789012345678"`), []byte(`"3000.123456789012345678"`), 1)
+	})
+	if _, err := openBackup(t, tampered).Verify(); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Verify(tampered) error = %v, want ErrChecksum", err)
+	}
+	dst := repository.NewMemoryRepository()
+	if _, err := openBackup(t, tampered).Restore(t.Context(), dst); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Restore(tampered) error = %v, want ErrChecksum", err)
+	}
+	if coins, _ := dst.ExportCoins(t.Context()); len(coins) != 0 {
+		t.Errorf("Restore(tampered) wrote %d coins", len(coins))
+	}
+
+	newer := rewrite(t, data, manifestFile, func(content []byte) []byte {
+		return bytes.Replace(content, []byte(`"version": 1`), []byte(`"version": 2`), 1)
+	})
+	if _, err := Open(bytes.NewReader(newer), int64(len(newer))); err == nil {
+		t.Errorf("Open(version 2) succeeded, want error")
+	}
+}
+
+// rewrite возвращает копию архива, в которой файл name изменен функцией edit
+func rewrite(t *testing.T, data []byte, name string, edit func([]byte) []byte) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+		if file.Name == name {
+			edited := edit(content)
+			if bytes.Equal(edited, content) {
+				t.Fatalf("%s was not changed", name)
+			}
+			content = edited
+		}
+		w, err := zw.Create(file.Name)
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", file.Name, err)
+		}
+		w.Write(content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to write backup: %v", err)
+	}
+	return buf.Bytes()
+}
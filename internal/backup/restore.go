@@ -0,0 +1,184 @@
+package backup
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// target - хранилище, в которое восстанавливается копия
+type target interface {
+	ExportCoins(ctx context.Context) ([]*models.TrackedCoin, error)
+	AddCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error
+	GetCoin(ctx context.Context, symbol string) (*models.TrackedCoin, error)
+	SetCoinStatus(ctx context.Context, symbol string, status models.CoinStatus, actor *models.Actor) error
+	RemoveCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error
+	AddNewPrices(ctx context.Context, prices []*models.CryptoPrice) ([]error, error)
+}
+
+// Summary - число записей в проверенной или восстановленной копии
+type Summary struct {
+	Coins  int64
+	Prices int64
+}
+
+// restoreActor - инициатор изменений восстановления в журнале аудита
+var restoreActor = &models.Actor{Name: "restore"}
+
+// ErrNotEmpty - хранилище для восстановления уже содержит монеты
+var ErrNotEmpty = errors.New("target storage is not empty")
+
+// Verify читает копию целиком: проверяет строки, число записей и контрольные суммы
+// всех файлов. Хранилище не затрагивается.
+func (b *Backup) Verify() (*Summary, error) {
+	coins, err := b.coins()
+	if err != nil {
+		return nil, err
+	}
+	symbols := make(map[string]bool, len(coins))
+	for _, coin := range coins {
+		symbols[coin.Symbol] = true
+	}
+
+	summary := &Summary{Coins: int64(len(coins))}
+	err = b.readPrices(func(price *priceRecord) error {
+		if !symbols[price.Coin] {
+			return fmt.Errorf("price of unknown coin %q", price.Coin)
+		}
+		summary.Prices++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// Restore проверяет копию и загружает ее в пустое хранилище dst. Монеты
+// добавляются заново и получают новые идентификаторы; убранные из списка
+// наблюдения монеты восстанавливаются вместе с историей. Если загрузка
+// прервалась, хранилище нужно очистить перед повторной попыткой.
+func (b *Backup) Restore(ctx context.Context, dst target) (*Summary, error) {
+	if _, err := b.Verify(); err != nil {
+		return nil, err
+	}
+	existing, err := dst.ExportCoins(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check target storage: %w", err)
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("%w: %d coins", ErrNotEmpty, len(existing))
+	}
+
+	coins, err := b.coins()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]int64, len(coins))
+	for _, coin := range coins {
+		if err := dst.AddCoin(ctx, &models.TrackedCoin{Symbol: coin.Symbol, CoinMetadata: coin.CoinMetadata}, restoreActor); err != nil {
+			return nil, fmt.Errorf("failed to restore coin %s: %w", coin.Symbol, err)
+		}
+		restored, err := dst.GetCoin(ctx, coin.Symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore coin %s: %w", coin.Symbol, err)
+		}
+		ids[coin.Symbol] = restored.ID
+	}
+
+	summary := &Summary{Coins: int64(len(coins))}
+	batch := make([]*models.CryptoPrice, 0, pageSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		errs, err := dst.AddNewPrices(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("failed to restore prices: %w", err)
+		}
+		for i, err := range errs {
+			if err != nil {
+				return fmt.Errorf("failed to restore price of %s at %s: %w", batch[i].Symbol, batch[i].Timestamp, err)
+			}
+		}
+		summary.Prices += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+	err = b.readPrices(func(price *priceRecord) error {
+		batch = append(batch, &models.CryptoPrice{CoinID: ids[price.Coin], Symbol: price.Coin, Price: price.Price, Timestamp: price.Timestamp})
+		if len(batch) < pageSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Состояние восстанавливается последним: цены записываются в монеты в любом состоянии,
+	// а убранная монета не должна попасть в опрос, пока идет загрузка
+	for _, coin := range coins {
+		switch coin.Status {
+		case models.CoinStatusActive:
+		case models.CoinStatusUntracked:
+			err = dst.RemoveCoin(ctx, &models.TrackedCoin{Symbol: coin.Symbol}, restoreActor)
+		default:
+			err = dst.SetCoinStatus(ctx, coin.Symbol, coin.Status, restoreActor)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore status of %s: %w", coin.Symbol, err)
+		}
+	}
+	return summary, nil
+}
+
+// coins читает и проверяет coins.jsonl
+func (b *Backup) coins() ([]*coinRecord, error) {
+	var coins []*coinRecord
+	seen := make(map[string]bool)
+	err := b.readRecords(coinsFile, func(line []byte) error {
+		var coin coinRecord
+		if err := json.Unmarshal(line, &coin); err != nil {
+			return err
+		}
+		if coin.Symbol == "" {
+			return errors.New("coin without symbol")
+		}
+		if seen[coin.Symbol] {
+			return fmt.Errorf("duplicate coin %s", coin.Symbol)
+		}
+		if !coin.Status.Valid() && coin.Status != models.CoinStatusUntracked {
+			return fmt.Errorf("invalid status %q of %s", coin.Status, coin.Symbol)
+		}
+		seen[coin.Symbol] = true
+		coins = append(coins, &coin)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return coins, nil
+}
+
+// readPrices читает prices.jsonl и передает проверенные строки в fn
+func (b *Backup) readPrices(fn func(price *priceRecord) error) error {
+	return b.readRecords(pricesFile, func(line []byte) error {
+		var price priceRecord
+		if err := json.Unmarshal(line, &price); err != nil {
+			return err
+		}
+		if !price.Price.IsPositive() {
+			return fmt.Errorf("invalid price %s", price.Price)
+		}
+		if price.Timestamp <= 0 {
+			return fmt.Errorf("invalid timestamp %s", price.Timestamp)
+		}
+		return fn(&price)
+	})
+}
@@ -15,18 +15,52 @@ type Config struct {
 	VsCurrency    string        `yaml:"vs_currency"`
 	Rest          `yaml:"rest"`
 	MaxConcurrent int `yaml:"max_concurrent"`
+	Aggregation   `yaml:"aggregation"`
+	Partitions    `yaml:"partitions"`
+	Archive       Archive `yaml:"archive"`
 }
 type Storage struct {
+	Driver   string `yaml:"driver"` // postgres (по умолчанию), mysql или sqlite
+	Path     string `yaml:"path"`   // Путь к файлу базы SQLite
 	User     string `yaml:"user"`
 	Password string `yaml:"password"`
 	Host     string `yaml:"host"`
 	Port     string `yaml:"port"`
 	DbName   string `yaml:"db_name"`
 	SslMode  string `yaml:"ssl_mode"`
+
+	Replicas             []string      `yaml:"replicas"`               // DSN реплик PostgreSQL для запросов чтения
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval"` // Интервал проверки доступности реплик
+
+	MaxConns               int32         `yaml:"max_conns"`                // Максимум соединений пула PostgreSQL
+	MinConns               int32         `yaml:"min_conns"`                // Соединения, которые пул держит открытыми
+	StatementCacheCapacity int           `yaml:"statement_cache_capacity"` // Подготовленные запросы в кеше соединения
+	QueryTimeout           time.Duration `yaml:"query_timeout"`            // Ограничение времени запроса, 0 - без ограничения
 }
 
 type Rest struct {
-	Address string `yaml:"address"`
+	Address    string    `yaml:"address"`
+	AdminToken string    `yaml:"admin_token"` // Ключ с правом admin для выпуска первых API-ключей, пустой - отключен
+	RateLimit  RateLimit `yaml:"rate_limit"`
+}
+
+// RateLimit - ограничения клиентов API. Ключ ограничивается по наибольшему из своих прав.
+type RateLimit struct {
+	Anonymous    Limit         `yaml:"anonymous"` // Запросы без ключа, по IP-адресу
+	Read         Limit         `yaml:"read"`
+	Write        Limit         `yaml:"write"`
+	Admin        Limit         `yaml:"admin"`
+	SyncInterval time.Duration `yaml:"sync_interval"` // Как часто суточные квоты сохраняются в хранилище
+}
+
+type Limit struct {
+	Rate       float64 `yaml:"rate"`        // Запросов в секунду, 0 - без ограничения
+	Burst      int     `yaml:"burst"`       // Запросов подряд без пауз, по умолчанию - rate
+	DailyQuota int64   `yaml:"daily_quota"` // Запросов за сутки UTC, 0 - без квоты
+}
+
+type Aggregation struct {
+	Timezone string `yaml:"timezone"` // Часовой пояс календарных интервалов, по умолчанию UTC
 }
 
 func MustLoad() *Config {
@@ -53,6 +87,44 @@ func MustLoad() *Config {
 	if err != nil {
 		panic(fmt.Errorf("failed to decode config: %w", err))
 	}
+	if err := config.validate(); err != nil {
+		panic(fmt.Errorf("invalid config: %w", err))
+	}
 
 	return &config
 }
+
+// validate проверяет согласованность настроек
+func (c *Config) validate() error {
+	// Секции старше срока хранения удаляются вместе с тиками, поэтому при архивации срок хранения
+	// должен быть длиннее срока до архива даже для самых коротких месяцев
+	if c.Archive.MaxAgeDays > 0 && c.RetentionMonths > 0 && c.RetentionMonths*28 <= c.Archive.MaxAgeDays {
+		return fmt.Errorf("partitions.retention_months (%d) must be longer than archive.max_age_days (%d)",
+			c.RetentionMonths, c.Archive.MaxAgeDays)
+	}
+	return nil
+}
+
+type Partitions struct {
+	PremakeMonths   int           `yaml:"premake_months"`   // Сколько месячных секций создавать заранее
+	RetentionMonths int           `yaml:"retention_months"` // Срок хранения в месяцах, 0 - бессрочно
+	DropExpired     bool          `yaml:"drop_expired"`     // Удалять отсоединенные секции
+	CheckInterval   time.Duration `yaml:"check_interval"`
+}
+
+type Archive struct {
+	MaxAgeDays    int           `yaml:"max_age_days"` // Тики старше стольких суток переносятся в архив, 0 - архивация выключена
+	CheckInterval time.Duration `yaml:"check_interval"`
+	Store         string        `yaml:"store"` // file (по умолчанию) или s3
+	Path          string        `yaml:"path"`  // Каталог архива для store: file
+	S3            S3            `yaml:"s3"`
+}
+
+// S3 - бакет S3-совместимого хранилища архива
+type S3 struct {
+	Endpoint  string `yaml:"endpoint"` // Например, http://minio:9000
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+}
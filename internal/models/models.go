@@ -1,20 +1,69 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrDuplicate - запись нарушает ограничение уникальности хранилища.
+// Все хранилища оборачивают в нее ошибку драйвера, чтобы сервис отвечал конфликтом.
+var ErrDuplicate = errors.New("duplicate record")
+
+// ErrReferenced - нарушена ссылка между записями: удаляемая запись используется другими
+// (монета в списках пользователей) или добавляемая ссылается на уже удаленную
+var ErrReferenced = errors.New("record is referenced")
+
+// CoinStatus - состояние опроса монеты
+type CoinStatus string
+
+const (
+	CoinStatusActive   CoinStatus = "active"   // Цена опрашивается
+	CoinStatusPaused   CoinStatus = "paused"   // Опрос приостановлен, история сохраняется
+	CoinStatusDelisted CoinStatus = "delisted" // Монета снята с торгов
+
+	// CoinStatusUntracked - монета удалена из списка наблюдения, история сохраняется.
+	// Устанавливается только удалением монеты, а не изменением состояния.
+	CoinStatusUntracked CoinStatus = "untracked"
+)
+
+// Valid сообщает, является ли состояние допустимым для монеты в списке наблюдения
+func (s CoinStatus) Valid() bool {
+	switch s {
+	case CoinStatusActive, CoinStatusPaused, CoinStatusDelisted:
+		return true
+	}
+	return false
+}
 
 // TrackedCoin - отслеживаемая криптовалюта
 type TrackedCoin struct {
-	ID     int64  `json:"id"`
-	Symbol string `json:"symbol" validate:"required,alpha"` // Только буквы (BTC, ETH)
+	ID     int64      `json:"id"`
+	Symbol string     `json:"symbol" validate:"required,alpha"` // Только буквы (BTC, ETH)
+	Status CoinStatus `json:"status"`
+	CoinMetadata
+}
+
+// CoinMetadata - описание монеты, полученное от провайдера цен
+type CoinMetadata struct {
+	Name        string `json:"name"`
+	ProviderID  string `json:"provider_id"` // Идентификатор монеты у провайдера (bitcoin)
+	Category    string `json:"category"`
+	Description string `json:"description"`
+	LogoURL     string `json:"logo_url"`
+	Decimals    int    `json:"decimals"`
 }
 
 // CryptoPrice - цена криптовалюты в конкретный момент
 type CryptoPrice struct {
-	ID        int64   `json:"id"`
-	CoinID    int64   `json:"coin_id"`
-	Symbol    string  `json:"symbol"`
-	Price     float64 `json:"price"`
-	Timestamp int64   `json:"timestamp"`
+	ID        int64           `json:"id"`
+	CoinID    int64           `json:"coin_id"`
+	Symbol    string          `json:"symbol"`
+	Price     decimal.Decimal `json:"price"`
+	Timestamp Timestamp       `json:"timestamp"`
 }
 
 // AddCoinRequest - запрос на добавление монеты
@@ -22,25 +71,419 @@ type AddCoinRequest struct {
 	Coin string `json:"coin" validate:"required,alpha"`
 }
 
+// SetStatusRequest - запрос на изменение состояния опроса монеты
+type SetStatusRequest struct {
+	Coin   string     `json:"coin" validate:"required,alpha"`
+	Status CoinStatus `json:"status" validate:"required"`
+}
+
+// PurgeCoinResponse - результат безвозвратного удаления монеты
+type PurgeCoinResponse struct {
+	Coin          string `json:"coin"`
+	DeletedPrices int64  `json:"deleted_prices"`
+}
+
+// UpdateMetadataRequest - ручное изменение метаданных монеты; пустые поля не меняются
+type UpdateMetadataRequest struct {
+	Coin        string  `json:"coin" validate:"required,alpha"`
+	Name        *string `json:"name"`
+	Category    *string `json:"category"`
+	Description *string `json:"description"`
+	LogoURL     *string `json:"logo_url"`
+	Decimals    *int    `json:"decimals"`
+}
+
+// CorrectPriceRequest - ручная корректировка цены в конкретный момент
+type CorrectPriceRequest struct {
+	Coin      string          `json:"coin" validate:"required,alpha"`
+	Price     decimal.Decimal `json:"price" validate:"required"`
+	Timestamp Timestamp       `json:"timestamp" validate:"required"`
+}
+
 // GetPriceRequest - запрос на получение цены
 type GetPriceRequest struct {
-	Coin      string      `json:"coin" validate:"required,alpha"`
-	Timestamp json.Number `json:"timestamp" validate:"required"`
+	Coin      string    `json:"coin" validate:"required,alpha"`
+	Timestamp Timestamp `json:"timestamp" validate:"required"`
 }
 
 type GetPriceResponse struct {
-	Coin      string  `json:"coin"`
-	Price     float64 `json:"price"`
-	Timestamp int64   `json:"timestamp"`
+	Coin      string          `json:"coin"`
+	Price     decimal.Decimal `json:"price"`
+	Timestamp Timestamp       `json:"timestamp"`
+}
+
+// LatestPrice - отслеживаемая монета с последней известной ценой.
+// У монеты без истории цен Price и Timestamp равны null.
+type LatestPrice struct {
+	Coin      string           `json:"coin"`
+	Name      string           `json:"name"`
+	Status    CoinStatus       `json:"status"`
+	Price     *decimal.Decimal `json:"price"`
+	Timestamp *Timestamp       `json:"timestamp"`
+	Age       float64          `json:"age"` // Секунд с момента цены
+}
+
+// Problem - описание ошибки в ответе по RFC 7807 (application/problem+json)
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"` // Машиночитаемый код ошибки: not_found, invalid_input, ...
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // PriceResponse - ответ с ценой
 type PriceResponse struct {
-	Coin      string  `json:"coin"`
-	Price     float64 `json:"price"`
-	Timestamp int64   `json:"timestamp"`
+	Coin      string          `json:"coin"`
+	Price     decimal.Decimal `json:"price"`
+	Timestamp Timestamp       `json:"timestamp"`
 }
 
 type CoinData struct {
-	CurrentPrice float64 `json:"current_price"`
+	ID           string          `json:"id"`
+	Symbol       string          `json:"symbol"`
+	Name         string          `json:"name"`
+	Image        string          `json:"image"`
+	CurrentPrice decimal.Decimal `json:"current_price"`
+}
+
+// CoinDetails - подробное описание монеты у провайдера
+type CoinDetails struct {
+	ID          string   `json:"id"`
+	Categories  []string `json:"categories"`
+	Description struct {
+		En string `json:"en"`
+	} `json:"description"`
+	DetailPlatforms map[string]struct {
+		DecimalPlace *int `json:"decimal_place"`
+	} `json:"detail_platforms"`
+}
+
+// GetBucketsRequest - запрос на агрегацию цен по временным интервалам
+type GetBucketsRequest struct {
+	Coin     string    `json:"coin" validate:"required,alpha"`
+	From     Timestamp `json:"from" validate:"required"`
+	To       Timestamp `json:"to" validate:"required"`
+	Interval string    `json:"interval" validate:"required"` // 1m, 15m, 1h, 1d, 1w
+	Timezone string    `json:"timezone"`                     // IANA, например Europe/Moscow
+}
+
+// PriceBucket - агрегированные цены за один интервал
+type PriceBucket struct {
+	Start Timestamp       `json:"start"`
+	Open  decimal.Decimal `json:"open"`
+	High  decimal.Decimal `json:"high"`
+	Low   decimal.Decimal `json:"low"`
+	Close decimal.Decimal `json:"close"`
+	Mean  decimal.Decimal `json:"mean"`
+	Count int64           `json:"count"`
+}
+
+// BucketQuery - параметры агрегации, проверенные сервисом
+type BucketQuery struct {
+	Symbol   string
+	From     Timestamp
+	To       Timestamp
+	Interval time.Duration
+	Timezone string
+}
+
+type GetBucketsResponse struct {
+	Coin     string         `json:"coin"`
+	Interval string         `json:"interval"`
+	Timezone string         `json:"timezone"`
+	Buckets  []*PriceBucket `json:"buckets"`
+}
+
+// Number - точное десятичное число, которое в JSON пишется числом, а не строкой.
+// Маршруты, появившиеся до /v1, отдают цены числами, как до перехода на decimal.
+type Number decimal.Decimal
+
+func (n Number) MarshalJSON() ([]byte, error) {
+	return []byte(decimal.Decimal(n).String()), nil
+}
+
+// LegacyPriceResponse - цена в ответе маршрутов до /v1
+type LegacyPriceResponse struct {
+	Coin      string          `json:"coin"`
+	Price     Number          `json:"price"`
+	Timestamp LegacyTimestamp `json:"timestamp"`
+}
+
+// LegacyPriceBucket - агрегат цен в ответе /currency/aggregate
+type LegacyPriceBucket struct {
+	Start LegacyTimestamp `json:"start"`
+	Open  Number          `json:"open"`
+	High  Number          `json:"high"`
+	Low   Number          `json:"low"`
+	Close Number          `json:"close"`
+	Mean  Number          `json:"mean"`
+	Count int64           `json:"count"`
+}
+
+type LegacyBucketsResponse struct {
+	Coin     string               `json:"coin"`
+	Interval string               `json:"interval"`
+	Timezone string               `json:"timezone"`
+	Buckets  []*LegacyPriceBucket `json:"buckets"`
+}
+
+func NewLegacyPriceResponse(price *CryptoPrice) LegacyPriceResponse {
+	return LegacyPriceResponse{Coin: price.Symbol, Price: Number(price.Price), Timestamp: LegacyTimestamp(price.Timestamp)}
+}
+
+func NewLegacyBuckets(buckets []*PriceBucket) []*LegacyPriceBucket {
+	legacy := make([]*LegacyPriceBucket, len(buckets))
+	for i, b := range buckets {
+		legacy[i] = &LegacyPriceBucket{
+			Start: LegacyTimestamp(b.Start),
+			Open:  Number(b.Open),
+			High:  Number(b.High),
+			Low:   Number(b.Low),
+			Close: Number(b.Close),
+			Mean:  Number(b.Mean),
+			Count: b.Count,
+		}
+	}
+	return legacy
+}
+
+// Actor - инициатор изменения для журнала аудита
+type Actor struct {
+	Name      string // API-ключ или пользователь
+	RequestID string
+}
+
+// AuditAction - вид изменения в журнале аудита
+type AuditAction string
+
+const (
+	AuditCoinAdd      AuditAction = "coin.add"
+	AuditCoinRemove   AuditAction = "coin.remove"
+	AuditCoinStatus   AuditAction = "coin.status"
+	AuditCoinMetadata AuditAction = "coin.metadata"
+	AuditCoinPurge    AuditAction = "coin.purge"
+	AuditPriceCorrect AuditAction = "price.correct"
+)
+
+// AuditEntry - запись журнала аудита; before и after - состояние до и после изменения
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Action    AuditAction     `json:"action"`
+	CoinID    *int64          `json:"coin_id,omitempty"`
+	Symbol    string          `json:"symbol"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	Timestamp Timestamp       `json:"timestamp"`
+}
+
+// AuditFilter - параметры выборки журнала аудита; пустые поля не фильтруют
+type AuditFilter struct {
+	Coin  string
+	Actor string
+	From  Timestamp
+	To    Timestamp
+	Limit int
+}
+
+// PoolStats - состояние пула соединений хранилища
+type PoolStats struct {
+	MaxConns       int32        `json:"max_conns"`
+	TotalConns     int32        `json:"total_conns"`
+	IdleConns      int32        `json:"idle_conns"`
+	AcquiredConns  int32        `json:"acquired_conns"`
+	WaitCount      int64        `json:"wait_count"` // получения соединения, ждавшие свободного
+	WaitDurationMs int64        `json:"wait_duration_ms"`
+	CanceledCount  int64        `json:"canceled_count"` // получения, прерванные отменой запроса
+	Replicas       []*PoolStats `json:"replicas,omitempty"`
+}
+
+// Scope - право доступа API-ключа. Права вложены: write включает read, admin - write.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"  // Чтение монет, цен и истории
+	ScopeWrite Scope = "write" // Изменение списка наблюдения
+	ScopeAdmin Scope = "admin" // Административные маршруты и управление ключами
+)
+
+var scopeLevels = map[Scope]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+func (s Scope) Valid() bool {
+	_, ok := scopeLevels[s]
+	return ok
+}
+
+// Includes сообщает, дает ли право s доступ, требующий права required
+func (s Scope) Includes(required Scope) bool {
+	return s.Valid() && scopeLevels[s] >= scopeLevels[required]
+}
+
+// APIKey - API-ключ. Сам ключ не хранится: в хранилище лежит его хеш,
+// а Prefix - начало ключа, по которому ключ узнают в списке.
+type APIKey struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []Scope    `json:"scopes"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt Timestamp  `json:"created_at"`
+	RevokedBy string     `json:"revoked_by,omitempty"`
+	RevokedAt *Timestamp `json:"revoked_at,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string  `json:"name" validate:"required"`
+	Scopes []Scope `json:"scopes" validate:"required"`
+}
+
+// CreateAPIKeyResponse - созданный ключ; Key возвращается только один раз
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// Principal - аутентифицированный инициатор запроса
+type Principal struct {
+	Name   string
+	KeyID  int64 // 0 - вход по admin_token из конфигурации
+	Scopes []Scope
+}
+
+// Allows сообщает, есть ли у инициатора право scope
+func (p *Principal) Allows(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s.Includes(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watchlist - именованный список наблюдения пользователя. Монеты списка берутся
+// из общего справочника и опрашиваются, пока есть хотя бы в одном списке.
+type Watchlist struct {
+	ID         int64          `json:"id"`
+	Owner      string         `json:"owner"`
+	Name       string         `json:"name"`
+	SharedWith []string       `json:"shared_with"` // Пользователи, которые видят список
+	CreatedAt  Timestamp      `json:"created_at"`
+	Coins      []*TrackedCoin `json:"-"`
+}
+
+// WatchlistResponse - список наблюдения с последними ценами его монет
+type WatchlistResponse struct {
+	Watchlist
+	Coins []*LatestPrice `json:"coins"`
+}
+
+// WatchlistRequest - создание или переименование списка наблюдения
+type WatchlistRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// AlertCondition - условие правила оповещения о цене
+type AlertCondition string
+
+const (
+	AlertPriceAbove   AlertCondition = "price_above"    // Цена не ниже порога
+	AlertPriceBelow   AlertCondition = "price_below"    // Цена не выше порога
+	AlertChangeAbove  AlertCondition = "change_above"   // Рост цены за окно, %, не меньше порога
+	AlertChangeBelow  AlertCondition = "change_below"   // Изменение цены за окно, %, не больше отрицательного порога
+	AlertCrossAboveMA AlertCondition = "cross_above_ma" // Цена пересекла скользящее среднее за окно снизу вверх
+	AlertCrossBelowMA AlertCondition = "cross_below_ma" // Цена пересекла скользящее среднее за окно сверху вниз
+)
+
+// Valid сообщает, известно ли условие
+func (c AlertCondition) Valid() bool {
+	switch c {
+	case AlertPriceAbove, AlertPriceBelow, AlertChangeAbove, AlertChangeBelow, AlertCrossAboveMA, AlertCrossBelowMA:
+		return true
+	}
+	return false
+}
+
+// Windowed сообщает, вычисляется ли условие по ценам за окно
+func (c AlertCondition) Windowed() bool {
+	return c != AlertPriceAbove && c != AlertPriceBelow
+}
+
+// AlertState - состояние правила оповещения
+type AlertState string
+
+const (
+	AlertArmed       AlertState = "armed"        // Сработает при выполнении условия
+	AlertFired       AlertState = "fired"        // Сработало; ждет, пока условие перестанет выполняться с учетом гистерезиса
+	AlertCoolingDown AlertState = "cooling_down" // Условие сброшено, правило взведется после паузы cooldown от срабатывания
+)
+
+// Duration - длительность, которая выводится в JSON в записи интервалов: 30s, 15m, 1h, 1d, 1w
+type Duration time.Duration
+
+func (d Duration) String() string {
+	for _, unit := range []struct {
+		suffix string
+		length time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}} {
+		if time.Duration(d)%unit.length == 0 && d != 0 {
+			return strconv.FormatInt(int64(time.Duration(d)/unit.length), 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(int64(time.Duration(d)/time.Second), 10) + "s"
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// AlertRule - правило оповещения пользователя о цене монеты
+type AlertRule struct {
+	ID             int64           `json:"id"`
+	Owner          string          `json:"owner"`
+	CoinID         int64           `json:"-"`
+	Coin           string          `json:"coin"`
+	Condition      AlertCondition  `json:"condition"`
+	Threshold      decimal.Decimal `json:"threshold"`        // Цена или процент изменения; для пересечения среднего не используется
+	Window         Duration        `json:"window,omitempty"` // Окно изменения цены или скользящего среднего
+	Hysteresis     decimal.Decimal `json:"hysteresis"`       // Запас сброса условия: % от порога или среднего, для изменения - процентные пункты
+	Cooldown       Duration        `json:"cooldown"`         // Минимальная пауза между срабатываниями
+	State          AlertState      `json:"state"`            // Состояние правила
+	StateChangedAt Timestamp       `json:"state_changed_at"` // Момент тика, изменившего состояние
+	LastFiredAt    *Timestamp      `json:"last_fired_at"`    // Момент последнего срабатывания
+	CreatedAt      Timestamp       `json:"created_at"`
+	Version        int64           `json:"-"` // Счетчик изменений состояния для условного обновления
+}
+
+// CreateAlertRuleRequest - создание правила оповещения
+type CreateAlertRuleRequest struct {
+	Coin       string           `json:"coin" validate:"required,alpha"`
+	Condition  AlertCondition   `json:"condition" validate:"required"`
+	Threshold  *decimal.Decimal `json:"threshold"`
+	Window     string           `json:"window"`     // 15m, 1h, 1d; обязательно для изменения и пересечения среднего
+	Hysteresis *decimal.Decimal `json:"hysteresis"` // По умолчанию 1
+	Cooldown   string           `json:"cooldown"`   // По умолчанию 15m
+}
+
+// AlertEvent - срабатывание правила оповещения
+type AlertEvent struct {
+	ID        int64           `json:"id"`
+	RuleID    int64           `json:"rule_id"`
+	Coin      string          `json:"coin"`
+	Condition AlertCondition  `json:"condition"`
+	Price     decimal.Decimal `json:"price"`    // Цена тика, на котором правило сработало
+	Value     decimal.Decimal `json:"value"`    // Значение условия: цена, изменение в % или скользящее среднее
+	FiredAt   Timestamp       `json:"fired_at"` // Момент тика
+}
+
+// AlertEventFilter - выборка истории срабатываний правил пользователя
+type AlertEventFilter struct {
+	Owner  string
+	RuleID int64
+	From   Timestamp
+	To     Timestamp
+	Limit  int
 }
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Timestamp - момент времени в Unix-миллисекундах.
+// В JSON и параметрах запроса принимается Unix-время в секундах (целое или дробное)
+// либо строка RFC 3339; точность сверх миллисекунды отбрасывается.
+// Выводится числом секунд, дробная часть - миллисекунды: 1736500490.123.
+type Timestamp int64
+
+// TimestampOf возвращает момент t с точностью до миллисекунды
+func TimestampOf(t time.Time) Timestamp {
+	return Timestamp(t.UnixMilli())
+}
+
+// UnixTimestamp возвращает момент, заданный Unix-временем в секундах
+func UnixTimestamp(sec int64) Timestamp {
+	return Timestamp(sec * 1000)
+}
+
+// Time возвращает момент в UTC
+func (t Timestamp) Time() time.Time {
+	return time.UnixMilli(int64(t)).UTC()
+}
+
+// String возвращает Unix-время в секундах с дробной частью миллисекунд
+func (t Timestamp) String() string {
+	return decimal.New(int64(t), -3).String()
+}
+
+// ParseTimestamp разбирает Unix-время в секундах или строку RFC 3339
+func ParseTimestamp(s string) (Timestamp, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty timestamp")
+	}
+	if seconds, err := decimal.NewFromString(s); err == nil {
+		ms := seconds.Shift(3).Truncate(0)
+		if !ms.BigInt().IsInt64() {
+			return 0, fmt.Errorf("timestamp %s is out of range", s)
+		}
+		return Timestamp(ms.IntPart()), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q: expected Unix seconds or RFC 3339", s)
+	}
+	return TimestampOf(t), nil
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	s := string(data)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseTimestamp(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// LegacyTimestamp - момент в ответах маршрутов до /v1, которые отдают целое число
+// Unix-секунд, как до перехода на миллисекунды; миллисекунды отбрасываются.
+type LegacyTimestamp Timestamp
+
+func (t LegacyTimestamp) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, Timestamp(t).Time().Unix(), 10), nil
+}
+
+// Scan читает момент из Unix-миллисекунд или значения времени базы данных
+func (t *Timestamp) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*t = Timestamp(v)
+	case time.Time:
+		*t = TimestampOf(v)
+	case []byte:
+		return t.Scan(string(v))
+	case string:
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q: %w", v, err)
+		}
+		*t = Timestamp(ms)
+	default:
+		return fmt.Errorf("cannot scan %T into timestamp", src)
+	}
+	return nil
+}
+
+// Value записывает момент в Unix-миллисекундах
+func (t Timestamp) Value() (driver.Value, error) {
+	return int64(t), nil
+}
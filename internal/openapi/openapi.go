@@ -0,0 +1,93 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Spec - документ OpenAPI 3 со всеми маршрутами сервиса, отдается по /openapi.json
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Docs - страница документации, которая читает Spec и показывает маршруты и схемы
+//
+//go:embed docs.html
+var Docs []byte
+
+// document - часть документа, нужная для проверки тел запросов
+type document struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+var spec = mustParse(Spec)
+
+// parse разбирает документ и подставляет схемы по ссылкам
+func parse(data []byte) (*document, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse openapi document: %w", err)
+	}
+	for name, schema := range doc.Components.Schemas {
+		if err := schema.resolve(doc.Components.Schemas); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for path, operations := range doc.Paths {
+		for method, op := range operations {
+			if schema := op.bodySchema(); schema != nil {
+				// Обертка подставляет и ссылку, которой задана сама схема тела
+				if err := (&Schema{Items: schema}).resolve(doc.Components.Schemas); err != nil {
+					return nil, fmt.Errorf("%s %s: %w", method, path, err)
+				}
+			}
+		}
+	}
+	return &doc, nil
+}
+
+func mustParse(data []byte) *document {
+	doc, err := parse(data)
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+func (op *operation) bodySchema() *Schema {
+	if op == nil || op.RequestBody == nil {
+		return nil
+	}
+	return op.RequestBody.Content["application/json"].Schema
+}
+
+// ValidateRequest проверяет тело запроса к маршруту path (шаблон из документа,
+// например /v1/coins/{symbol}) по схеме requestBody. Маршруты без тела в документе
+// не проверяются. Несоответствие схеме возвращается как *ValidationError.
+func ValidateRequest(method, path string, body []byte) error {
+	schema := spec.Paths[path][strings.ToLower(method)].bodySchema()
+	if schema == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return schema.Validate(value)
+}
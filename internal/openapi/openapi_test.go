@@ -0,0 +1,85 @@
+package openapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parse(Spec)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, path := range []string{"/currency/add", "/currency/get", "/currency/aggregate", "/currency/status", "/currency/metadata", "/admin/currency/purge", "/admin/currency/price", "/admin/keys", "/v1/watchlists", "/v1/alerts"} {
+		if doc.Paths[path]["post"].bodySchema() == nil {
+			t.Errorf("POST %s has no request body schema", path)
+		}
+	}
+	if doc.Paths["/v1/watchlists/{id}"]["patch"].bodySchema() == nil {
+		t.Errorf("PATCH /v1/watchlists/{id} has no request body schema")
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		path  string
+		body  string
+		field string // Поле с ошибкой, "." - тело целиком; пустое - тело корректно
+	}{
+		{"/currency/add", `{"coin": "BTC"}`, ""},
+		{"/currency/add", `{}`, "coin"},
+		{"/currency/add", `{"coin": "BTC1"}`, "coin"},
+		{"/currency/add", `{"coin": "BTC", "extra": 1}`, "extra"},
+		{"/currency/add", `["BTC"]`, "."},
+		{"/currency/get", `{"coin": "BTC", "timestamp": 1736500490.123}`, ""},
+		{"/currency/get", `{"coin": "BTC", "timestamp": "2025-01-10T09:14:50.123Z"}`, ""},
+		{"/currency/get", `{"coin": "BTC", "timestamp": true}`, "timestamp"},
+		{"/currency/get", `{"coin": "BTC", "timestamp": -1}`, "timestamp"},
+		{"/admin/currency/price", `{"coin": "BTC", "price": "94512.170000000000000001", "timestamp": 1736500490}`, ""},
+		{"/admin/currency/price", `{"coin": "BTC", "price": 94512.17, "timestamp": 1736500490}`, ""},
+		{"/admin/currency/price", `{"coin": "BTC", "price": "1e3", "timestamp": 1736500490}`, "price"},
+		{"/currency/status", `{"coin": "BTC", "status": "paused"}`, ""},
+		{"/currency/status", `{"coin": "BTC", "status": "untracked"}`, "status"},
+		{"/currency/metadata", `{"coin": "BTC", "name": null, "decimals": 8}`, ""},
+		{"/currency/metadata", `{"coin": "BTC", "decimals": 1.5}`, "decimals"},
+		{"/currency/metadata", `{"coin": "BTC", "decimals": 37}`, "decimals"},
+		{"/currency/aggregate", `{"coin": "BTC", "from": 1736380800, "to": 1736467200, "interval": "1h"}`, ""},
+		{"/currency/aggregate", `{"coin": "BTC", "from": 1736380800, "to": 1736467200, "interval": "1y"}`, "interval"},
+		{"/currency/aggregate", `{"coin": "BTC", "from": 1736380800, "interval": "1h"}`, "to"},
+		{"/admin/keys", `{"name": "grafana", "scopes": ["read"]}`, ""},
+		{"/admin/keys", `{"name": "", "scopes": ["read"]}`, "name"},
+		{"/admin/keys", `{"name": "grafana", "scopes": ["root"]}`, "scopes[0]"},
+		{"/v1/watchlists", `{"name": "defi"}`, ""},
+		{"/v1/watchlists", `{"name": ""}`, "name"},
+		{"/v1/watchlists", `{"name": "defi", "coins": ["BTC"]}`, "coins"},
+		{"/v1/alerts", `{"coin": "BTC", "condition": "price_above", "threshold": "100000", "hysteresis": 0.5, "cooldown": "1h"}`, ""},
+		{"/v1/alerts", `{"coin": "ETH", "condition": "cross_below_ma", "window": "1d"}`, ""},
+		{"/v1/alerts", `{"coin": "BTC", "condition": "price_between"}`, "condition"},
+		{"/v1/alerts", `{"coin": "BTC", "condition": "change_above", "threshold": 5, "window": "1y"}`, "window"},
+		{"/v1/alerts", `{"condition": "price_above", "threshold": 1}`, "coin"},
+	}
+	for _, tt := range tests {
+		err := ValidateRequest("POST", tt.path, []byte(tt.body))
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("%s %s: unexpected error %v", tt.path, tt.body, err)
+			}
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != strings.TrimPrefix(tt.field, ".") {
+			t.Errorf("%s %s: error = %v, want validation error of %s", tt.path, tt.body, err, tt.field)
+		}
+	}
+
+	if err := ValidateRequest("POST", "/currency/add", []byte(`{"coin":`)); err == nil {
+		t.Errorf("truncated JSON accepted")
+	}
+	if err := ValidateRequest("PATCH", "/v1/watchlists/{id}", []byte(`{}`)); err == nil {
+		t.Errorf("rename without a name accepted")
+	}
+	if err := ValidateRequest("GET", "/v1/coins", nil); err != nil {
+		t.Errorf("route without request body: %v", err)
+	}
+}
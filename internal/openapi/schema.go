@@ -0,0 +1,206 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Schema - подмножество Schema Object OpenAPI 3.0, которое используется в документе
+// и проверяется у тел запросов. format, description и example не проверяются.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Nullable             bool               `json:"nullable"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	OneOf                []*Schema          `json:"oneOf"`
+	Enum                 []any              `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+
+	pattern *regexp.Regexp
+}
+
+// ValidationError - значение в теле запроса не соответствует схеме
+type ValidationError struct {
+	Field   string // Путь к значению: price, items[2].coin; пустой - тело целиком
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// resolve подставляет схемы по ссылкам $ref и компилирует шаблоны
+func (s *Schema) resolve(schemas map[string]*Schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Pattern != "" && s.pattern == nil {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+	for _, child := range s.children() {
+		if child.Ref != "" {
+			name, ok := strings.CutPrefix(child.Ref, "#/components/schemas/")
+			target := schemas[name]
+			if !ok || target == nil {
+				return fmt.Errorf("unresolved reference %s", child.Ref)
+			}
+			*child = *target
+		}
+		if err := child.resolve(schemas); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) children() []*Schema {
+	children := slices.Collect(maps.Values(s.Properties))
+	if s.Items != nil {
+		children = append(children, s.Items)
+	}
+	return append(children, s.OneOf...)
+}
+
+// Validate проверяет значение, разобранное json.Decoder с UseNumber
+func (s *Schema) Validate(value any) error {
+	return s.validate(value, "")
+}
+
+func (s *Schema) validate(value any, field string) error {
+	fail := func(format string, args ...any) error {
+		return &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)}
+	}
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return fail("must not be null")
+	}
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, option := range s.OneOf {
+			if option.validate(value, field) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fail("must be %s", s.describe())
+		}
+		return nil
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return &ValidationError{Field: join(field, name), Message: "is required"}
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(object)) {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return &ValidationError{Field: join(field, name), Message: "unknown field"}
+				}
+				continue
+			}
+			if err := property.validate(object[name], join(field, name)); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return fail("must be an array")
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		length := len([]rune(str))
+		if s.MinLength != nil && length < *s.MinLength {
+			return fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			return fail("must match %s", s.Pattern)
+		}
+	case "number", "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fail("must be a %s", s.Type)
+		}
+		if s.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				return fail("must be an integer")
+			}
+		}
+		f, err := number.Float64()
+		if err != nil {
+			return fail("must be a %s", s.Type)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fail("must be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(allowed any) bool { return fmt.Sprint(allowed) == fmt.Sprint(value) }) {
+		return fail("must be one of %v", s.Enum)
+	}
+	return nil
+}
+
+// describe перечисляет типы вариантов oneOf для сообщения об ошибке
+func (s *Schema) describe() string {
+	types := make([]string, 0, len(s.OneOf))
+	for _, option := range s.OneOf {
+		types = append(types, option.Type)
+	}
+	return strings.Join(types, " or ")
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
@@ -0,0 +1,245 @@
+// Package ratelimit ограничивает частоту запросов клиентов к API корзиной токенов
+// и считает суточные квоты запросов, общие для всех экземпляров сервиса.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval - как часто из памяти убираются корзины клиентов, которые успели наполниться
+const sweepInterval = time.Minute
+
+// Limit - ограничение клиента
+type Limit struct {
+	Rate       float64 // Пополнение корзины, запросов в секунду; 0 - частота не ограничена
+	Burst      int     // Емкость корзины: столько запросов подряд проходит без пауз
+	DailyQuota int64   // Запросов за сутки UTC; 0 - без квоты
+}
+
+// Decision - ответ на запрос клиента и значения заголовков RateLimit-*
+type Decision struct {
+	Allowed    bool
+	Limited    bool          // Клиент ограничен; без ограничений заголовки не отдаются
+	Quota      bool          // Limit, Remaining и Reset относятся к суточной квоте, а не к корзине
+	Limit      int64         // Емкость корзины или суточная квота
+	Remaining  int64         // Запросов, которые пройдут сразу
+	Reset      time.Duration // До полного наполнения корзины или до начала следующих суток
+	RetryAfter time.Duration // Через сколько повторить отклоненный запрос
+}
+
+// quotaStore хранит суточный учет запросов всех экземпляров сервиса
+type quotaStore interface {
+	AddQuotaUsage(ctx context.Context, day string, usage map[string]int64) error
+	GetQuotaUsage(ctx context.Context, day string) (map[string]int64, error)
+	DeleteQuotaUsageBefore(ctx context.Context, day string) (int64, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// Limiter ведет корзины токенов клиентов в памяти экземпляра и суточные квоты.
+// Запросы квоты копятся в памяти и сохраняются в хранилище при Sync; после Sync
+// учет включает запросы остальных экземпляров. Между синхронизациями квота может
+// быть превышена на запросы, сделанные через другие экземпляры.
+type Limiter struct {
+	store quotaStore
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	swept     time.Time
+	day       string           // Текущие сутки учета квот, YYYY-MM-DD
+	used      map[string]int64 // Запросы за сутки по хранилищу и уже отправленные в него
+	pending   map[string]int64 // Запросы этого экземпляра, еще не сохраненные
+	prunedDay string           // Сутки, до которых учет в хранилище уже удален
+}
+
+// New создает ограничитель; store - хранилище квот, nil - квоты считаются только в памяти
+func New(store quotaStore) *Limiter {
+	return &Limiter{
+		store:   store,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+		used:    make(map[string]int64),
+		pending: make(map[string]int64),
+	}
+}
+
+// Allow решает, пропустить ли запрос клиента client с ограничением limit.
+// Пропущенный запрос расходует токен корзины и единицу суточной квоты.
+func (l *Limiter) Allow(client string, limit Limit) Decision {
+	return l.decide(client, limit, true)
+}
+
+// Peek возвращает решение, которое принял бы Allow, не расходуя ни токен, ни квоту
+func (l *Limiter) Peek(client string, limit Limit) Decision {
+	return l.decide(client, limit, false)
+}
+
+func (l *Limiter) decide(client string, limit Limit, spend bool) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	var decision Decision
+
+	var quotaUsed int64
+	if limit.DailyQuota > 0 {
+		l.rollover(now)
+		quotaUsed = l.used[client] + l.pending[client]
+		if quotaUsed >= limit.DailyQuota {
+			untilTomorrow := nextDay(now).Sub(now)
+			return Decision{
+				Limited:    true,
+				Quota:      true,
+				Limit:      limit.DailyQuota,
+				Reset:      untilTomorrow,
+				RetryAfter: untilTomorrow,
+			}
+		}
+	}
+
+	if limit.Rate > 0 {
+		b := l.buckets[client]
+		if b == nil || b.limit != limit {
+			b = &bucket{tokens: float64(limit.Burst), updated: now, limit: limit}
+			if spend {
+				l.buckets[client] = b
+			}
+		}
+		b.refill(now)
+		decision = Decision{Limited: true, Limit: int64(limit.Burst)}
+		if b.tokens < 1 {
+			decision.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+			decision.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+			return decision
+		}
+		tokens := b.tokens - 1
+		if spend {
+			b.tokens = tokens
+		}
+		decision.Remaining = int64(tokens)
+		decision.Reset = seconds((float64(limit.Burst) - tokens) / limit.Rate)
+	}
+
+	decision.Allowed = true
+	if limit.DailyQuota > 0 {
+		if spend {
+			l.pending[client]++
+		}
+		// Заголовки сообщают о том ограничении, которое наступит раньше
+		if remaining := limit.DailyQuota - quotaUsed - 1; !decision.Limited || remaining < decision.Remaining {
+			decision = Decision{
+				Allowed:   true,
+				Limited:   true,
+				Quota:     true,
+				Limit:     limit.DailyQuota,
+				Remaining: remaining,
+				Reset:     nextDay(now).Sub(now),
+			}
+		}
+	}
+	return decision
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	}
+	b.updated = now
+}
+
+// sweep убирает наполнившиеся корзины: новая корзина клиента будет такой же полной
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+	for client, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// rollover начинает учет новых суток. Запросы прошедших суток, не сохраненные
+// до полуночи, не переносятся: квота новых суток начинается с нуля.
+func (l *Limiter) rollover(now time.Time) {
+	day := now.UTC().Format(time.DateOnly)
+	if day == l.day {
+		return
+	}
+	l.day = day
+	clear(l.used)
+	clear(l.pending)
+}
+
+// Sync сохраняет накопленные запросы квот и загружает учет всех экземпляров за текущие сутки.
+// Раз в сутки удаляет из хранилища учет прошедших дней.
+func (l *Limiter) Sync(ctx context.Context) error {
+	if l.store == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	l.rollover(l.now())
+	day := l.day
+	pending := l.pending
+	l.pending = make(map[string]int64)
+	// Отправленные запросы учитываются сразу, до ответа хранилища
+	for client, requests := range pending {
+		l.used[client] += requests
+	}
+	prune := l.prunedDay != day
+	l.mu.Unlock()
+
+	if err := l.store.AddQuotaUsage(ctx, day, pending); err != nil {
+		l.mu.Lock()
+		if l.day == day {
+			for client, requests := range pending {
+				l.used[client] -= requests
+				l.pending[client] += requests
+			}
+		}
+		l.mu.Unlock()
+		return fmt.Errorf("failed to save quota usage: %w", err)
+	}
+
+	usage, err := l.store.GetQuotaUsage(ctx, day)
+	if err != nil {
+		return fmt.Errorf("failed to load quota usage: %w", err)
+	}
+	l.mu.Lock()
+	if l.day == day {
+		l.used = usage
+	}
+	l.mu.Unlock()
+
+	if prune {
+		if _, err := l.store.DeleteQuotaUsageBefore(ctx, day); err != nil {
+			return fmt.Errorf("failed to prune quota usage: %w", err)
+		}
+		l.mu.Lock()
+		l.prunedDay = day
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+func nextDay(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
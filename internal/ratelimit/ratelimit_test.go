@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"awesomeProject/internal/repository"
+	"context"
+	"testing"
+	"time"
+)
+
+// clock - управляемое время ограничителя
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time          { return c.now }
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newLimiter(store quotaStore, start time.Time) (*Limiter, *clock) {
+	c := &clock{now: start}
+	l := New(store)
+	l.now = c.Now
+	return l, c
+}
+
+var noon = time.Date(2023, 11, 14, 12, 0, 0, 0, time.UTC)
+
+func TestTokenBucket(t *testing.T) {
+	l, c := newLimiter(nil, noon)
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for i := range 3 {
+		d := l.Allow("key:1", limit)
+		if !d.Allowed || d.Remaining != int64(2-i) || d.Limit != 3 {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i, d, 2-i)
+		}
+	}
+	d := l.Allow("key:1", limit)
+	if d.Allowed || d.Quota || d.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("over burst = %+v, want rejected with retry after 500ms", d)
+	}
+	if d := l.Allow("key:2", limit); !d.Allowed {
+		t.Errorf("another client = %+v, want allowed", d)
+	}
+
+	c.Advance(500 * time.Millisecond)
+	if d := l.Allow("key:1", limit); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("after refill = %+v, want allowed with 0 remaining", d)
+	}
+	c.Advance(10 * time.Second)
+	if d := l.Allow("key:1", limit); !d.Allowed || d.Remaining != 2 {
+		t.Errorf("after full refill = %+v, want burst restored", d)
+	}
+
+	if d := l.Allow("key:1", Limit{}); !d.Allowed || d.Limited {
+		t.Errorf("without limit = %+v, want allowed and not limited", d)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	l, _ := newLimiter(nil, noon)
+	limit := Limit{Rate: 1, Burst: 2, DailyQuota: 10}
+
+	for range 3 {
+		if d := l.Peek("ip:10.0.0.1", limit); !d.Allowed {
+			t.Fatalf("peek = %+v, want allowed", d)
+		}
+	}
+	l.Allow("ip:10.0.0.1", limit)
+	if d := l.Peek("ip:10.0.0.1", limit); !d.Allowed || d.Remaining != 0 {
+		t.Fatalf("peek after one request = %+v, want allowed with 0 remaining", d)
+	}
+	l.Allow("ip:10.0.0.1", limit)
+	if d := l.Peek("ip:10.0.0.1", limit); d.Allowed || d.RetryAfter != time.Second {
+		t.Errorf("peek over burst = %+v, want rejected with retry after 1s", d)
+	}
+}
+
+func TestDailyQuota(t *testing.T) {
+	l, c := newLimiter(nil, noon)
+	limit := Limit{DailyQuota: 2}
+
+	for i := range 2 {
+		if d := l.Allow("ip:10.0.0.1", limit); !d.Allowed || !d.Quota || d.Remaining != int64(1-i) {
+			t.Fatalf("request %d = %+v, want allowed by quota", i, d)
+		}
+	}
+	d := l.Allow("ip:10.0.0.1", limit)
+	if d.Allowed || !d.Quota || d.RetryAfter != 12*time.Hour {
+		t.Fatalf("over quota = %+v, want rejected until midnight", d)
+	}
+
+	c.Advance(12 * time.Hour)
+	if d := l.Allow("ip:10.0.0.1", limit); !d.Allowed {
+		t.Errorf("next day = %+v, want allowed", d)
+	}
+}
+
+func TestQuotaSync(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	if err := repo.AddQuotaUsage(ctx, "2023-11-13", map[string]int64{"key:1": 100}); err != nil {
+		t.Fatal(err)
+	}
+	limit := Limit{DailyQuota: 5}
+
+	first, _ := newLimiter(repo, noon)
+	second, _ := newLimiter(repo, noon)
+	for range 3 {
+		first.Allow("key:1", limit)
+	}
+	if err := first.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if usage, _ := repo.GetQuotaUsage(ctx, "2023-11-14"); usage["key:1"] != 3 {
+		t.Errorf("saved usage = %v, want key:1=3", usage)
+	}
+	if usage, _ := repo.GetQuotaUsage(ctx, "2023-11-13"); len(usage) != 0 {
+		t.Errorf("usage of the previous day = %v, want pruned", usage)
+	}
+
+	// Второй экземпляр или перезапуск видит запросы первого после синхронизации
+	if err := second.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	for i := range 2 {
+		if d := second.Allow("key:1", limit); !d.Allowed {
+			t.Fatalf("request %d = %+v, want allowed", i, d)
+		}
+	}
+	if d := second.Allow("key:1", limit); d.Allowed {
+		t.Errorf("request over the shared quota = %+v, want rejected", d)
+	}
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultAlertEventsLimit ограничивает выборку истории срабатываний без явного limit
+const defaultAlertEventsLimit = 100
+
+// alertDialect - особенности SQL правил оповещения в диалекте хранилища
+type alertDialect struct {
+	param     func(n int) string         // n-й параметр запроса
+	timeArg   func(models.Timestamp) any // значение параметра для колонок времени
+	returning bool                       // INSERT возвращает id через RETURNING, иначе LastInsertId
+}
+
+var postgresAlerts = alertDialect{
+	param:     numberedParam,
+	timeArg:   func(ts models.Timestamp) any { return ts.Time() },
+	returning: true,
+}
+
+// alertRuleColumns - колонки правила с символом монеты в порядке полей scanAlertRule
+const alertRuleColumns = `r.id, r.owner, r.coin_id, c.symbol, r.kind, r.threshold, r.window_ms, r.hysteresis,
+        r.cooldown_ms, r.state, r.state_changed_at, r.last_fired_at, r.created_at, r.version`
+
+const alertRulesFrom = ` FROM alert_rules r JOIN tracked_coins c ON c.id = r.coin_id`
+
+func scanAlertRule(row rowScanner) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	var window, cooldown int64
+	if err := row.Scan(
+		&rule.ID,
+		&rule.Owner,
+		&rule.CoinID,
+		&rule.Coin,
+		&rule.Condition,
+		&rule.Threshold,
+		&window,
+		&rule.Hysteresis,
+		&cooldown,
+		&rule.State,
+		&rule.StateChangedAt,
+		&rule.LastFiredAt,
+		&rule.CreatedAt,
+		&rule.Version,
+	); err != nil {
+		return nil, err
+	}
+	rule.Window = models.Duration(time.Duration(window) * time.Millisecond)
+	rule.Cooldown = models.Duration(time.Duration(cooldown) * time.Millisecond)
+	return &rule, nil
+}
+
+// durationMillis переводит длительность в миллисекунды для колонок *_ms
+func durationMillis(d models.Duration) int64 {
+	return time.Duration(d).Milliseconds()
+}
+
+func insertAlertRule(ctx context.Context, db *sql.DB, log *zap.Logger, dialect alertDialect, rule *models.AlertRule) error {
+	p := dialect.param
+	query := `
+        INSERT INTO alert_rules (owner, coin_id, kind, threshold, window_ms, hysteresis, cooldown_ms,
+                                 state, state_changed_at, version, created_at)
+        VALUES (` + p(1) + `, ` + p(2) + `, ` + p(3) + `, ` + p(4) + `, ` + p(5) + `, ` + p(6) + `, ` + p(7) + `, ` +
+		p(8) + `, ` + p(9) + `, 1, ` + p(10) + `)`
+	args := []any{
+		rule.Owner,
+		rule.CoinID,
+		rule.Condition,
+		rule.Threshold,
+		durationMillis(rule.Window),
+		rule.Hysteresis,
+		durationMillis(rule.Cooldown),
+		rule.State,
+		dialect.timeArg(rule.StateChangedAt),
+		dialect.timeArg(rule.CreatedAt),
+	}
+
+	var err error
+	if dialect.returning {
+		err = db.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&rule.ID)
+	} else {
+		var res sql.Result
+		if res, err = db.ExecContext(ctx, query, args...); err == nil {
+			rule.ID, err = res.LastInsertId()
+		}
+	}
+	if err != nil {
+		log.Error("Failed to create alert rule", zap.Error(err), zap.String("owner", rule.Owner), zap.String("coin", rule.Coin))
+		return fmt.Errorf("failed to create alert rule: %w", err)
+	}
+	rule.Version = 1
+	return nil
+}
+
+func queryAlertRule(ctx context.Context, db *sql.DB, dialect alertDialect, id int64) (*models.AlertRule, error) {
+	rule, err := scanAlertRule(db.QueryRowContext(ctx, `SELECT `+alertRuleColumns+alertRulesFrom+` WHERE r.id = `+dialect.param(1), id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert rule: %w", err)
+	}
+	return rule, nil
+}
+
+// queryAlertRules возвращает правила владельца owner, а при пустом owner - все правила, в порядке создания
+func queryAlertRules(ctx context.Context, db *sql.DB, log *zap.Logger, dialect alertDialect, owner string) ([]*models.AlertRule, error) {
+	query := `SELECT ` + alertRuleColumns + alertRulesFrom
+	var args []any
+	if owner != "" {
+		query += ` WHERE r.owner = ` + dialect.param(1)
+		args = append(args, owner)
+	}
+	rows, err := db.QueryContext(ctx, query+` ORDER BY r.id`, args...)
+	if err != nil {
+		log.Error("Failed to list alert rules", zap.Error(err), zap.String("owner", owner))
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*models.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return rules, nil
+}
+
+// deleteAlertRule удаляет правило; история его срабатываний удаляется каскадно
+func deleteAlertRule(ctx context.Context, db *sql.DB, dialect alertDialect, id int64) error {
+	return execAffecting(ctx, db, "delete alert rule", `DELETE FROM alert_rules WHERE id = `+dialect.param(1), id)
+}
+
+// updateAlertState записывает новое состояние правила, если оно не менялось с версии rule.Version,
+// и в той же транзакции - срабатывание event, если оно есть. Возвращает false, если состояние
+// уже изменил другой экземпляр сервиса или правило удалено; тогда ничего не записывается.
+func updateAlertState(ctx context.Context, db *sql.DB, log *zap.Logger, dialect alertDialect, rule *models.AlertRule, event *models.AlertEvent) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	p := dialect.param
+	var lastFired any
+	if rule.LastFiredAt != nil {
+		lastFired = dialect.timeArg(*rule.LastFiredAt)
+	}
+	res, err := tx.ExecContext(ctx, `
+        UPDATE alert_rules SET state = `+p(1)+`, state_changed_at = `+p(2)+`, last_fired_at = `+p(3)+`, version = version + 1
+        WHERE id = `+p(4)+` AND version = `+p(5),
+		rule.State, dialect.timeArg(rule.StateChangedAt), lastFired, rule.ID, rule.Version)
+	if err != nil {
+		log.Error("Failed to update alert state", zap.Error(err), zap.Int64("rule_id", rule.ID))
+		return false, fmt.Errorf("failed to update alert state: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update alert state: %w", err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+
+	if event != nil {
+		query := `INSERT INTO alert_events (rule_id, price, value, fired_at) VALUES (` + p(1) + `, ` + p(2) + `, ` + p(3) + `, ` + p(4) + `)`
+		args := []any{event.RuleID, event.Price, event.Value, dialect.timeArg(event.FiredAt)}
+		if dialect.returning {
+			err = tx.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&event.ID)
+		} else {
+			var res sql.Result
+			if res, err = tx.ExecContext(ctx, query, args...); err == nil {
+				event.ID, err = res.LastInsertId()
+			}
+		}
+		if err != nil {
+			log.Error("Failed to record alert event", zap.Error(err), zap.Int64("rule_id", rule.ID))
+			return false, fmt.Errorf("failed to record alert event: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	rule.Version++
+	return true, nil
+}
+
+// queryAlertEvents возвращает срабатывания правил от новых к старым
+func queryAlertEvents(ctx context.Context, db *sql.DB, log *zap.Logger, dialect alertDialect, filter *models.AlertEventFilter) ([]*models.AlertEvent, error) {
+	var conditions []string
+	var args []any
+	if filter.Owner != "" {
+		args = append(args, filter.Owner)
+		conditions = append(conditions, "r.owner = "+dialect.param(len(args)))
+	}
+	if filter.RuleID > 0 {
+		args = append(args, filter.RuleID)
+		conditions = append(conditions, "e.rule_id = "+dialect.param(len(args)))
+	}
+	if filter.From > 0 {
+		args = append(args, dialect.timeArg(filter.From))
+		conditions = append(conditions, "e.fired_at >= "+dialect.param(len(args)))
+	}
+	if filter.To > 0 {
+		args = append(args, dialect.timeArg(filter.To))
+		conditions = append(conditions, "e.fired_at < "+dialect.param(len(args)))
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAlertEventsLimit
+	}
+	args = append(args, limit)
+
+	query := `
+        SELECT e.id, e.rule_id, c.symbol, r.kind, e.price, e.value, e.fired_at
+        FROM alert_events e
+        JOIN alert_rules r ON r.id = e.rule_id
+        JOIN tracked_coins c ON c.id = r.coin_id`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY e.fired_at DESC, e.id DESC LIMIT " + dialect.param(len(args))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Error("Failed to get alert events", zap.Error(err), zap.String("owner", filter.Owner))
+		return nil, fmt.Errorf("failed to get alert events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.AlertEvent
+	for rows.Next() {
+		var event models.AlertEvent
+		if err := rows.Scan(
+			&event.ID,
+			&event.RuleID,
+			&event.Coin,
+			&event.Condition,
+			&event.Price,
+			&event.Value,
+			&event.FiredAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan alert event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return events, nil
+}
+
+// CreateAlertRule создает правило и заполняет rule.ID
+func (r *Repository) CreateAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return insertAlertRule(ctx, r.db, r.log, postgresAlerts, rule)
+}
+
+// GetAlertRule возвращает правило. Читает из основной базы: по правилу проверяются права на его удаление.
+func (r *Repository) GetAlertRule(ctx context.Context, id int64) (*models.AlertRule, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return queryAlertRule(ctx, r.db, postgresAlerts, id)
+}
+
+// ListAlertRules возвращает правила владельца owner, а при пустом owner - все правила.
+// Читает из основной базы: по списку правил проверяются тики, и версия состояния должна быть свежей.
+func (r *Repository) ListAlertRules(ctx context.Context, owner string) ([]*models.AlertRule, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return queryAlertRules(ctx, r.db, r.log, postgresAlerts, owner)
+}
+
+func (r *Repository) DeleteAlertRule(ctx context.Context, id int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return deleteAlertRule(ctx, r.db, postgresAlerts, id)
+}
+
+// UpdateAlertState условно по версии записывает состояние правила и срабатывание event
+func (r *Repository) UpdateAlertState(ctx context.Context, rule *models.AlertRule, event *models.AlertEvent) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return updateAlertState(ctx, r.db, r.log, postgresAlerts, rule, event)
+}
+
+// GetAlertEvents возвращает историю срабатываний от новых к старым
+func (r *Repository) GetAlertEvents(ctx context.Context, filter *models.AlertEventFilter) ([]*models.AlertEvent, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var events []*models.AlertEvent
+	err := r.read(ctx, func(db *sql.DB) (err error) {
+		events, err = queryAlertEvents(ctx, db, r.log, postgresAlerts, filter)
+		return err
+	})
+	return events, err
+}
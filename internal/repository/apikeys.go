@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// apiKeyDialect - особенности SQL таблицы api_keys в диалекте хранилища
+type apiKeyDialect struct {
+	param     func(n int) string         // n-й параметр запроса
+	timeArg   func(models.Timestamp) any // значение параметра для колонок времени
+	returning bool                       // INSERT возвращает id через RETURNING, иначе LastInsertId
+}
+
+var postgresAPIKeys = apiKeyDialect{
+	param:     numberedParam,
+	timeArg:   func(ts models.Timestamp) any { return ts.Time() },
+	returning: true,
+}
+
+const apiKeyColumns = `id, name, prefix, scopes, created_by, created_at, revoked_by, revoked_at`
+
+// insertAPIKey сохраняет ключ; имя, занятое другим ключом без учета регистра, - models.ErrDuplicate
+func insertAPIKey(ctx context.Context, db *sql.DB, log *zap.Logger, dialect apiKeyDialect, key *models.APIKey, hash string) error {
+	p := dialect.param
+	query := `
+        INSERT INTO api_keys (name, prefix, key_hash, scopes, created_by, created_at)
+        VALUES (` + strings.Join([]string{p(1), p(2), p(3), p(4), p(5), p(6)}, ", ") + `)`
+	args := []any{key.Name, key.Prefix, hash, joinScopes(key.Scopes), key.CreatedBy, dialect.timeArg(key.CreatedAt)}
+
+	if dialect.returning {
+		err := db.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&key.ID)
+		if err != nil {
+			log.Error("Failed to create API key", zap.Error(err), zap.String("name", key.Name))
+			return fmt.Errorf("failed to create api key: %w", constraintError(err))
+		}
+		return nil
+	}
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err == nil {
+		key.ID, err = res.LastInsertId()
+	}
+	if err != nil {
+		log.Error("Failed to create API key", zap.Error(err), zap.String("name", key.Name))
+		return fmt.Errorf("failed to create api key: %w", constraintError(err))
+	}
+	return nil
+}
+
+// queryAPIKeyByHash возвращает действующий ключ по хешу; отозванный ключ не находится (sql.ErrNoRows)
+func queryAPIKeyByHash(ctx context.Context, db *sql.DB, dialect apiKeyDialect, hash string) (*models.APIKey, error) {
+	row := db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = `+dialect.param(1)+` AND revoked_at IS NULL`, hash)
+	key, err := scanAPIKey(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+func queryAPIKeys(ctx context.Context, db *sql.DB, log *zap.Logger) ([]*models.APIKey, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		log.Error("Failed to list API keys", zap.Error(err))
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			log.Error("Failed to scan API key", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return keys, nil
+}
+
+// revokeAPIKey отзывает действующий ключ; если такого ключа нет, возвращается sql.ErrNoRows
+func revokeAPIKey(ctx context.Context, db *sql.DB, log *zap.Logger, dialect apiKeyDialect, id int64, revokedBy string, at models.Timestamp) error {
+	p := dialect.param
+	res, err := db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_by = `+p(1)+`, revoked_at = `+p(2)+` WHERE id = `+p(3)+` AND revoked_at IS NULL`,
+		revokedBy, dialect.timeArg(at), id)
+	if err != nil {
+		log.Error("Failed to revoke API key", zap.Error(err), zap.Int64("id", id))
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("failed to revoke api key: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (*models.APIKey, error) {
+	var key models.APIKey
+	var scopes string
+	var revokedAt *models.Timestamp
+	if err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&scopes,
+		&key.CreatedBy,
+		&key.CreatedAt,
+		&key.RevokedBy,
+		&revokedAt,
+	); err != nil {
+		return nil, err
+	}
+	key.Scopes = splitScopes(scopes)
+	key.RevokedAt = revokedAt
+	return &key, nil
+}
+
+// Права ключа хранятся одной строкой через запятую
+func joinScopes(scopes []models.Scope) string {
+	parts := make([]string, len(scopes))
+	for i, scope := range scopes {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitScopes(s string) []models.Scope {
+	var scopes []models.Scope
+	for _, part := range strings.Split(s, ",") {
+		if part != "" {
+			scopes = append(scopes, models.Scope(part))
+		}
+	}
+	return scopes
+}
+
+// CreateAPIKey сохраняет ключ по его хешу и заполняет key.ID
+func (r *Repository) CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return insertAPIKey(ctx, r.db, r.log, postgresAPIKeys, key, hash)
+}
+
+// GetAPIKeyByHash ищет действующий ключ. Запрос идет в основную базу,
+// чтобы отозванный ключ не принимался из-за отставания реплики.
+func (r *Repository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return queryAPIKeyByHash(ctx, r.db, postgresAPIKeys, hash)
+}
+
+// ListAPIKeys возвращает все ключи, включая отозванные, в порядке создания
+func (r *Repository) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return queryAPIKeys(ctx, r.db, r.log)
+}
+
+func (r *Repository) RevokeAPIKey(ctx context.Context, id int64, revokedBy string, at models.Timestamp) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return revokeAPIKey(ctx, r.db, r.log, postgresAPIKeys, id, revokedBy, at)
+}
@@ -0,0 +1,379 @@
+package repository
+
+import (
+	"awesomeProject/internal/archive"
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// OldestPriceBefore возвращает время самого раннего тика раньше before;
+// false - таких тиков нет
+func (r *Repository) OldestPriceBefore(ctx context.Context, before time.Time) (time.Time, bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var oldest sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT MIN(timestamp) FROM coin_prices WHERE timestamp < $1`, before).Scan(&oldest)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get oldest price: %w", err)
+	}
+	return oldest.Time, oldest.Valid, nil
+}
+
+// ArchiveMonth передает тики месяца UTC, начинающегося в month, в write по монетам и суткам
+// (по возрастанию времени) и убирает их из coin_prices в той же транзакции: секция месяца
+// отсоединяется и удаляется целиком, без построчного DELETE и последующей очистки таблицы.
+// Если write вернул ошибку, тики остаются в базе. query_timeout не применяется: время
+// выгрузки зависит от архива.
+func (r *Repository) ArchiveMonth(ctx context.Context, month time.Time, write func(coinID int64, day time.Time, prices []*models.CryptoPrice) error) (int64, error) {
+	from := monthStart(month)
+	to := from.AddDate(0, 1, 0)
+	existing, err := r.partitions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	name := partitionName(from)
+	_, partitioned := existing[name]
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	// Запись в выгружаемую таблицу ждет конца транзакции, поэтому удаляются ровно выгруженные тики.
+	// Тики месяца без своей секции лежат в секции по умолчанию: это тики, записанные задним
+	// числом после архивации месяца, и их немного, поэтому они удаляются построчно.
+	table := "coin_prices_default"
+	if partitioned {
+		table = name
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`LOCK TABLE %s IN EXCLUSIVE MODE`, table)); err != nil {
+		return 0, fmt.Errorf("failed to lock %s: %w", table, err)
+	}
+
+	exported, err := exportPrices(ctx, tx, from, to, write)
+	if err != nil {
+		return 0, err
+	}
+
+	if partitioned {
+		for _, statement := range []string{
+			fmt.Sprintf(`ALTER TABLE coin_prices DETACH PARTITION %s`, name),
+			fmt.Sprintf(`DROP TABLE %s`, name),
+		} {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return 0, fmt.Errorf("failed to drop archived partition %s: %w", name, err)
+			}
+		}
+	} else {
+		res, err := tx.ExecContext(ctx, `DELETE FROM coin_prices_default WHERE timestamp >= $1 AND timestamp < $2`, from, to)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete archived prices: %w", err)
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if deleted != exported {
+			return 0, fmt.Errorf("deleted %d prices, exported %d", deleted, exported)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if partitioned {
+		r.log.Info("Dropped archived partition", zap.String("partition", name))
+	}
+	return exported, nil
+}
+
+func exportPrices(ctx context.Context, tx *sql.Tx, from, to time.Time, write func(coinID int64, day time.Time, prices []*models.CryptoPrice) error) (int64, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id, coin_id, price, timestamp
+        FROM coin_prices
+        WHERE timestamp >= $1 AND timestamp < $2
+        ORDER BY coin_id, timestamp`,
+		from, to,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to export prices: %w", err)
+	}
+	defer rows.Close()
+
+	var exported int64
+	var batch []*models.CryptoPrice
+	var day time.Time
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := write(batch[0].CoinID, day, batch); err != nil {
+			return fmt.Errorf("failed to archive prices of coin %d: %w", batch[0].CoinID, err)
+		}
+		exported += int64(len(batch))
+		batch = nil
+		return nil
+	}
+	for rows.Next() {
+		var price models.CryptoPrice
+		if err := rows.Scan(&price.ID, &price.CoinID, &price.Price, &price.Timestamp); err != nil {
+			return 0, fmt.Errorf("failed to scan price: %w", err)
+		}
+		// Тики пишутся в архив пакетами монеты за одни сутки
+		t := price.Timestamp.Time().UTC()
+		priceDay := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		if len(batch) > 0 && (batch[0].CoinID != price.CoinID || !priceDay.Equal(day)) {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+		day = priceDay
+		batch = append(batch, &price)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows error: %w", err)
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return exported, nil
+}
+
+// pricesBetween возвращает тики монеты из базы за период [from, to) по возрастанию времени
+func (r *Repository) pricesBetween(ctx context.Context, coinID int64, from, to models.Timestamp) ([]*models.CryptoPrice, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var prices []*models.CryptoPrice
+	err := r.read(ctx, func(db *sql.DB) (err error) {
+		prices, err = queryPrices(ctx, db, r.log, `
+            SELECT id, coin_id, price, timestamp
+            FROM coin_prices
+            WHERE coin_id = $1 AND timestamp >= $2 AND timestamp < $3
+            ORDER BY timestamp`,
+			coinID, from.Time(), to.Time(),
+		)
+		return err
+	})
+	return prices, err
+}
+
+// ArchivedRepository - хранилище PostgreSQL, которое дочитывает из архива тики,
+// вынесенные из coin_prices. Запросы цены и агрегаты за старые периоды
+// объединяют архив и базу; остальные методы работают как у Repository.
+type ArchivedRepository struct {
+	*Repository
+	archive *archive.Archive
+}
+
+func (r *Repository) WithArchive(archive *archive.Archive) *ArchivedRepository {
+	return &ArchivedRepository{Repository: r, archive: archive}
+}
+
+func (r *ArchivedRepository) cutoff() models.Timestamp {
+	return models.TimestampOf(r.archive.Cutoff(time.Now()))
+}
+
+// GetPrice возвращает ближайшую цену из базы или архива
+func (r *ArchivedRepository) GetPrice(ctx context.Context, coin *models.GetPriceRequest) (*models.CryptoPrice, error) {
+	stored, err := r.Repository.GetPrice(ctx, coin)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// В архиве только тики раньше границы: более близкий тик там возможен,
+	// лишь если окрестность найденного в базе тика заходит за границу
+	if stored != nil && coin.Timestamp-distance(stored.Timestamp, coin.Timestamp) >= r.cutoff() {
+		return stored, nil
+	}
+
+	tracked, coinErr := r.GetCoin(ctx, coin.Coin)
+	if coinErr != nil {
+		if stored != nil {
+			return stored, nil
+		}
+		return nil, err
+	}
+	archived, archiveErr := r.archive.Nearest(ctx, tracked.ID, coin.Timestamp)
+	if archiveErr != nil {
+		r.log.Error("Failed to read archive", zap.Error(archiveErr), zap.String("coin", coin.Coin))
+		return nil, fmt.Errorf("failed to get price: %w", archiveErr)
+	}
+
+	switch {
+	case archived == nil && stored == nil:
+		return nil, err
+	case archived == nil:
+		return stored, nil
+	case stored != nil && !archive.Closer(archived.Timestamp, stored.Timestamp, coin.Timestamp):
+		return stored, nil
+	}
+	archived.Symbol = tracked.Symbol
+	return archived, nil
+}
+
+func distance(a, b models.Timestamp) models.Timestamp {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// dstMargin покрывает удлинение суток при переходе на зимнее время
+const dstMargin = 2 * time.Hour
+
+// GetPriceBuckets агрегирует цены за период, захватывающий архив. Интервалы
+// до первого целиком свежего интервала сворачиваются из тиков архива и базы,
+// остальные агрегирует PostgreSQL.
+func (r *ArchivedRepository) GetPriceBuckets(ctx context.Context, q *models.BucketQuery) ([]*models.PriceBucket, error) {
+	cutoff := r.cutoff()
+	if q.From >= cutoff {
+		return r.Repository.GetPriceBuckets(ctx, q)
+	}
+
+	tracked, err := r.GetCoin(ctx, q.Symbol)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.Repository.GetPriceBuckets(ctx, q)
+	}
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	// Интервал, содержащий границу, включает и архивные, и свежие тики
+	split := min(bucketStart(cutoff+models.Timestamp((q.Interval+dstMargin).Milliseconds()), q.Interval, loc), q.To)
+	archived, err := r.archive.Prices(ctx, tracked.ID, q.From, split)
+	if err != nil {
+		r.log.Error("Failed to read archive", zap.Error(err), zap.String("coin", q.Symbol))
+		return nil, fmt.Errorf("failed to get price buckets: %w", err)
+	}
+	stored, err := r.pricesBetween(ctx, tracked.ID, q.From, split)
+	if err != nil {
+		return nil, err
+	}
+
+	fold := bucketFold{interval: q.Interval, loc: loc}
+	for _, price := range archive.MergePrices(archived, stored) {
+		fold.add(price.Price, price.Timestamp)
+	}
+	buckets := fold.finish()
+	if split >= q.To {
+		return buckets, nil
+	}
+
+	rest, err := r.Repository.GetPriceBuckets(ctx, &models.BucketQuery{
+		Symbol:   q.Symbol,
+		From:     split,
+		To:       q.To,
+		Interval: q.Interval,
+		Timezone: q.Timezone,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(buckets, rest...), nil
+}
+
+// ExportPrices возвращает до limit тиков монеты позже after по возрастанию времени
+// из архива и базы, чтобы резервная копия включала и перенесенную в архив историю
+func (r *ArchivedRepository) ExportPrices(ctx context.Context, coinID int64, after models.Timestamp, limit int) ([]*models.CryptoPrice, error) {
+	archived, err := r.archive.PricesAfter(ctx, coinID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export archived prices: %w", err)
+	}
+	stored, err := r.Repository.ExportPrices(ctx, coinID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := archive.MergePrices(archived, stored)
+	return prices[:min(limit, len(prices))], nil
+}
+
+// PurgeCoin удаляет монету с историей цен в базе и в архиве и возвращает число удаленных
+// тиков в обоих. Архив удаляется после фиксации удаления в базе; если это не удалось,
+// монета записывается в archive_orphans, и ее архив дочищает планировщик архивации.
+func (r *ArchivedRepository) PurgeCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) (int64, error) {
+	tracked, err := r.GetCoin(ctx, coin.Symbol)
+	if err != nil {
+		return r.Repository.PurgeCoin(ctx, coin, actor)
+	}
+	archived, err := r.archive.Count(ctx, tracked.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count archived prices: %w", err)
+	}
+	deleted, err := r.Repository.PurgeCoin(ctx, coin, actor)
+	if err != nil {
+		return deleted, err
+	}
+
+	files, err := r.archive.DeleteCoin(ctx, tracked.ID)
+	if err != nil {
+		r.log.Warn("Failed to delete archived prices, leaving them for cleanup", zap.Error(err), zap.String("coin", coin.Symbol))
+		if err := r.AddArchiveOrphan(ctx, tracked.ID); err != nil {
+			r.log.Error("Failed to record orphaned archive", zap.Error(err), zap.Int64("coin_id", tracked.ID))
+		}
+		return deleted + archived, nil
+	}
+	r.log.Info("Deleted archived prices", zap.String("coin", coin.Symbol), zap.Int("files", files))
+	return deleted + archived, nil
+}
+
+// AddArchiveOrphan записывает удаленную монету, архив которой еще предстоит удалить
+func (r *Repository) AddArchiveOrphan(ctx context.Context, coinID int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO archive_orphans (coin_id) VALUES ($1) ON CONFLICT DO NOTHING`, coinID)
+	if err != nil {
+		return fmt.Errorf("failed to add archive orphan: %w", err)
+	}
+	return nil
+}
+
+// ArchiveOrphans возвращает удаленные монеты, архив которых еще не удален
+func (r *Repository) ArchiveOrphans(ctx context.Context) ([]int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT coin_id FROM archive_orphans ORDER BY coin_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archive orphans: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan archive orphan: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return ids, nil
+}
+
+// DeleteArchiveOrphan убирает монету из archive_orphans после удаления ее архива
+func (r *Repository) DeleteArchiveOrphan(ctx context.Context, coinID int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM archive_orphans WHERE coin_id = $1`, coinID); err != nil {
+		return fmt.Errorf("failed to delete archive orphan: %w", err)
+	}
+	return nil
+}
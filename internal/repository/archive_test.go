@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"awesomeProject/internal/archive"
+	"awesomeProject/internal/models"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// TestPostgresArchive проверяет, что после переноса тиков в архив цены
+// и агрегаты через ArchivedRepository не меняются
+func TestPostgresArchive(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer admin.Close()
+
+	ctx := t.Context()
+	repo := &Repository{db: openPostgresSchema(t, admin, dsn), log: zap.NewNop()}
+	store, err := archive.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	priceArchive := archive.New(store, 30)
+	archived := repo.WithArchive(priceArchive)
+
+	if err := repo.AddCoin(ctx, &models.TrackedCoin{Symbol: "BTC"}, &models.Actor{Name: "test"}); err != nil {
+		t.Fatalf("AddCoin: %v", err)
+	}
+	coin, err := repo.GetCoin(ctx, "BTC")
+	if err != nil {
+		t.Fatalf("GetCoin: %v", err)
+	}
+
+	// Месяцы раньше месяца границы переносятся целиком: первый - из своей секции,
+	// второй - из секции по умолчанию
+	cutoff := priceArchive.Cutoff(time.Now())
+	current := monthStart(cutoff)
+	first := current.AddDate(0, -2, 0)
+	if _, err := repo.EnsurePartitions(ctx, first, first); err != nil {
+		t.Fatalf("EnsurePartitions: %v", err)
+	}
+	moments := []time.Time{first.Add(time.Hour), first.Add(time.Hour + 500*time.Millisecond), current.Add(-12 * time.Hour), cutoff.Add(-time.Hour), cutoff.Add(50 * time.Hour)}
+	var fresh int
+	for i, moment := range moments {
+		price := &models.CryptoPrice{CoinID: coin.ID, Price: decimal.NewFromInt(int64(i + 1)), Timestamp: models.TimestampOf(moment)}
+		if err := repo.AddNewPrice(ctx, price); err != nil {
+			t.Fatalf("AddNewPrice: %v", err)
+		}
+		if !moment.Before(current) {
+			fresh++
+		}
+	}
+
+	from, to := models.TimestampOf(first.AddDate(0, 0, -7)), models.TimestampOf(cutoff.AddDate(0, 0, 7))
+	queries := []*models.BucketQuery{
+		{Symbol: "BTC", From: from, To: to, Interval: time.Hour, Timezone: "UTC"},
+		{Symbol: "BTC", From: from, To: to, Interval: 24 * time.Hour, Timezone: "Europe/Moscow"},
+		{Symbol: "BTC", From: from, To: to, Interval: 7 * 24 * time.Hour, Timezone: "America/New_York"},
+	}
+	points := []models.Timestamp{from, models.TimestampOf(first.Add(2 * time.Hour)), models.TimestampOf(current.Add(-5 * time.Hour)), models.TimestampOf(cutoff), to}
+
+	snapshot := func() string {
+		var s string
+		for _, q := range queries {
+			buckets, err := archived.GetPriceBuckets(ctx, q)
+			if err != nil {
+				t.Fatalf("GetPriceBuckets(%s): %v", q.Interval, err)
+			}
+			for _, b := range buckets {
+				s += fmt.Sprintf("%s %s %s %s %s %s %d\n", b.Start, b.Open, b.High, b.Low, b.Close, b.Mean, b.Count)
+			}
+		}
+		for _, ts := range points {
+			price, err := archived.GetPrice(ctx, &models.GetPriceRequest{Coin: "BTC", Timestamp: ts})
+			if err != nil {
+				t.Fatalf("GetPrice(%s): %v", ts, err)
+			}
+			s += fmt.Sprintf("%s: %s %s %s\n", ts, price.Symbol, price.Price, price.Timestamp)
+		}
+		// Резервная копия выгружает тики страницами из архива и базы
+		coin, err := archived.GetCoin(ctx, "BTC")
+		if err != nil {
+			t.Fatalf("GetCoin: %v", err)
+		}
+		var after models.Timestamp
+		for {
+			page, err := archived.ExportPrices(ctx, coin.ID, after, 2)
+			if err != nil {
+				t.Fatalf("ExportPrices(%s): %v", after, err)
+			}
+			for _, price := range page {
+				s += fmt.Sprintf("export %s %s\n", price.Timestamp, price.Price)
+			}
+			if len(page) < 2 {
+				break
+			}
+			after = page[len(page)-1].Timestamp
+		}
+		return s
+	}
+	before := snapshot()
+
+	for month := first; month.Before(current); month = month.AddDate(0, 1, 0) {
+		if _, err := repo.ArchiveMonth(ctx, month, func(coinID int64, day time.Time, prices []*models.CryptoPrice) error {
+			return priceArchive.Write(ctx, coinID, day, prices)
+		}); err != nil {
+			t.Fatalf("ArchiveMonth(%s): %v", month, err)
+		}
+	}
+	partitions, err := repo.partitions(ctx)
+	if err != nil {
+		t.Fatalf("partitions: %v", err)
+	}
+	if _, ok := partitions[partitionName(first)]; ok {
+		t.Errorf("partition %s is left after archiving", partitionName(first))
+	}
+
+	var remaining int
+	if err := repo.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM coin_prices").Scan(&remaining); err != nil {
+		t.Fatalf("failed to count prices: %v", err)
+	}
+	if remaining != fresh {
+		t.Errorf("prices left in coin_prices = %d, want %d", remaining, fresh)
+	}
+	if after := snapshot(); after != before {
+		t.Errorf("after archiving:\n%s\nbefore:\n%s", after, before)
+	}
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// defaultAuditLimit ограничивает выборку журнала аудита без явного limit
+const defaultAuditLimit = 100
+
+// auditDialect - особенности SQL журнала аудита в диалекте хранилища
+type auditDialect struct {
+	insert     string             // добавление записи
+	states     string             // колонки состояния до и после изменения
+	timeColumn string             // время записи в Unix-миллисекундах или значением времени
+	timeParam  string             // параметр в Unix-миллисекундах, приведенный к типу created_at; %s - параметр
+	param      func(n int) string // n-й параметр запроса
+}
+
+func numberedParam(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+var postgresAudit = auditDialect{
+	insert: `
+        INSERT INTO audit_log (action, coin_id, symbol, actor, request_id, before, after)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+	states:     "before, after",
+	timeColumn: "created_at",
+	timeParam:  "to_timestamp(%s::BIGINT / 1000.0)",
+	param:      numberedParam,
+}
+
+// writeAudit добавляет запись в журнал аудита в транзакции изменения,
+// поэтому изменение и запись о нем фиксируются или откатываются вместе
+func writeAudit(ctx context.Context, tx *sql.Tx, action models.AuditAction, coinID int64, symbol string, actor *models.Actor, before, after any) error {
+	return insertAudit(ctx, tx, postgresAudit, action, coinID, symbol, actor, before, after)
+}
+
+func insertAudit(ctx context.Context, tx *sql.Tx, dialect auditDialect, action models.AuditAction, coinID int64, symbol string, actor *models.Actor, before, after any) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, dialect.insert,
+		action,
+		coinID,
+		symbol,
+		actor.Name,
+		actor.RequestID,
+		beforeJSON,
+		afterJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// auditJSON сериализует состояние; отсутствующее состояние записывается как NULL
+func auditJSON(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit state: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetAuditLog возвращает записи журнала аудита от новых к старым
+func (r *Repository) GetAuditLog(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var entries []*models.AuditEntry
+	err := r.read(ctx, func(db *sql.DB) (err error) {
+		entries, err = queryAuditLog(ctx, db, r.log, filter, postgresAudit)
+		return err
+	})
+	return entries, err
+}
+
+func queryAuditLog(ctx context.Context, db *sql.DB, log *zap.Logger, filter *models.AuditFilter, dialect auditDialect) ([]*models.AuditEntry, error) {
+	var conditions []string
+	var args []any
+	if filter.Coin != "" {
+		args = append(args, filter.Coin)
+		conditions = append(conditions, "symbol = "+dialect.param(len(args)))
+	}
+	if filter.Actor != "" {
+		args = append(args, filter.Actor)
+		conditions = append(conditions, "actor = "+dialect.param(len(args)))
+	}
+	if filter.From > 0 {
+		args = append(args, filter.From)
+		conditions = append(conditions, "created_at >= "+fmt.Sprintf(dialect.timeParam, dialect.param(len(args))))
+	}
+	if filter.To > 0 {
+		args = append(args, filter.To)
+		conditions = append(conditions, "created_at < "+fmt.Sprintf(dialect.timeParam, dialect.param(len(args))))
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	args = append(args, limit)
+
+	query := `
+        SELECT id, action, coin_id, symbol, actor, request_id, ` + dialect.states + `, ` + dialect.timeColumn + `
+        FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT " + dialect.param(len(args))
+
+	log.Debug("Getting audit log", zap.String("coin", filter.Coin), zap.String("actor", filter.Actor))
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Error("Failed to get audit log", zap.Error(err))
+		return nil, fmt.Errorf("failed to get audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		var entry models.AuditEntry
+		var coinID sql.NullInt64
+		var before, after []byte
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.Action,
+			&coinID,
+			&entry.Symbol,
+			&entry.Actor,
+			&entry.RequestID,
+			&before,
+			&after,
+			&entry.Timestamp,
+		); err != nil {
+			log.Error("Failed to scan audit entry", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if coinID.Valid {
+			entry.CoinID = &coinID.Int64
+		}
+		entry.Before = auditRaw(before)
+		entry.After = auditRaw(after)
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return entries, nil
+}
+
+func auditRaw(data []byte) json.RawMessage {
+	if data == nil {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(data)
+}
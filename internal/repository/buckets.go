@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// bucketQuery строит запрос агрегации по интервалам для хранилищ без date_bin с часовыми
+// поясами. Смещения пояса loc на периоде запроса вычисляются заранее и передаются
+// параметрами, поэтому интервалы группирует база с теми же границами, что и в PostgreSQL:
+// запрос возвращает начало интервала в местных миллисекундах от bucketOrigin (см. bucketAt),
+// цены открытия и закрытия, число тиков и столбцы aggregates над ценами интервала.
+func bucketQuery(q *models.BucketQuery, loc *time.Location, param func(int) string, aggregates ...string) (string, []any) {
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return param(len(args))
+	}
+
+	// Смещение пояса в момент тика: в периоде бывает несколько переходов на летнее время
+	spans := zoneSpans(q.From, q.To, loc)
+	offset := arg(spans[len(spans)-1].offset)
+	if len(spans) > 1 {
+		var cases strings.Builder
+		cases.WriteString("CASE")
+		for i := range spans[:len(spans)-1] {
+			fmt.Fprintf(&cases, " WHEN cp.timestamp < %s THEN %s", arg(spans[i+1].since), arg(spans[i].offset))
+		}
+		cases.WriteString(" ELSE " + offset + " END")
+		offset = cases.String()
+	}
+	origin, interval := models.TimestampOf(bucketOrigin), q.Interval.Milliseconds()
+
+	columns, selected := make([]string, len(aggregates)), make([]string, len(aggregates))
+	for i, aggregate := range aggregates {
+		columns[i] = fmt.Sprintf("%s AS agg%d", aggregate, i)
+		selected[i] = fmt.Sprintf("b.agg%d", i)
+	}
+	query := fmt.Sprintf(`
+        WITH ticks AS (
+            SELECT coin_id, price, timestamp, wall - ((wall %% %[1]d) + %[1]d) %% %[1]d AS bucket
+            FROM (
+                SELECT cp.coin_id, cp.price, cp.timestamp, cp.timestamp + %[2]s - %[3]d AS wall
+                FROM coin_prices cp
+                JOIN tracked_coins tc ON tc.id = cp.coin_id
+                WHERE tc.symbol = %[4]s
+                  AND cp.timestamp >= %[5]s
+                  AND cp.timestamp < %[6]s
+            ) local_ticks
+        ),
+        buckets AS (
+            SELECT coin_id, bucket, MIN(timestamp) AS first_at, MAX(timestamp) AS last_at, COUNT(*) AS ticks, %[7]s
+            FROM ticks
+            GROUP BY coin_id, bucket
+        )
+        SELECT b.bucket, o.price, c.price, b.ticks, %[8]s
+        FROM buckets b
+        JOIN coin_prices o ON o.coin_id = b.coin_id AND o.timestamp = b.first_at
+        JOIN coin_prices c ON c.coin_id = b.coin_id AND c.timestamp = b.last_at
+        ORDER BY b.bucket`,
+		interval, offset, int64(origin), arg(q.Symbol), arg(q.From), arg(q.To),
+		strings.Join(columns, ", "), strings.Join(selected, ", "))
+	return query, args
+}
+
+// bucketStats заполняет максимум, минимум и среднее интервала по его ценам
+func bucketStats(bucket *models.PriceBucket, prices []string) error {
+	sum := decimal.Zero
+	for i, s := range prices {
+		price, err := decimal.NewFromString(s)
+		if err != nil {
+			return fmt.Errorf("invalid price %q: %w", s, err)
+		}
+		if i == 0 || price.GreaterThan(bucket.High) {
+			bucket.High = price
+		}
+		if i == 0 || price.LessThan(bucket.Low) {
+			bucket.Low = price
+		}
+		sum = sum.Add(price)
+	}
+	bucket.Mean = sum.DivRound(decimal.NewFromInt(int64(len(prices))), 18)
+	return nil
+}
+
+// zoneSpan - смещение пояса в миллисекундах, действующее с момента since
+type zoneSpan struct {
+	since  models.Timestamp
+	offset int64
+}
+
+// zoneSpans возвращает смещения пояса loc на полуинтервале [from, to) по возрастанию времени
+func zoneSpans(from, to models.Timestamp, loc *time.Location) []zoneSpan {
+	var spans []zoneSpan
+	t := from.Time().In(loc)
+	for {
+		_, offset := t.Zone()
+		spans = append(spans, zoneSpan{since: models.TimestampOf(t), offset: int64(offset) * 1000})
+		_, end := t.ZoneBounds()
+		if end.IsZero() || !end.Before(to.Time()) {
+			return spans
+		}
+		t = end.In(loc)
+	}
+}
+
+// bucketAt возвращает начало интервала, заданное местными миллисекундами от bucketOrigin
+func bucketAt(wall int64, loc *time.Location) models.Timestamp {
+	start := bucketOrigin.Add(time.Duration(wall) * time.Millisecond)
+	return models.TimestampOf(time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc))
+}
+
+// bucketFold накапливает интервалы из тиков, поступающих по возрастанию времени
+type bucketFold struct {
+	interval time.Duration
+	loc      *time.Location
+	buckets  []*models.PriceBucket
+	bucket   *models.PriceBucket
+	sum      decimal.Decimal
+}
+
+func (f *bucketFold) add(price decimal.Decimal, timestamp models.Timestamp) {
+	start := bucketStart(timestamp, f.interval, f.loc)
+	if f.bucket == nil || f.bucket.Start != start {
+		f.closeBucket()
+		f.bucket = &models.PriceBucket{Start: start, Open: price, High: price, Low: price}
+		f.sum = decimal.Zero
+		f.buckets = append(f.buckets, f.bucket)
+	}
+	if price.GreaterThan(f.bucket.High) {
+		f.bucket.High = price
+	}
+	if price.LessThan(f.bucket.Low) {
+		f.bucket.Low = price
+	}
+	f.bucket.Close = price
+	f.bucket.Count++
+	f.sum = f.sum.Add(price)
+}
+
+func (f *bucketFold) closeBucket() {
+	if f.bucket != nil {
+		f.bucket.Mean = f.sum.DivRound(decimal.NewFromInt(f.bucket.Count), 18)
+	}
+}
+
+func (f *bucketFold) finish() []*models.PriceBucket {
+	f.closeBucket()
+	return f.buckets
+}
+
+// bucketOrigin - начало отсчета интервалов (понедельник), как в date_bin для PostgreSQL
+var bucketOrigin = time.Date(2000, 1, 3, 0, 0, 0, 0, time.UTC)
+
+// bucketStart возвращает начало интервала, в который попадает момент timestamp.
+// Интервалы отсчитываются по местному времени loc, как date_bin(... AT TIME ZONE loc).
+func bucketStart(timestamp models.Timestamp, interval time.Duration, loc *time.Location) models.Timestamp {
+	local := timestamp.Time().In(loc)
+	wall := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+
+	n := wall.Sub(bucketOrigin) / interval
+	if wall.Before(bucketOrigin) && wall.Sub(bucketOrigin)%interval != 0 {
+		n--
+	}
+	return bucketAt((n * interval).Milliseconds(), loc)
+}
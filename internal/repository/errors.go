@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// constraintError оборачивает ошибку драйвера о нарушении ограничения уникальности
+// в models.ErrDuplicate, а внешнего ключа - в models.ErrReferenced; остальные ошибки не меняются
+func constraintError(err error) error {
+	var pgErr *pgconn.PgError
+	var mysqlErr *mysql.MySQLError
+	var sqliteErr *sqlite.Error
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == "23505", // unique_violation
+		errors.As(err, &mysqlErr) && mysqlErr.Number == 1062, // ER_DUP_ENTRY
+		errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY):
+		return fmt.Errorf("%w: %w", models.ErrDuplicate, err)
+	case errors.As(err, &pgErr) && pgErr.Code == "23503", // foreign_key_violation
+		errors.As(err, &mysqlErr) && (mysqlErr.Number == 1451 || mysqlErr.Number == 1452), // ER_ROW_IS_REFERENCED_2, ER_NO_REFERENCED_ROW_2
+		errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return fmt.Errorf("%w: %w", models.ErrReferenced, err)
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// Выгрузка для резервного копирования: все монеты, включая убранные из списка
+// наблюдения, и их история цен страницами по возрастанию времени.
+// after - время последнего тика предыдущей страницы (0 - с начала истории).
+
+const exportCoinsQuery = `SELECT ` + coinColumns + ` FROM tracked_coins ORDER BY symbol`
+
+// ExportCoins возвращает все монеты, включая убранные из списка наблюдения
+func (r *Repository) ExportCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return queryCoins(ctx, r.db, r.log, exportCoinsQuery)
+}
+
+// ExportPrices возвращает до limit тиков монеты позже after по возрастанию времени
+func (r *Repository) ExportPrices(ctx context.Context, coinID int64, after models.Timestamp, limit int) ([]*models.CryptoPrice, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return queryPrices(ctx, r.db, r.log, `
+        SELECT id, coin_id, price, timestamp
+        FROM coin_prices
+        WHERE coin_id = $1 AND timestamp > $2
+        ORDER BY timestamp
+        LIMIT $3`,
+		coinID, after.Time(), limit,
+	)
+}
+
+// ExportCoins возвращает все монеты, включая убранные из списка наблюдения
+func (r *MySQLRepository) ExportCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	return queryCoins(ctx, r.db, r.log, exportCoinsQuery)
+}
+
+// ExportPrices возвращает до limit тиков монеты позже after по возрастанию времени
+func (r *MySQLRepository) ExportPrices(ctx context.Context, coinID int64, after models.Timestamp, limit int) ([]*models.CryptoPrice, error) {
+	return queryPrices(ctx, r.db, r.log, `
+        SELECT id, coin_id, price, timestamp
+        FROM coin_prices
+        WHERE coin_id = ? AND timestamp > ?
+        ORDER BY timestamp
+        LIMIT ?`,
+		coinID, after, limit,
+	)
+}
+
+// ExportCoins возвращает все монеты, включая убранные из списка наблюдения
+func (r *SQLiteRepository) ExportCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	return queryCoins(ctx, r.db, r.log, exportCoinsQuery)
+}
+
+// ExportPrices возвращает до limit тиков монеты позже after по возрастанию времени
+func (r *SQLiteRepository) ExportPrices(ctx context.Context, coinID int64, after models.Timestamp, limit int) ([]*models.CryptoPrice, error) {
+	return queryPrices(ctx, r.db, r.log, `
+        SELECT id, coin_id, price, timestamp
+        FROM coin_prices
+        WHERE coin_id = $1 AND timestamp > $2
+        ORDER BY timestamp
+        LIMIT $3`,
+		coinID, after, limit,
+	)
+}
+
+// ExportCoins возвращает все монеты, включая убранные из списка наблюдения
+func (r *MemoryRepository) ExportCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	return r.listCoins(func(*models.TrackedCoin) bool { return true }), nil
+}
+
+// ExportPrices возвращает до limit тиков монеты позже after по возрастанию времени
+func (r *MemoryRepository) ExportPrices(ctx context.Context, coinID int64, after models.Timestamp, limit int) ([]*models.CryptoPrice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	prices := r.prices[coinID]
+	i := sort.Search(len(prices), func(i int) bool { return prices[i].Timestamp > after })
+	page := make([]*models.CryptoPrice, 0, min(limit, len(prices)-i))
+	for _, price := range prices[i:min(i+limit, len(prices))] {
+		copied := *price
+		page = append(page, &copied)
+	}
+	return page, nil
+}
+
+func queryPrices(ctx context.Context, db *sql.DB, log *zap.Logger, query string, args ...any) ([]*models.CryptoPrice, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Error("Failed to get prices", zap.Error(err))
+		return nil, fmt.Errorf("failed to get prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []*models.CryptoPrice
+	for rows.Next() {
+		var price models.CryptoPrice
+		if err := rows.Scan(&price.ID, &price.CoinID, &price.Price, &price.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan price: %w", err)
+		}
+		prices = append(prices, &price)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return prices, nil
+}
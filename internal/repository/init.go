@@ -1,36 +1,82 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
-	"go.uber.org/zap"
-	"os"
-	"path/filepath"
+	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
 )
 
+// PoolConfig - настройки пула соединений PostgreSQL; нулевые поля оставляют значения pgx
+type PoolConfig struct {
+	MaxConns               int32         // максимум открытых соединений
+	MinConns               int32         // соединения, которые пул держит открытыми без нагрузки
+	StatementCacheCapacity int           // подготовленные запросы, кешируемые на соединении
+	QueryTimeout           time.Duration // ограничение времени одного запроса к хранилищу
+}
+
 // Storage представляет слой доступа к данным PostgreSQL
 type Storage struct {
-	db  *sql.DB
-	log *zap.Logger
+	pool     *pgxpool.Pool
+	db       *sql.DB     // database/sql поверх pool для goose и общих запросов
+	replicas *replicaSet // nil - реплики не заданы
+	timeout  time.Duration
+	log      *zap.Logger
 }
 
-// NewStorage создает новый экземпляр репозитория
-func NewStorage(user string, password string, host string, port string, dbname string, sslmode string, log *zap.Logger) (*Storage, error) {
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", user, password, host, port, dbname, sslmode)
+// NewStorage создает новый экземпляр репозитория. replicas - необязательные DSN
+// реплик для чтения, доступность которых проверяется раз в replicaCheckInterval.
+// Настройки pool применяются к основной базе и к каждой реплике.
+func NewStorage(user string, password string, host string, port string, dbname string, sslmode string, pool PoolConfig, replicas []string, replicaCheckInterval time.Duration, log *zap.Logger) (*Storage, error) {
 	log = log.With(zap.String("type", "Storage"))
 
+	primary, err := openPostgres(user, password, host, port, dbname, sslmode, pool, log)
+	if err != nil {
+		return nil, err
+	}
+	db := stdlib.OpenDBFromPool(primary)
+	if err := migrate(db, goose.DialectPostgres, "postgres", log); err != nil {
+		db.Close()
+		primary.Close()
+		return nil, err
+	}
+
+	storage := &Storage{
+		pool:    primary,
+		db:      db,
+		timeout: pool.QueryTimeout,
+		log:     log,
+	}
+	if len(replicas) > 0 {
+		log.Info("Connecting to read replicas", zap.Int("count", len(replicas)))
+		storage.replicas, err = newReplicaSet(db, replicas, pool, log)
+		if err != nil {
+			log.Error("Failed to open replica connection", zap.Error(err))
+			db.Close()
+			primary.Close()
+			return nil, err
+		}
+		go storage.replicas.start(replicaCheckInterval)
+	}
+	return storage, nil
+}
+
+func openPostgres(user string, password string, host string, port string, dbname string, sslmode string, pool PoolConfig, log *zap.Logger) (*pgxpool.Pool, error) {
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", user, password, host, port, dbname, sslmode)
+
 	log.Info("Connecting to PostgreSQL database",
 		zap.String("dbname", dbname),
 		zap.String("user", user),
-		zap.String("sslmode", sslmode))
+		zap.String("sslmode", sslmode),
+		zap.Int32("max_conns", pool.MaxConns),
+		zap.Int32("min_conns", pool.MinConns))
 
-	db, err := sql.Open("postgres", connStr)
+	p, err := newPool(connStr, pool)
 	if err != nil {
 		log.Error("Failed to open database connection", zap.Error(err))
 		return nil, err
@@ -38,71 +84,42 @@ func NewStorage(user string, password string, host string, port string, dbname s
 
 	// Проверка соединения
 	log.Info("Testing database connection")
-	if err := db.Ping(); err != nil {
+	if err := p.Ping(context.Background()); err != nil {
 		log.Error("Failed to ping database", zap.Error(err))
+		p.Close()
 		return nil, err
 	}
 
 	log.Info("Successfully connected to database")
-
-	log.Info("Starting database migrations")
-
-	//if err := migrations(connStr); err != nil {
-	//	log.Error("Failed to run database migrations", zap.Error(err))
-	//	return nil, err
-	//}
-	if err := runMigrations(db); err != nil {
-		log.Error("Failed to run migrations", zap.Error(err))
-		return nil, err
-	}
-	log.Info("Successfully migrated database")
-	return &Storage{
-		db:  db,
-		log: log,
-	}, nil
+	return p, nil
 }
 
-func migrations(connStr string) error {
-
-	m, err := migrate.New("file://../migrations", connStr)
-
+// newPool создает пул pgx. Запросы выполняются в режиме кеширования
+// подготовленных запросов: повторный запрос не готовится заново.
+func newPool(connStr string, pool PoolConfig) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
-		return fmt.Errorf("start migrations error %v", err)
-	}
-
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			return nil
-		}
-
-		return fmt.Errorf("migration up error: %v", err)
+		return nil, fmt.Errorf("failed to parse connection string: %w", err)
 	}
-
-	return nil
-
-}
-
-func runMigrations(db *sql.DB) error {
-	migrationsDir := os.Getenv("MIGRATIONS_DIR")
-	if migrationsDir == "" {
-		migrationsDir = ".../../migrations"
+	if pool.MaxConns > 0 {
+		cfg.MaxConns = pool.MaxConns
 	}
-
-	// Преобразуем относительный путь в абсолютный
-	absPath, err := filepath.Abs(migrationsDir)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+	if pool.MinConns > 0 {
+		cfg.MinConns = pool.MinConns
 	}
-
-	goose.SetDialect("postgres")
-	if err := goose.Up(db, absPath); err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+	if pool.StatementCacheCapacity > 0 {
+		cfg.ConnConfig.StatementCacheCapacity = pool.StatementCacheCapacity
 	}
-	return nil
+	return pgxpool.NewWithConfig(context.Background(), cfg)
 }
 
 // Close закрывает соединение с базой данных
 func (s *Storage) Close() error {
 	s.log.Info("Closing database connection")
-	return s.db.Close()
+	if s.replicas != nil {
+		s.replicas.close()
+	}
+	err := s.db.Close()
+	s.pool.Close()
+	return err
 }
@@ -0,0 +1,828 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository хранит монеты, цены и журнал аудита в памяти процесса.
+// Реализует тот же контракт, что и Repository для PostgreSQL, и нужен тестам
+// сервиса и обработчиков, которым не требуется база данных.
+type MemoryRepository struct {
+	mu          sync.RWMutex
+	coins       map[string]*models.TrackedCoin  // по символу
+	prices      map[int64][]*models.CryptoPrice // по coin_id, по возрастанию timestamp
+	audit       []*models.AuditEntry            // в порядке записи
+	apiKeys     []*memoryAPIKey                 // в порядке создания
+	quotaUsage  map[string]map[string]int64     // по дню, затем по клиенту
+	watchlists  map[int64]*memoryWatchlist      // по id
+	alertRules  []*models.AlertRule             // в порядке создания
+	alertEvents []*models.AlertEvent            // в порядке записи
+	nextCoinID  int64
+	nextPriceID int64
+	nextListID  int64
+	nextRuleID  int64
+	nextEventID int64
+}
+
+// memoryWatchlist - список пользователя; монеты и доступы хранятся множествами
+type memoryWatchlist struct {
+	list   models.Watchlist
+	coins  map[int64]bool
+	shares map[string]bool
+}
+
+type memoryAPIKey struct {
+	key  models.APIKey
+	hash string
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		coins:      make(map[string]*models.TrackedCoin),
+		prices:     make(map[int64][]*models.CryptoPrice),
+		quotaUsage: make(map[string]map[string]int64),
+		watchlists: make(map[int64]*memoryWatchlist),
+	}
+}
+
+func (r *MemoryRepository) AddCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := copyCoin(r.coins[coin.Symbol])
+	stored, ok := r.coins[coin.Symbol]
+	if !ok {
+		r.nextCoinID++
+		stored = &models.TrackedCoin{ID: r.nextCoinID, Symbol: coin.Symbol}
+		r.coins[coin.Symbol] = stored
+	}
+	stored.CoinMetadata = coin.CoinMetadata
+	stored.Status = models.CoinStatusActive
+
+	return r.writeAudit(models.AuditCoinAdd, stored.ID, stored.Symbol, actor, before, copyCoin(stored))
+}
+
+// RemoveCoin убирает монету из списка наблюдения, сохраняя ее историю цен
+func (r *MemoryRepository) RemoveCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.coins[coin.Symbol]
+	if !ok || stored.Status == models.CoinStatusUntracked {
+		return nil
+	}
+	before := copyCoin(stored)
+	stored.Status = models.CoinStatusUntracked
+
+	return r.writeAudit(models.AuditCoinRemove, stored.ID, stored.Symbol, actor, before, copyCoin(stored))
+}
+
+// PurgeCoin безвозвратно удаляет убранную из списка наблюдения монету вместе с историей цен
+func (r *MemoryRepository) PurgeCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.coins[coin.Symbol]
+	if !ok {
+		return 0, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+	if stored.Status != models.CoinStatusUntracked {
+		return 0, fmt.Errorf("coin %s is still tracked, remove it first", coin.Symbol)
+	}
+	if r.coinWatched(stored.ID) {
+		return 0, fmt.Errorf("coin %s is in users' watchlists: %w", coin.Symbol, models.ErrReferenced)
+	}
+
+	deleted := int64(len(r.prices[stored.ID]))
+	delete(r.prices, stored.ID)
+	delete(r.coins, stored.Symbol)
+	r.deleteAlertRules(func(rule *models.AlertRule) bool { return rule.CoinID == stored.ID })
+
+	after := map[string]int64{"deleted_prices": deleted}
+	if err := r.writeAudit(models.AuditCoinPurge, stored.ID, stored.Symbol, actor, stored, after); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// GetPrice возвращает цену, ближайшую к моменту запроса; при равном расстоянии - более раннюю
+func (r *MemoryRepository) GetPrice(ctx context.Context, coin *models.GetPriceRequest) (*models.CryptoPrice, error) {
+	timestamp := coin.Timestamp
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.coins[coin.Coin]
+	if !ok {
+		return nil, fmt.Errorf("failed to get price: %w", sql.ErrNoRows)
+	}
+	prices := r.prices[stored.ID]
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("failed to get price: %w", sql.ErrNoRows)
+	}
+
+	// Первая цена строго позже момента запроса и последняя не позже него
+	i := sort.Search(len(prices), func(i int) bool { return prices[i].Timestamp > timestamp })
+	nearest := i
+	if i == len(prices) || (i > 0 && timestamp-prices[i-1].Timestamp <= prices[i].Timestamp-timestamp) {
+		nearest = i - 1
+	}
+	price := *prices[nearest]
+	price.Symbol = stored.Symbol
+	return &price, nil
+}
+
+// GetAllCoins возвращает список наблюдения; убранные из него монеты не входят
+func (r *MemoryRepository) GetAllCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	return r.listCoins(func(coin *models.TrackedCoin) bool { return coin.Status != models.CoinStatusUntracked }), nil
+}
+
+// GetActiveCoins возвращает монеты, цены которых нужно опрашивать: активные
+// в общем списке и убранные из него, но оставшиеся в списках пользователей
+func (r *MemoryRepository) GetActiveCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	return r.listCoins(func(coin *models.TrackedCoin) bool {
+		return coin.Status == models.CoinStatusActive ||
+			coin.Status == models.CoinStatusUntracked && r.coinWatched(coin.ID)
+	}), nil
+}
+
+func (r *MemoryRepository) listCoins(match func(*models.TrackedCoin) bool) []*models.TrackedCoin {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var coins []*models.TrackedCoin
+	for _, coin := range r.coins {
+		if match(coin) {
+			coins = append(coins, copyCoin(coin))
+		}
+	}
+	sort.Slice(coins, func(i, j int) bool { return coins[i].Symbol < coins[j].Symbol })
+	return coins
+}
+
+func (r *MemoryRepository) GetCoin(ctx context.Context, symbol string) (*models.TrackedCoin, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coin, ok := r.coins[symbol]
+	if !ok {
+		return nil, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+	return copyCoin(coin), nil
+}
+
+// SetCoinStatus меняет состояние опроса монеты
+func (r *MemoryRepository) SetCoinStatus(ctx context.Context, symbol string, status models.CoinStatus, actor *models.Actor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.coins[symbol]
+	if !ok || stored.Status == models.CoinStatusUntracked {
+		return fmt.Errorf("failed to set coin status: %w", sql.ErrNoRows)
+	}
+	if stored.Status == status {
+		return nil
+	}
+	before := copyCoin(stored)
+	stored.Status = status
+
+	return r.writeAudit(models.AuditCoinStatus, stored.ID, stored.Symbol, actor, before, copyCoin(stored))
+}
+
+// UpdateCoinMetadata меняет заданные в запросе поля метаданных монеты
+func (r *MemoryRepository) UpdateCoinMetadata(ctx context.Context, req *models.UpdateMetadataRequest, actor *models.Actor) (*models.TrackedCoin, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.coins[req.Coin]
+	if !ok {
+		return nil, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+	before := copyCoin(stored)
+	if req.Name != nil {
+		stored.Name = *req.Name
+	}
+	if req.Category != nil {
+		stored.Category = *req.Category
+	}
+	if req.Description != nil {
+		stored.Description = *req.Description
+	}
+	if req.LogoURL != nil {
+		stored.LogoURL = *req.LogoURL
+	}
+	if req.Decimals != nil {
+		stored.Decimals = *req.Decimals
+	}
+
+	after := copyCoin(stored)
+	if err := r.writeAudit(models.AuditCoinMetadata, stored.ID, stored.Symbol, actor, before, after); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// CorrectPrice вручную записывает цену монеты в конкретный момент
+func (r *MemoryRepository) CorrectPrice(ctx context.Context, price *models.CryptoPrice, actor *models.Actor) (*models.CryptoPrice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coin, ok := r.coins[price.Symbol]
+	if !ok {
+		return nil, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+
+	var before *models.CryptoPrice
+	if i, ok := r.findPrice(coin.ID, price.Timestamp); ok {
+		existing := *r.prices[coin.ID][i]
+		existing.Symbol = coin.Symbol
+		before = &existing
+	}
+	after := *r.upsertPrice(coin.ID, price)
+	after.Symbol = coin.Symbol
+
+	if err := r.writeAudit(models.AuditPriceCorrect, coin.ID, coin.Symbol, actor, before, &after); err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
+
+// GetAuditLog возвращает записи журнала аудита от новых к старым
+func (r *MemoryRepository) GetAuditLog(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+
+	var entries []*models.AuditEntry
+	for i := len(r.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entry := r.audit[i]
+		switch {
+		case filter.Coin != "" && entry.Symbol != filter.Coin,
+			filter.Actor != "" && entry.Actor != filter.Actor,
+			filter.From > 0 && entry.Timestamp < filter.From,
+			filter.To > 0 && entry.Timestamp >= filter.To:
+			continue
+		}
+		copied := *entry
+		entries = append(entries, &copied)
+	}
+	return entries, nil
+}
+
+func (r *MemoryRepository) AddNewPrice(ctx context.Context, coin *models.CryptoPrice) error {
+	errs, err := r.AddNewPrices(ctx, []*models.CryptoPrice{coin})
+	if err != nil {
+		return err
+	}
+	return errs[0]
+}
+
+// AddNewPrices записывает цены за цикл опроса.
+// Возвращает ошибки по строкам в порядке входного среза (nil - строка записана).
+func (r *MemoryRepository) AddNewPrices(ctx context.Context, prices []*models.CryptoPrice) ([]error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := make([]error, len(prices))
+	for i, price := range prices {
+		if !r.coinExists(price.CoinID) {
+			errs[i] = fmt.Errorf("coin with id %d not found", price.CoinID)
+			continue
+		}
+		r.upsertPrice(price.CoinID, price)
+	}
+	return errs, nil
+}
+
+// GetPriceBuckets агрегирует цены монеты по интервалам с теми же границами, что и в PostgreSQL
+func (r *MemoryRepository) GetPriceBuckets(ctx context.Context, q *models.BucketQuery) ([]*models.PriceBucket, error) {
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coin, ok := r.coins[q.Symbol]
+	if !ok {
+		return nil, nil
+	}
+	fold := bucketFold{interval: q.Interval, loc: loc}
+	for _, price := range r.prices[coin.ID] {
+		if price.Timestamp >= q.From && price.Timestamp < q.To {
+			fold.add(price.Price, price.Timestamp)
+		}
+	}
+	return fold.finish(), nil
+}
+
+func (r *MemoryRepository) coinExists(id int64) bool {
+	for _, coin := range r.coins {
+		if coin.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// findPrice возвращает позицию цены монеты coinID в момент timestamp
+func (r *MemoryRepository) findPrice(coinID int64, timestamp models.Timestamp) (int, bool) {
+	prices := r.prices[coinID]
+	i := sort.Search(len(prices), func(i int) bool { return prices[i].Timestamp >= timestamp })
+	return i, i < len(prices) && prices[i].Timestamp == timestamp
+}
+
+// upsertPrice добавляет цену или заменяет существующую цену в тот же момент
+func (r *MemoryRepository) upsertPrice(coinID int64, price *models.CryptoPrice) *models.CryptoPrice {
+	i, ok := r.findPrice(coinID, price.Timestamp)
+	if ok {
+		r.prices[coinID][i].Price = price.Price
+		return r.prices[coinID][i]
+	}
+
+	r.nextPriceID++
+	stored := &models.CryptoPrice{ID: r.nextPriceID, CoinID: coinID, Price: price.Price, Timestamp: price.Timestamp}
+	prices := append(r.prices[coinID], nil)
+	copy(prices[i+1:], prices[i:])
+	prices[i] = stored
+	r.prices[coinID] = prices
+	return stored
+}
+
+func (r *MemoryRepository) writeAudit(action models.AuditAction, coinID int64, symbol string, actor *models.Actor, before, after any) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+	r.audit = append(r.audit, &models.AuditEntry{
+		ID:        int64(len(r.audit) + 1),
+		Action:    action,
+		CoinID:    &coinID,
+		Symbol:    symbol,
+		Actor:     actor.Name,
+		RequestID: actor.RequestID,
+		Before:    memoryAuditRaw(beforeJSON),
+		After:     memoryAuditRaw(afterJSON),
+		Timestamp: models.TimestampOf(time.Now()),
+	})
+	return nil
+}
+
+// memoryAuditRaw переводит результат auditJSON в вид, который возвращают хранилища с SQL
+func memoryAuditRaw(v any) json.RawMessage {
+	if v == nil {
+		return auditRaw(nil)
+	}
+	return auditRaw([]byte(v.(string)))
+}
+
+// CreateAPIKey сохраняет ключ по его хешу и заполняет key.ID
+func (r *MemoryRepository) CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.apiKeys {
+		if stored.hash == hash {
+			return fmt.Errorf("failed to create api key: %w: key hash", models.ErrDuplicate)
+		}
+		// Имена уникальны без учета регистра, как в индексе по LOWER(name) в базах
+		if strings.EqualFold(stored.key.Name, key.Name) {
+			return fmt.Errorf("failed to create api key: %w: name %q", models.ErrDuplicate, key.Name)
+		}
+	}
+	key.ID = int64(len(r.apiKeys) + 1)
+	stored := &memoryAPIKey{key: *key, hash: hash}
+	stored.key.Scopes = append([]models.Scope(nil), key.Scopes...)
+	r.apiKeys = append(r.apiKeys, stored)
+	return nil
+}
+
+// GetAPIKeyByHash ищет действующий ключ по хешу
+func (r *MemoryRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, stored := range r.apiKeys {
+		if stored.hash == hash && stored.key.RevokedAt == nil {
+			copied := stored.key
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get api key: %w", sql.ErrNoRows)
+}
+
+// ListAPIKeys возвращает все ключи, включая отозванные, в порядке создания
+func (r *MemoryRepository) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*models.APIKey, len(r.apiKeys))
+	for i, stored := range r.apiKeys {
+		copied := stored.key
+		keys[i] = &copied
+	}
+	return keys, nil
+}
+
+func (r *MemoryRepository) RevokeAPIKey(ctx context.Context, id int64, revokedBy string, at models.Timestamp) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id < 1 || id > int64(len(r.apiKeys)) || r.apiKeys[id-1].key.RevokedAt != nil {
+		return fmt.Errorf("failed to revoke api key: %w", sql.ErrNoRows)
+	}
+	stored := r.apiKeys[id-1]
+	stored.key.RevokedBy = revokedBy
+	stored.key.RevokedAt = &at
+	return nil
+}
+
+// AddQuotaUsage прибавляет запросы клиентов за сутки day (YYYY-MM-DD, UTC)
+func (r *MemoryRepository) AddQuotaUsage(ctx context.Context, day string, usage map[string]int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.quotaUsage[day] == nil {
+		r.quotaUsage[day] = make(map[string]int64)
+	}
+	for client, requests := range usage {
+		r.quotaUsage[day][client] += requests
+	}
+	return nil
+}
+
+// GetQuotaUsage возвращает запросы клиентов за сутки day
+func (r *MemoryRepository) GetQuotaUsage(ctx context.Context, day string) (map[string]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	usage := make(map[string]int64, len(r.quotaUsage[day]))
+	for client, requests := range r.quotaUsage[day] {
+		usage[client] = requests
+	}
+	return usage, nil
+}
+
+// DeleteQuotaUsageBefore удаляет учет запросов за сутки раньше day
+func (r *MemoryRepository) DeleteQuotaUsageBefore(ctx context.Context, day string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for d, usage := range r.quotaUsage {
+		if d < day {
+			deleted += int64(len(usage))
+			delete(r.quotaUsage, d)
+		}
+	}
+	return deleted, nil
+}
+
+func copyCoin(coin *models.TrackedCoin) *models.TrackedCoin {
+	if coin == nil {
+		return nil
+	}
+	copied := *coin
+	return &copied
+}
+
+// CreateWatchlist создает пустой список и заполняет w.ID
+func (r *MemoryRepository) CreateWatchlist(ctx context.Context, w *models.Watchlist) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.watchlists {
+		if stored.list.Owner == w.Owner && stored.list.Name == w.Name {
+			return fmt.Errorf("failed to create watchlist %q: %w", w.Name, models.ErrDuplicate)
+		}
+	}
+	r.nextListID++
+	w.ID = r.nextListID
+	r.watchlists[w.ID] = &memoryWatchlist{
+		list:   models.Watchlist{ID: w.ID, Owner: w.Owner, Name: w.Name, CreatedAt: w.CreatedAt},
+		coins:  make(map[int64]bool),
+		shares: make(map[string]bool),
+	}
+	return nil
+}
+
+func (r *MemoryRepository) GetWatchlist(ctx context.Context, id int64) (*models.Watchlist, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.watchlists[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get watchlist: %w", sql.ErrNoRows)
+	}
+	return r.copyWatchlist(stored), nil
+}
+
+// ListWatchlists возвращает списки пользователя и открытые ему списки в порядке создания
+func (r *MemoryRepository) ListWatchlists(ctx context.Context, user string) ([]*models.Watchlist, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var watchlists []*models.Watchlist
+	for _, stored := range r.watchlists {
+		if stored.list.Owner == user || stored.shares[user] {
+			watchlists = append(watchlists, r.copyWatchlist(stored))
+		}
+	}
+	sort.Slice(watchlists, func(i, j int) bool { return watchlists[i].ID < watchlists[j].ID })
+	return watchlists, nil
+}
+
+func (r *MemoryRepository) RenameWatchlist(ctx context.Context, id int64, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.watchlists[id]
+	if !ok {
+		return fmt.Errorf("failed to rename watchlist: %w", sql.ErrNoRows)
+	}
+	for _, other := range r.watchlists {
+		if other != stored && other.list.Owner == stored.list.Owner && other.list.Name == name {
+			return fmt.Errorf("failed to rename watchlist to %q: %w", name, models.ErrDuplicate)
+		}
+	}
+	stored.list.Name = name
+	return nil
+}
+
+func (r *MemoryRepository) DeleteWatchlist(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.watchlists[id]; !ok {
+		return fmt.Errorf("failed to delete watchlist: %w", sql.ErrNoRows)
+	}
+	delete(r.watchlists, id)
+	return nil
+}
+
+// AddWatchlistCoin добавляет монету в список; монета, которой нет в справочнике,
+// добавляется в него вне общего списка. Заполняет coin.ID.
+func (r *MemoryRepository) AddWatchlistCoin(ctx context.Context, id int64, coin *models.TrackedCoin) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, ok := r.watchlists[id]
+	if !ok {
+		return fmt.Errorf("failed to add coin to watchlist: %w", sql.ErrNoRows)
+	}
+	stored, ok := r.coins[coin.Symbol]
+	if !ok {
+		r.nextCoinID++
+		stored = &models.TrackedCoin{ID: r.nextCoinID, Symbol: coin.Symbol, Status: models.CoinStatusUntracked, CoinMetadata: coin.CoinMetadata}
+		r.coins[coin.Symbol] = stored
+	}
+	list.coins[stored.ID] = true
+	coin.ID = stored.ID
+	return nil
+}
+
+func (r *MemoryRepository) RemoveWatchlistCoin(ctx context.Context, id, coinID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, ok := r.watchlists[id]
+	if !ok || !list.coins[coinID] {
+		return fmt.Errorf("failed to remove coin from watchlist: %w", sql.ErrNoRows)
+	}
+	delete(list.coins, coinID)
+	return nil
+}
+
+func (r *MemoryRepository) ShareWatchlist(ctx context.Context, id int64, user string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, ok := r.watchlists[id]
+	if !ok {
+		return fmt.Errorf("failed to share watchlist: %w", sql.ErrNoRows)
+	}
+	list.shares[user] = true
+	return nil
+}
+
+func (r *MemoryRepository) UnshareWatchlist(ctx context.Context, id int64, user string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, ok := r.watchlists[id]
+	if !ok || !list.shares[user] {
+		return fmt.Errorf("failed to unshare watchlist: %w", sql.ErrNoRows)
+	}
+	delete(list.shares, user)
+	return nil
+}
+
+// IsCoinWatched сообщает, есть ли монета хотя бы в одном списке пользователя
+func (r *MemoryRepository) IsCoinWatched(ctx context.Context, coinID int64) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.coinWatched(coinID), nil
+}
+
+func (r *MemoryRepository) coinWatched(coinID int64) bool {
+	for _, list := range r.watchlists {
+		if list.coins[coinID] {
+			return true
+		}
+	}
+	return false
+}
+
+// copyWatchlist возвращает копию списка с монетами по символу и доступами по имени
+func (r *MemoryRepository) copyWatchlist(stored *memoryWatchlist) *models.Watchlist {
+	w := stored.list
+	w.SharedWith = make([]string, 0, len(stored.shares))
+	for user := range stored.shares {
+		w.SharedWith = append(w.SharedWith, user)
+	}
+	sort.Strings(w.SharedWith)
+	w.Coins = nil
+	for _, coin := range r.coins {
+		if stored.coins[coin.ID] {
+			w.Coins = append(w.Coins, copyCoin(coin))
+		}
+	}
+	sort.Slice(w.Coins, func(i, j int) bool { return w.Coins[i].Symbol < w.Coins[j].Symbol })
+	return &w
+}
+
+// CreateAlertRule создает правило и заполняет rule.ID
+func (r *MemoryRepository) CreateAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.coinExists(rule.CoinID) {
+		return fmt.Errorf("failed to create alert rule: coin %d does not exist", rule.CoinID)
+	}
+	r.nextRuleID++
+	rule.ID = r.nextRuleID
+	rule.Version = 1
+	stored := *rule
+	r.alertRules = append(r.alertRules, &stored)
+	return nil
+}
+
+func (r *MemoryRepository) GetAlertRule(ctx context.Context, id int64) (*models.AlertRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rule := range r.alertRules {
+		if rule.ID == id {
+			return copyAlertRule(rule), nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get alert rule: %w", sql.ErrNoRows)
+}
+
+// ListAlertRules возвращает правила владельца owner, а при пустом owner - все правила
+func (r *MemoryRepository) ListAlertRules(ctx context.Context, owner string) ([]*models.AlertRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var rules []*models.AlertRule
+	for _, rule := range r.alertRules {
+		if owner == "" || rule.Owner == owner {
+			rules = append(rules, copyAlertRule(rule))
+		}
+	}
+	return rules, nil
+}
+
+func (r *MemoryRepository) DeleteAlertRule(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.deleteAlertRules(func(rule *models.AlertRule) bool { return rule.ID == id }) == 0 {
+		return fmt.Errorf("failed to delete alert rule: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// UpdateAlertState условно по версии записывает состояние правила и срабатывание event
+func (r *MemoryRepository) UpdateAlertState(ctx context.Context, rule *models.AlertRule, event *models.AlertEvent) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var stored *models.AlertRule
+	for _, candidate := range r.alertRules {
+		if candidate.ID == rule.ID {
+			stored = candidate
+		}
+	}
+	if stored == nil || stored.Version != rule.Version {
+		return false, nil
+	}
+	stored.State = rule.State
+	stored.StateChangedAt = rule.StateChangedAt
+	stored.LastFiredAt = copyTimestamp(rule.LastFiredAt)
+	stored.Version++
+	rule.Version = stored.Version
+
+	if event != nil {
+		r.nextEventID++
+		event.ID = r.nextEventID
+		recorded := *event
+		r.alertEvents = append(r.alertEvents, &recorded)
+	}
+	return true, nil
+}
+
+// GetAlertEvents возвращает историю срабатываний от новых к старым
+func (r *MemoryRepository) GetAlertEvents(ctx context.Context, filter *models.AlertEventFilter) ([]*models.AlertEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make(map[int64]*models.AlertRule, len(r.alertRules))
+	for _, rule := range r.alertRules {
+		rules[rule.ID] = rule
+	}
+	var events []*models.AlertEvent
+	for _, event := range r.alertEvents {
+		rule := rules[event.RuleID]
+		if rule == nil ||
+			(filter.Owner != "" && rule.Owner != filter.Owner) ||
+			(filter.RuleID > 0 && event.RuleID != filter.RuleID) ||
+			(filter.From > 0 && event.FiredAt < filter.From) ||
+			(filter.To > 0 && event.FiredAt >= filter.To) {
+			continue
+		}
+		copied := *event
+		copied.Coin = rule.Coin
+		copied.Condition = rule.Condition
+		events = append(events, &copied)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].FiredAt != events[j].FiredAt {
+			return events[i].FiredAt > events[j].FiredAt
+		}
+		return events[i].ID > events[j].ID
+	})
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAlertEventsLimit
+	}
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// deleteAlertRules удаляет подходящие правила вместе с историей их срабатываний и возвращает их число
+func (r *MemoryRepository) deleteAlertRules(match func(*models.AlertRule) bool) int {
+	deleted := make(map[int64]bool)
+	kept := r.alertRules[:0]
+	for _, rule := range r.alertRules {
+		if match(rule) {
+			deleted[rule.ID] = true
+		} else {
+			kept = append(kept, rule)
+		}
+	}
+	r.alertRules = kept
+	if len(deleted) > 0 {
+		events := r.alertEvents[:0]
+		for _, event := range r.alertEvents {
+			if !deleted[event.RuleID] {
+				events = append(events, event)
+			}
+		}
+		r.alertEvents = events
+	}
+	return len(deleted)
+}
+
+func copyAlertRule(rule *models.AlertRule) *models.AlertRule {
+	copied := *rule
+	copied.LastFiredAt = copyTimestamp(rule.LastFiredAt)
+	return &copied
+}
+
+func copyTimestamp(ts *models.Timestamp) *models.Timestamp {
+	if ts == nil {
+		return nil
+	}
+	copied := *ts
+	return &copied
+}
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"awesomeProject/internal/repository/repotest"
+	"testing"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return NewMemoryRepository()
+	})
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+)
+
+// Миграции каждого хранилища лежат в migrations/<хранилище> и встроены в бинарный файл
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migrator применяет и откатывает встроенные миграции схемы хранилища
+type Migrator struct {
+	db       *sql.DB
+	pool     *pgxpool.Pool // nil - хранилище без пула pgx
+	provider *goose.Provider
+	log      *zap.Logger
+}
+
+func newMigrator(db *sql.DB, dialect goose.Dialect, dir string, log *zap.Logger) (*Migrator, error) {
+	migrations, err := fs.Sub(migrationFiles, "migrations/"+dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrations: %w", err)
+	}
+	provider, err := goose.NewProvider(dialect, db, migrations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration provider: %w", err)
+	}
+	return &Migrator{db: db, provider: provider, log: log}, nil
+}
+
+// NewPostgresMigrator подключается к PostgreSQL для управления миграциями
+func NewPostgresMigrator(user string, password string, host string, port string, dbname string, sslmode string, log *zap.Logger) (*Migrator, error) {
+	log = log.With(zap.String("type", "Migrator"))
+	pool, err := openPostgres(user, password, host, port, dbname, sslmode, PoolConfig{}, log)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := newMigrator(stdlib.OpenDBFromPool(pool), goose.DialectPostgres, "postgres", log)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	migrator.pool = pool
+	return migrator, nil
+}
+
+// NewMySQLMigrator подключается к MySQL/MariaDB для управления миграциями
+func NewMySQLMigrator(user string, password string, host string, port string, dbname string, log *zap.Logger) (*Migrator, error) {
+	log = log.With(zap.String("type", "Migrator"))
+	db, err := openMySQL(user, password, host, port, dbname, log)
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(db, goose.DialectMySQL, "mysql", log)
+}
+
+// NewSQLiteMigrator открывает базу SQLite для управления миграциями
+func NewSQLiteMigrator(path string, log *zap.Logger) (*Migrator, error) {
+	log = log.With(zap.String("type", "Migrator"))
+	db, err := openSQLite(path, log)
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(db, goose.DialectSQLite3, "sqlite", log)
+}
+
+// Up применяет все неприменные миграции
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	results, err := m.provider.Up(ctx)
+	if err != nil {
+		return results, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	for _, result := range results {
+		m.log.Info("Applied migration", zap.String("migration", result.Source.Path), zap.Duration("duration", result.Duration))
+	}
+	return results, nil
+}
+
+// Down откатывает последнюю примененную миграцию
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	result, err := m.provider.Down(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to roll back migration: %w", err)
+	}
+	m.log.Info("Rolled back migration", zap.String("migration", result.Source.Path), zap.Duration("duration", result.Duration))
+	return result, nil
+}
+
+// Redo откатывает и заново применяет последнюю примененную миграцию
+func (m *Migrator) Redo(ctx context.Context) ([]*goose.MigrationResult, error) {
+	down, err := m.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+	up, err := m.provider.UpByOne(ctx)
+	if err != nil {
+		return []*goose.MigrationResult{down}, fmt.Errorf("failed to reapply migration: %w", err)
+	}
+	m.log.Info("Applied migration", zap.String("migration", up.Source.Path), zap.Duration("duration", up.Duration))
+	return []*goose.MigrationResult{down, up}, nil
+}
+
+// Status возвращает состояние всех встроенных миграций
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration status: %w", err)
+	}
+	return statuses, nil
+}
+
+// Close закрывает соединение с базой данных
+func (m *Migrator) Close() error {
+	err := m.db.Close()
+	if m.pool != nil {
+		m.pool.Close()
+	}
+	return err
+}
+
+// migrate применяет миграции при запуске хранилища
+func migrate(db *sql.DB, dialect goose.Dialect, dir string, log *zap.Logger) error {
+	log.Info("Starting database migrations")
+	migrator, err := newMigrator(db, dialect, dir, log)
+	if err != nil {
+		log.Error("Failed to run migrations", zap.Error(err))
+		return err
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		log.Error("Failed to run migrations", zap.Error(err))
+		return err
+	}
+	log.Info("Successfully migrated database")
+	return nil
+}
@@ -0,0 +1,62 @@
+-- +goose Up
+-- Схема MySQL/MariaDB повторяет итоговую схему PostgreSQL.
+CREATE TABLE tracked_coins (
+                               id INT AUTO_INCREMENT PRIMARY KEY,
+                               symbol VARCHAR(32) NOT NULL UNIQUE,
+                               created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                               name VARCHAR(255) NOT NULL DEFAULT '',
+                               provider_id VARCHAR(255) NOT NULL DEFAULT '',
+                               category VARCHAR(255) NOT NULL DEFAULT '',
+                               description TEXT NOT NULL,
+                               logo_url VARCHAR(1024) NOT NULL DEFAULT '',
+                               decimals INT NOT NULL DEFAULT 0,
+                               status VARCHAR(16) NOT NULL DEFAULT 'active'
+                                   CHECK (status IN ('active', 'paused', 'delisted', 'untracked')),
+                               updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                               untracked_at TIMESTAMP NULL,
+                               INDEX idx_tracked_coins_status (status)
+) ENGINE = InnoDB;
+
+CREATE TABLE coin_prices (
+                             id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                             coin_id INT NOT NULL,
+                             price DECIMAL(40,18) NOT NULL,
+                             timestamp BIGINT NOT NULL,
+                             UNIQUE KEY coin_prices_coin_id_timestamp_key (coin_id, timestamp),
+                             FOREIGN KEY (coin_id) REFERENCES tracked_coins(id) ON DELETE CASCADE
+) ENGINE = InnoDB;
+
+-- BEFORE - зарезервированное слово MySQL, поэтому колонки состояния заключены в обратные кавычки
+CREATE TABLE audit_log (
+                           id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                           action VARCHAR(32) NOT NULL,
+                           coin_id INT,
+                           symbol VARCHAR(32) NOT NULL,
+                           actor VARCHAR(255) NOT NULL,
+                           request_id VARCHAR(64) NOT NULL DEFAULT '',
+                           `before` JSON,
+                           `after` JSON,
+                           created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                           INDEX idx_audit_log_symbol (symbol, created_at),
+                           INDEX idx_audit_log_actor (actor, created_at),
+                           INDEX idx_audit_log_created_at (created_at)
+) ENGINE = InnoDB;
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_update
+    BEFORE UPDATE ON audit_log
+    FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_delete
+    BEFORE DELETE ON audit_log
+    FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS coin_prices;
+DROP TABLE IF EXISTS tracked_coins;
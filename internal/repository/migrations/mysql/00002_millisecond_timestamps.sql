@@ -0,0 +1,15 @@
+-- +goose Up
+-- Момент цены хранится в Unix-миллисекундах, время записей журнала - с миллисекундами.
+-- Порядок обновления исключает временные совпадения в UNIQUE(coin_id, timestamp).
+UPDATE coin_prices SET timestamp = timestamp * 1000 ORDER BY timestamp DESC;
+ALTER TABLE audit_log MODIFY created_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3);
+
+-- +goose Down
+-- Возврат к Unix-секундам: из тиков одной секунды остается последний
+DELETE earlier FROM coin_prices earlier
+JOIN coin_prices later
+  ON later.coin_id = earlier.coin_id
+ AND FLOOR(later.timestamp / 1000) = FLOOR(earlier.timestamp / 1000)
+ AND later.timestamp > earlier.timestamp;
+UPDATE coin_prices SET timestamp = FLOOR(timestamp / 1000) ORDER BY timestamp ASC;
+ALTER TABLE audit_log MODIFY created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
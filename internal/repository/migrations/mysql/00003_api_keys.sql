@@ -0,0 +1,16 @@
+-- +goose Up
+-- API-ключи хранятся только хешами SHA-256; время - в Unix-миллисекундах
+CREATE TABLE api_keys (
+                          id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                          name VARCHAR(100) NOT NULL,
+                          prefix VARCHAR(16) NOT NULL,
+                          key_hash CHAR(64) NOT NULL UNIQUE,
+                          scopes VARCHAR(100) NOT NULL,
+                          created_by VARCHAR(100) NOT NULL,
+                          created_at BIGINT NOT NULL,
+                          revoked_by VARCHAR(100) NOT NULL DEFAULT '',
+                          revoked_at BIGINT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- Именованные списки наблюдения пользователей поверх общего справочника tracked_coins.
+-- Монета опрашивается, пока она в общем списке или хотя бы в одном списке пользователя.
+CREATE TABLE watchlists (
+                            id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                            owner VARCHAR(100) NOT NULL,
+                            name VARCHAR(100) NOT NULL,
+                            created_at BIGINT NOT NULL,
+                            UNIQUE KEY watchlists_owner_name_key (owner, name)
+) ENGINE = InnoDB;
+
+-- Монета, которая есть в списках, не удаляется безвозвратно
+CREATE TABLE watchlist_coins (
+                                 watchlist_id BIGINT NOT NULL,
+                                 coin_id INT NOT NULL,
+                                 PRIMARY KEY (watchlist_id, coin_id),
+                                 INDEX idx_watchlist_coins_coin_id (coin_id),
+                                 FOREIGN KEY (watchlist_id) REFERENCES watchlists(id) ON DELETE CASCADE,
+                                 FOREIGN KEY (coin_id) REFERENCES tracked_coins(id)
+) ENGINE = InnoDB;
+
+-- Пользователи, которым владелец открыл список для чтения
+CREATE TABLE watchlist_shares (
+                                  watchlist_id BIGINT NOT NULL,
+                                  username VARCHAR(100) NOT NULL,
+                                  PRIMARY KEY (watchlist_id, username),
+                                  INDEX idx_watchlist_shares_username (username),
+                                  FOREIGN KEY (watchlist_id) REFERENCES watchlists(id) ON DELETE CASCADE
+) ENGINE = InnoDB;
+
+-- +goose Down
+DROP TABLE IF EXISTS watchlist_shares;
+DROP TABLE IF EXISTS watchlist_coins;
+DROP TABLE IF EXISTS watchlists;
@@ -0,0 +1,38 @@
+-- +goose Up
+-- Правила оповещений о цене и история их срабатываний. Состояние правила меняется
+-- условным обновлением по version, поэтому срабатывание записывается один раз,
+-- даже если тики проверяют несколько экземпляров сервиса.
+CREATE TABLE alert_rules (
+                             id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                             owner VARCHAR(100) NOT NULL,
+                             coin_id INT NOT NULL,
+                             kind VARCHAR(32) NOT NULL
+                                 CHECK (kind IN ('price_above', 'price_below', 'change_above', 'change_below', 'cross_above_ma', 'cross_below_ma')),
+                             threshold DECIMAL(40,18) NOT NULL,
+                             window_ms BIGINT NOT NULL DEFAULT 0,
+                             hysteresis DECIMAL(40,18) NOT NULL,
+                             cooldown_ms BIGINT NOT NULL,
+                             state VARCHAR(16) NOT NULL DEFAULT 'armed'
+                                 CHECK (state IN ('armed', 'fired', 'cooling_down')),
+                             state_changed_at BIGINT NOT NULL,
+                             last_fired_at BIGINT NULL,
+                             version BIGINT NOT NULL DEFAULT 1,
+                             created_at BIGINT NOT NULL,
+                             INDEX idx_alert_rules_owner (owner),
+                             INDEX idx_alert_rules_coin_id (coin_id),
+                             FOREIGN KEY (coin_id) REFERENCES tracked_coins(id) ON DELETE CASCADE
+) ENGINE = InnoDB;
+
+CREATE TABLE alert_events (
+                              id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                              rule_id BIGINT NOT NULL,
+                              price DECIMAL(40,18) NOT NULL,
+                              value DECIMAL(40,18) NOT NULL,
+                              fired_at BIGINT NOT NULL,
+                              INDEX idx_alert_events_rule_id_fired_at (rule_id, fired_at),
+                              FOREIGN KEY (rule_id) REFERENCES alert_rules(id) ON DELETE CASCADE
+) ENGINE = InnoDB;
+
+-- +goose Down
+DROP TABLE IF EXISTS alert_events;
+DROP TABLE IF EXISTS alert_rules;
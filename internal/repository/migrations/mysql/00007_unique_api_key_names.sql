@@ -0,0 +1,12 @@
+-- +goose Up
+-- Имя ключа - владелец списков и правил, поэтому оно уникально. Повторные имена и имена,
+-- зарезервированные за admin_token и запросами без ключа, получают суффикс #<id>; списки и правила
+-- остаются за первым ключом с этим именем.
+UPDATE api_keys k
+    LEFT JOIN (SELECT name, MIN(id) AS id FROM api_keys GROUP BY name) f ON f.name = k.name
+SET k.name = CONCAT(LEFT(k.name, 80), '#', k.id)
+WHERE LOWER(k.name) IN ('admin', 'anonymous') OR k.id > f.id;
+CREATE UNIQUE INDEX idx_api_keys_name ON api_keys (name);
+
+-- +goose Down
+DROP INDEX idx_api_keys_name ON api_keys;
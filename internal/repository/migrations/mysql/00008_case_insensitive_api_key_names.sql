@@ -0,0 +1,16 @@
+-- +goose Up
+-- Имена ключей уникальны без учета регистра при любой сортировке колонки. Имена, отличающиеся
+-- от более раннего ключа только регистром, получают суффикс #<id>; списки и правила остаются
+-- за первым ключом. Индекс строится по вычисляемой колонке: функциональных индексов нет в MariaDB.
+UPDATE api_keys k
+    JOIN (SELECT LOWER(name) AS name, MIN(id) AS id FROM api_keys GROUP BY LOWER(name)) f ON f.name = LOWER(k.name)
+SET k.name = CONCAT(LEFT(k.name, 80), '#', k.id)
+WHERE k.id > f.id;
+DROP INDEX idx_api_keys_name ON api_keys;
+ALTER TABLE api_keys ADD COLUMN name_lower VARCHAR(100) AS (LOWER(name)) STORED;
+CREATE UNIQUE INDEX idx_api_keys_name_lower ON api_keys (name_lower);
+
+-- +goose Down
+DROP INDEX idx_api_keys_name_lower ON api_keys;
+ALTER TABLE api_keys DROP COLUMN name_lower;
+CREATE UNIQUE INDEX idx_api_keys_name ON api_keys (name);
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Поиск цены на момент времени идет по составному индексу (coin_id, timestamp),
+-- который создается ограничением UNIQUE(coin_id, timestamp). Отдельный индекс
+-- по coin_id является его префиксом и только замедляет вставку.
+DROP INDEX IF EXISTS idx_coin_prices_coin_id;
+
+-- +goose Down
+CREATE INDEX idx_coin_prices_coin_id ON coin_prices(coin_id);
@@ -0,0 +1,76 @@
+-- +goose Up
+-- Переводим coin_prices на помесячное секционирование по timestamp (Unix-секунды, UTC).
+-- Старые данные удаляются отсоединением секций вместо DELETE.
+ALTER TABLE coin_prices RENAME TO coin_prices_old;
+ALTER TABLE coin_prices_old RENAME CONSTRAINT coin_prices_coin_id_timestamp_key TO coin_prices_old_coin_id_timestamp_key;
+ALTER SEQUENCE coin_prices_id_seq RENAME TO coin_prices_old_id_seq;
+
+CREATE TABLE coin_prices (
+                             id BIGSERIAL NOT NULL,
+                             coin_id INTEGER NOT NULL REFERENCES tracked_coins(id) ON DELETE CASCADE,
+                             price DECIMAL(20,8) NOT NULL,
+                             timestamp BIGINT NOT NULL,
+                             UNIQUE(coin_id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+-- Секция по умолчанию принимает цены, для которых месячная секция еще не создана
+CREATE TABLE coin_prices_default PARTITION OF coin_prices DEFAULT;
+
+-- Секции с первого месяца истории до двух месяцев вперед
+-- +goose StatementBegin
+DO $$
+DECLARE
+    month_start TIMESTAMP;
+    last_month  TIMESTAMP := date_trunc('month', now() AT TIME ZONE 'UTC') + INTERVAL '2 months';
+BEGIN
+    SELECT date_trunc('month', to_timestamp(MIN(timestamp)) AT TIME ZONE 'UTC')
+    INTO month_start
+    FROM coin_prices_old;
+
+    IF month_start IS NULL OR month_start > last_month THEN
+        month_start := date_trunc('month', now() AT TIME ZONE 'UTC');
+    END IF;
+
+    WHILE month_start <= last_month LOOP
+        EXECUTE format(
+            'CREATE TABLE %I PARTITION OF coin_prices FOR VALUES FROM (%s) TO (%s)',
+            'coin_prices_' || to_char(month_start, 'YYYYMM'),
+            EXTRACT(EPOCH FROM month_start AT TIME ZONE 'UTC')::BIGINT,
+            EXTRACT(EPOCH FROM (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC')::BIGINT
+        );
+        month_start := month_start + INTERVAL '1 month';
+    END LOOP;
+END
+$$;
+-- +goose StatementEnd
+
+INSERT INTO coin_prices (id, coin_id, price, timestamp)
+SELECT id, coin_id, price, timestamp
+FROM coin_prices_old;
+
+SELECT setval('coin_prices_id_seq', COALESCE((SELECT MAX(id) FROM coin_prices), 0) + 1, false);
+
+DROP TABLE coin_prices_old;
+
+-- +goose Down
+ALTER TABLE coin_prices RENAME TO coin_prices_partitioned;
+ALTER TABLE coin_prices_partitioned RENAME CONSTRAINT coin_prices_coin_id_timestamp_key TO coin_prices_partitioned_coin_id_timestamp_key;
+ALTER SEQUENCE coin_prices_id_seq RENAME TO coin_prices_partitioned_id_seq;
+
+CREATE TABLE coin_prices (
+                             id SERIAL PRIMARY KEY,
+                             coin_id INTEGER NOT NULL REFERENCES tracked_coins(id) ON DELETE CASCADE,
+                             price DECIMAL(20,8) NOT NULL,
+                             timestamp BIGINT NOT NULL,
+                             UNIQUE(coin_id, timestamp)
+);
+
+INSERT INTO coin_prices (id, coin_id, price, timestamp)
+SELECT id, coin_id, price, timestamp
+FROM coin_prices_partitioned;
+
+SELECT setval('coin_prices_id_seq', COALESCE((SELECT MAX(id) FROM coin_prices), 0) + 1, false);
+
+DROP TABLE coin_prices_partitioned;
+
+CREATE INDEX idx_coin_prices_timestamp ON coin_prices(timestamp);
@@ -0,0 +1,27 @@
+-- +goose Up
+-- Метаданные монеты от провайдера и состояние опроса
+ALTER TABLE tracked_coins
+    ADD COLUMN name        VARCHAR(100)  NOT NULL DEFAULT '',
+    ADD COLUMN provider_id VARCHAR(100)  NOT NULL DEFAULT '',
+    ADD COLUMN category    VARCHAR(100)  NOT NULL DEFAULT '',
+    ADD COLUMN description TEXT          NOT NULL DEFAULT '',
+    ADD COLUMN logo_url    VARCHAR(500)  NOT NULL DEFAULT '',
+    ADD COLUMN decimals    INTEGER       NOT NULL DEFAULT 0,
+    ADD COLUMN status      VARCHAR(16)   NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'paused', 'delisted')),
+    ADD COLUMN updated_at  TIMESTAMP     DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_tracked_coins_status ON tracked_coins(status);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_tracked_coins_status;
+
+ALTER TABLE tracked_coins
+    DROP COLUMN name,
+    DROP COLUMN provider_id,
+    DROP COLUMN category,
+    DROP COLUMN description,
+    DROP COLUMN logo_url,
+    DROP COLUMN decimals,
+    DROP COLUMN status,
+    DROP COLUMN updated_at;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Удаление монеты из списка наблюдения больше не удаляет ее историю цен
+ALTER TABLE tracked_coins DROP CONSTRAINT tracked_coins_status_check;
+ALTER TABLE tracked_coins
+    ADD CONSTRAINT tracked_coins_status_check
+        CHECK (status IN ('active', 'paused', 'delisted', 'untracked')),
+    ADD COLUMN untracked_at TIMESTAMP;
+
+-- +goose Down
+-- Убранные из списка монеты остаются приостановленными вместе с историей цен
+UPDATE tracked_coins SET status = 'paused' WHERE status = 'untracked';
+ALTER TABLE tracked_coins DROP CONSTRAINT tracked_coins_status_check;
+ALTER TABLE tracked_coins
+    ADD CONSTRAINT tracked_coins_status_check
+        CHECK (status IN ('active', 'paused', 'delisted')),
+    DROP COLUMN untracked_at;
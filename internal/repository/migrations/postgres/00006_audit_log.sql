@@ -0,0 +1,38 @@
+-- +goose Up
+-- Журнал изменений списка наблюдения и данных. Только добавление записей:
+-- монета может быть удалена, поэтому внешнего ключа на tracked_coins нет.
+CREATE TABLE audit_log (
+                           id BIGSERIAL PRIMARY KEY,
+                           action VARCHAR(32) NOT NULL,
+                           coin_id INTEGER,
+                           symbol VARCHAR(10) NOT NULL,
+                           actor VARCHAR(100) NOT NULL,
+                           request_id VARCHAR(64) NOT NULL DEFAULT '',
+                           before JSONB,
+                           after JSONB,
+                           created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_log_symbol ON audit_log(symbol, created_at);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor, created_at);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_no_update_delete
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
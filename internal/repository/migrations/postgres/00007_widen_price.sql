@@ -0,0 +1,7 @@
+-- +goose Up
+-- Точные цены: до 22 знаков в целой части и 18 после запятой,
+-- чтобы хранить цены меньше сатоши и очень большие значения без округления
+ALTER TABLE coin_prices ALTER COLUMN price TYPE NUMERIC(40,18);
+
+-- +goose Down
+ALTER TABLE coin_prices ALTER COLUMN price TYPE DECIMAL(20,8);
@@ -0,0 +1,109 @@
+-- +goose Up
+-- Момент цены хранится как TIMESTAMPTZ с точностью до миллисекунды вместо Unix-секунд,
+-- чтобы тики потоковых источников внутри одной секунды не сливались.
+-- Тип ключа секционирования нельзя изменить на месте, поэтому таблица пересоздается
+-- с теми же месячными секциями, а цены переносятся без изменений.
+ALTER TABLE coin_prices RENAME TO coin_prices_old;
+ALTER TABLE coin_prices_old RENAME CONSTRAINT coin_prices_coin_id_timestamp_key TO coin_prices_old_coin_id_timestamp_key;
+ALTER SEQUENCE coin_prices_id_seq RENAME TO coin_prices_old_id_seq;
+
+CREATE TABLE coin_prices (
+                             id BIGSERIAL NOT NULL,
+                             coin_id INTEGER NOT NULL REFERENCES tracked_coins(id) ON DELETE CASCADE,
+                             price NUMERIC(40,18) NOT NULL,
+                             timestamp TIMESTAMPTZ NOT NULL,
+                             UNIQUE(coin_id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+-- Секции старой таблицы переименовываются, для каждого их месяца создается новая секция
+-- +goose StatementBegin
+DO $$
+DECLARE
+    part        RECORD;
+    month_start TIMESTAMP; -- Начало месяца по UTC: с TIMESTAMPTZ месяц прибавлялся бы в часовом поясе сессии
+BEGIN
+    FOR part IN
+        SELECT c.relname
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        JOIN pg_class p ON p.oid = i.inhparent
+        WHERE p.relname = 'coin_prices_old'
+    LOOP
+        EXECUTE format('ALTER TABLE %I RENAME TO %I', part.relname, part.relname || '_old');
+        IF part.relname ~ '^coin_prices_[0-9]{6}$' THEN
+            month_start := to_date(right(part.relname, 6), 'YYYYMM')::TIMESTAMP;
+            EXECUTE format(
+                'CREATE TABLE %I PARTITION OF coin_prices FOR VALUES FROM (%L) TO (%L)',
+                part.relname,
+                month_start AT TIME ZONE 'UTC',
+                (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC'
+            );
+        END IF;
+    END LOOP;
+END
+$$;
+-- +goose StatementEnd
+
+CREATE TABLE coin_prices_default PARTITION OF coin_prices DEFAULT;
+
+INSERT INTO coin_prices (id, coin_id, price, timestamp)
+SELECT id, coin_id, price, to_timestamp(timestamp)
+FROM coin_prices_old;
+
+SELECT setval('coin_prices_id_seq', COALESCE((SELECT MAX(id) FROM coin_prices), 0) + 1, false);
+
+DROP TABLE coin_prices_old;
+
+-- +goose Down
+-- Возврат к Unix-секундам: из тиков одной секунды остается последний
+ALTER TABLE coin_prices RENAME TO coin_prices_new;
+ALTER TABLE coin_prices_new RENAME CONSTRAINT coin_prices_coin_id_timestamp_key TO coin_prices_new_coin_id_timestamp_key;
+ALTER SEQUENCE coin_prices_id_seq RENAME TO coin_prices_new_id_seq;
+
+CREATE TABLE coin_prices (
+                             id BIGSERIAL NOT NULL,
+                             coin_id INTEGER NOT NULL REFERENCES tracked_coins(id) ON DELETE CASCADE,
+                             price NUMERIC(40,18) NOT NULL,
+                             timestamp BIGINT NOT NULL,
+                             UNIQUE(coin_id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+-- +goose StatementBegin
+DO $$
+DECLARE
+    part        RECORD;
+    month_start TIMESTAMP;
+BEGIN
+    FOR part IN
+        SELECT c.relname
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        JOIN pg_class p ON p.oid = i.inhparent
+        WHERE p.relname = 'coin_prices_new'
+    LOOP
+        EXECUTE format('ALTER TABLE %I RENAME TO %I', part.relname, part.relname || '_new');
+        IF part.relname ~ '^coin_prices_[0-9]{6}$' THEN
+            month_start := to_date(right(part.relname, 6), 'YYYYMM')::TIMESTAMP;
+            EXECUTE format(
+                'CREATE TABLE %I PARTITION OF coin_prices FOR VALUES FROM (%s) TO (%s)',
+                part.relname,
+                EXTRACT(EPOCH FROM month_start AT TIME ZONE 'UTC')::BIGINT,
+                EXTRACT(EPOCH FROM (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC')::BIGINT
+            );
+        END IF;
+    END LOOP;
+END
+$$;
+-- +goose StatementEnd
+
+CREATE TABLE coin_prices_default PARTITION OF coin_prices DEFAULT;
+
+INSERT INTO coin_prices (id, coin_id, price, timestamp)
+SELECT DISTINCT ON (coin_id, FLOOR(EXTRACT(EPOCH FROM timestamp)))
+       id, coin_id, price, FLOOR(EXTRACT(EPOCH FROM timestamp))::BIGINT
+FROM coin_prices_new
+ORDER BY coin_id, FLOOR(EXTRACT(EPOCH FROM timestamp)), timestamp DESC;
+
+SELECT setval('coin_prices_id_seq', COALESCE((SELECT MAX(id) FROM coin_prices), 0) + 1, false);
+
+DROP TABLE coin_prices_new;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- API-ключи хранятся только хешами SHA-256; отозванные ключи остаются в таблице
+-- с отметкой, кто и когда их отозвал.
+CREATE TABLE api_keys (
+                          id BIGSERIAL PRIMARY KEY,
+                          name VARCHAR(100) NOT NULL,
+                          prefix VARCHAR(16) NOT NULL,
+                          key_hash CHAR(64) NOT NULL UNIQUE,
+                          scopes VARCHAR(100) NOT NULL,
+                          created_by VARCHAR(100) NOT NULL,
+                          created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                          revoked_by VARCHAR(100) NOT NULL DEFAULT '',
+                          revoked_at TIMESTAMPTZ
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_keys;
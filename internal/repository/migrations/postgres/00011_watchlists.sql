@@ -0,0 +1,33 @@
+-- +goose Up
+-- Именованные списки наблюдения пользователей поверх общего справочника tracked_coins.
+-- Монета опрашивается, пока она в общем списке или хотя бы в одном списке пользователя.
+CREATE TABLE watchlists (
+                            id BIGSERIAL PRIMARY KEY,
+                            owner VARCHAR(100) NOT NULL,
+                            name VARCHAR(100) NOT NULL,
+                            created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                            UNIQUE (owner, name)
+);
+
+-- Монета, которая есть в списках, не удаляется безвозвратно
+CREATE TABLE watchlist_coins (
+                                 watchlist_id BIGINT NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+                                 coin_id INTEGER NOT NULL REFERENCES tracked_coins(id),
+                                 PRIMARY KEY (watchlist_id, coin_id)
+);
+
+CREATE INDEX idx_watchlist_coins_coin_id ON watchlist_coins(coin_id);
+
+-- Пользователи, которым владелец открыл список для чтения
+CREATE TABLE watchlist_shares (
+                                  watchlist_id BIGINT NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+                                  username VARCHAR(100) NOT NULL,
+                                  PRIMARY KEY (watchlist_id, username)
+);
+
+CREATE INDEX idx_watchlist_shares_username ON watchlist_shares(username);
+
+-- +goose Down
+DROP TABLE IF EXISTS watchlist_shares;
+DROP TABLE IF EXISTS watchlist_coins;
+DROP TABLE IF EXISTS watchlists;
@@ -0,0 +1,38 @@
+-- +goose Up
+-- Правила оповещений о цене и история их срабатываний. Состояние правила меняется
+-- условным обновлением по version, поэтому срабатывание записывается один раз,
+-- даже если тики проверяют несколько экземпляров сервиса.
+CREATE TABLE alert_rules (
+                             id BIGSERIAL PRIMARY KEY,
+                             owner VARCHAR(100) NOT NULL,
+                             coin_id INTEGER NOT NULL REFERENCES tracked_coins(id) ON DELETE CASCADE,
+                             kind VARCHAR(32) NOT NULL
+                                 CHECK (kind IN ('price_above', 'price_below', 'change_above', 'change_below', 'cross_above_ma', 'cross_below_ma')),
+                             threshold NUMERIC(40,18) NOT NULL,
+                             window_ms BIGINT NOT NULL DEFAULT 0,
+                             hysteresis NUMERIC(40,18) NOT NULL,
+                             cooldown_ms BIGINT NOT NULL,
+                             state VARCHAR(16) NOT NULL DEFAULT 'armed'
+                                 CHECK (state IN ('armed', 'fired', 'cooling_down')),
+                             state_changed_at TIMESTAMPTZ NOT NULL,
+                             last_fired_at TIMESTAMPTZ,
+                             version BIGINT NOT NULL DEFAULT 1,
+                             created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_alert_rules_owner ON alert_rules(owner);
+CREATE INDEX idx_alert_rules_coin_id ON alert_rules(coin_id);
+
+CREATE TABLE alert_events (
+                              id BIGSERIAL PRIMARY KEY,
+                              rule_id BIGINT NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+                              price NUMERIC(40,18) NOT NULL,
+                              value NUMERIC(40,18) NOT NULL,
+                              fired_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_alert_events_rule_id_fired_at ON alert_events(rule_id, fired_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS alert_events;
+DROP TABLE IF EXISTS alert_rules;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Имя ключа - владелец списков и правил, поэтому оно уникально. Повторные имена и имена,
+-- зарезервированные за admin_token и запросами без ключа, получают суффикс #<id>; списки и правила
+-- остаются за первым ключом с этим именем.
+UPDATE api_keys SET name = LEFT(name, 80) || '#' || id
+WHERE LOWER(name) IN ('admin', 'anonymous')
+   OR id > (SELECT MIN(k.id) FROM api_keys k WHERE k.name = api_keys.name);
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_name_key UNIQUE (name);
+
+-- +goose Down
+ALTER TABLE api_keys DROP CONSTRAINT IF EXISTS api_keys_name_key;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Монеты, удаленные безвозвратно, архив которых не удалось удалить сразу.
+-- Планировщик архивации дочищает их архив при следующем запуске.
+CREATE TABLE archive_orphans (
+                                 coin_id INTEGER PRIMARY KEY,
+                                 created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS archive_orphans;
@@ -0,0 +1,65 @@
+-- +goose Up
+-- Схема SQLite повторяет итоговую схему PostgreSQL. Цены хранятся текстом,
+-- чтобы не терять точность: арифметика над ними выполняется в приложении.
+CREATE TABLE tracked_coins (
+                               id INTEGER PRIMARY KEY AUTOINCREMENT,
+                               symbol TEXT UNIQUE NOT NULL,
+                               created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                               name TEXT NOT NULL DEFAULT '',
+                               provider_id TEXT NOT NULL DEFAULT '',
+                               category TEXT NOT NULL DEFAULT '',
+                               description TEXT NOT NULL DEFAULT '',
+                               logo_url TEXT NOT NULL DEFAULT '',
+                               decimals INTEGER NOT NULL DEFAULT 0,
+                               status TEXT NOT NULL DEFAULT 'active'
+                                   CHECK (status IN ('active', 'paused', 'delisted', 'untracked')),
+                               updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                               untracked_at TIMESTAMP
+);
+
+CREATE INDEX idx_tracked_coins_status ON tracked_coins(status);
+
+CREATE TABLE coin_prices (
+                             id INTEGER PRIMARY KEY AUTOINCREMENT,
+                             coin_id INTEGER NOT NULL REFERENCES tracked_coins(id) ON DELETE CASCADE,
+                             price TEXT NOT NULL,
+                             timestamp INTEGER NOT NULL,
+                             UNIQUE(coin_id, timestamp)
+);
+
+CREATE TABLE audit_log (
+                           id INTEGER PRIMARY KEY AUTOINCREMENT,
+                           action TEXT NOT NULL,
+                           coin_id INTEGER,
+                           symbol TEXT NOT NULL,
+                           actor TEXT NOT NULL,
+                           request_id TEXT NOT NULL DEFAULT '',
+                           before TEXT,
+                           after TEXT,
+                           created_at INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER))
+);
+
+CREATE INDEX idx_audit_log_symbol ON audit_log(symbol, created_at);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor, created_at);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_update
+    BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_delete
+    BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS coin_prices;
+DROP TABLE IF EXISTS tracked_coins;
@@ -0,0 +1,102 @@
+-- +goose Up
+-- Момент цены и время записей журнала хранятся в Unix-миллисекундах.
+-- Смена знака на первом шаге исключает временные совпадения в UNIQUE(coin_id, timestamp).
+UPDATE coin_prices SET timestamp = -timestamp;
+UPDATE coin_prices SET timestamp = -timestamp * 1000;
+
+-- Значение по умолчанию колонки в SQLite не меняется, поэтому журнал пересоздается
+DROP TRIGGER audit_log_no_update;
+DROP TRIGGER audit_log_no_delete;
+
+CREATE TABLE audit_log_new (
+                               id INTEGER PRIMARY KEY AUTOINCREMENT,
+                               action TEXT NOT NULL,
+                               coin_id INTEGER,
+                               symbol TEXT NOT NULL,
+                               actor TEXT NOT NULL,
+                               request_id TEXT NOT NULL DEFAULT '',
+                               before TEXT,
+                               after TEXT,
+                               created_at INTEGER NOT NULL DEFAULT (CAST(ROUND(unixepoch('subsec') * 1000) AS INTEGER))
+);
+
+INSERT INTO audit_log_new (id, action, coin_id, symbol, actor, request_id, before, after, created_at)
+SELECT id, action, coin_id, symbol, actor, request_id, before, after, created_at * 1000
+FROM audit_log;
+
+DROP TABLE audit_log;
+ALTER TABLE audit_log_new RENAME TO audit_log;
+
+CREATE INDEX idx_audit_log_symbol ON audit_log(symbol, created_at);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor, created_at);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_update
+    BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_delete
+    BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Возврат к Unix-секундам: из тиков одной секунды остается последний
+DELETE FROM coin_prices
+WHERE EXISTS (
+    SELECT 1 FROM coin_prices later
+    WHERE later.coin_id = coin_prices.coin_id
+      AND later.timestamp / 1000 = coin_prices.timestamp / 1000
+      AND later.timestamp > coin_prices.timestamp
+);
+UPDATE coin_prices SET timestamp = -(timestamp / 1000);
+UPDATE coin_prices SET timestamp = -timestamp;
+
+DROP TRIGGER audit_log_no_update;
+DROP TRIGGER audit_log_no_delete;
+
+CREATE TABLE audit_log_old (
+                               id INTEGER PRIMARY KEY AUTOINCREMENT,
+                               action TEXT NOT NULL,
+                               coin_id INTEGER,
+                               symbol TEXT NOT NULL,
+                               actor TEXT NOT NULL,
+                               request_id TEXT NOT NULL DEFAULT '',
+                               before TEXT,
+                               after TEXT,
+                               created_at INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER))
+);
+
+INSERT INTO audit_log_old (id, action, coin_id, symbol, actor, request_id, before, after, created_at)
+SELECT id, action, coin_id, symbol, actor, request_id, before, after, created_at / 1000
+FROM audit_log;
+
+DROP TABLE audit_log;
+ALTER TABLE audit_log_old RENAME TO audit_log;
+
+CREATE INDEX idx_audit_log_symbol ON audit_log(symbol, created_at);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor, created_at);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_update
+    BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_delete
+    BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- API-ключи хранятся только хешами SHA-256; время - в Unix-миллисекундах
+CREATE TABLE api_keys (
+                          id INTEGER PRIMARY KEY AUTOINCREMENT,
+                          name TEXT NOT NULL,
+                          prefix TEXT NOT NULL,
+                          key_hash TEXT NOT NULL UNIQUE,
+                          scopes TEXT NOT NULL,
+                          created_by TEXT NOT NULL,
+                          created_at INTEGER NOT NULL,
+                          revoked_by TEXT NOT NULL DEFAULT '',
+                          revoked_at INTEGER
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_keys;
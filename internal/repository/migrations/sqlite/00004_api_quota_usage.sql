@@ -0,0 +1,15 @@
+-- +goose Up
+-- Суточные квоты запросов к API: число запросов клиента за сутки UTC.
+-- client - key:<id> для API-ключа, admin для admin_token, ip:<адрес> для запросов без ключа;
+-- day - дата YYYY-MM-DD.
+CREATE TABLE api_quota_usage (
+                                 client TEXT NOT NULL,
+                                 day TEXT NOT NULL,
+                                 requests INTEGER NOT NULL,
+                                 PRIMARY KEY (client, day)
+);
+
+CREATE INDEX idx_api_quota_usage_day ON api_quota_usage(day);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_quota_usage;
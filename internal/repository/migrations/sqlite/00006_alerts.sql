@@ -0,0 +1,37 @@
+-- +goose Up
+-- Правила оповещений о цене и история их срабатываний. Пороги и цены хранятся текстом,
+-- как цены в coin_prices. Состояние правила меняется условным обновлением по version.
+CREATE TABLE alert_rules (
+                             id INTEGER PRIMARY KEY AUTOINCREMENT,
+                             owner TEXT NOT NULL,
+                             coin_id INTEGER NOT NULL REFERENCES tracked_coins(id) ON DELETE CASCADE,
+                             kind TEXT NOT NULL
+                                 CHECK (kind IN ('price_above', 'price_below', 'change_above', 'change_below', 'cross_above_ma', 'cross_below_ma')),
+                             threshold TEXT NOT NULL,
+                             window_ms INTEGER NOT NULL DEFAULT 0,
+                             hysteresis TEXT NOT NULL,
+                             cooldown_ms INTEGER NOT NULL,
+                             state TEXT NOT NULL DEFAULT 'armed'
+                                 CHECK (state IN ('armed', 'fired', 'cooling_down')),
+                             state_changed_at INTEGER NOT NULL,
+                             last_fired_at INTEGER,
+                             version INTEGER NOT NULL DEFAULT 1,
+                             created_at INTEGER NOT NULL
+);
+
+CREATE INDEX idx_alert_rules_owner ON alert_rules(owner);
+CREATE INDEX idx_alert_rules_coin_id ON alert_rules(coin_id);
+
+CREATE TABLE alert_events (
+                              id INTEGER PRIMARY KEY AUTOINCREMENT,
+                              rule_id INTEGER NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+                              price TEXT NOT NULL,
+                              value TEXT NOT NULL,
+                              fired_at INTEGER NOT NULL
+);
+
+CREATE INDEX idx_alert_events_rule_id_fired_at ON alert_events(rule_id, fired_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS alert_events;
+DROP TABLE IF EXISTS alert_rules;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Имя ключа - владелец списков и правил, поэтому оно уникально. Повторные имена и имена,
+-- зарезервированные за admin_token и запросами без ключа, получают суффикс #<id>; списки и правила
+-- остаются за первым ключом с этим именем.
+UPDATE api_keys SET name = name || '#' || id
+WHERE LOWER(name) IN ('admin', 'anonymous')
+   OR id > (SELECT MIN(k.id) FROM api_keys k WHERE k.name = api_keys.name);
+CREATE UNIQUE INDEX idx_api_keys_name ON api_keys (name);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_api_keys_name;
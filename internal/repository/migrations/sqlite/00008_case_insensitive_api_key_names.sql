@@ -0,0 +1,11 @@
+-- +goose Up
+-- Имена ключей уникальны без учета регистра. Имена, отличающиеся от более раннего ключа
+-- только регистром, получают суффикс #<id>; списки и правила остаются за первым ключом.
+UPDATE api_keys SET name = name || '#' || id
+WHERE id > (SELECT MIN(k.id) FROM api_keys k WHERE LOWER(k.name) = LOWER(api_keys.name));
+DROP INDEX IF EXISTS idx_api_keys_name;
+CREATE UNIQUE INDEX idx_api_keys_lower_name ON api_keys (LOWER(name));
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_api_keys_lower_name;
+CREATE UNIQUE INDEX idx_api_keys_name ON api_keys (name);
@@ -0,0 +1,735 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+)
+
+// MySQLStorage представляет слой доступа к данным MySQL/MariaDB
+type MySQLStorage struct {
+	db  *sql.DB
+	log *zap.Logger
+}
+
+// NewMySQLStorage подключается к MySQL/MariaDB и применяет встроенные миграции
+func NewMySQLStorage(user string, password string, host string, port string, dbname string, log *zap.Logger) (*MySQLStorage, error) {
+	log = log.With(zap.String("type", "MySQLStorage"))
+
+	db, err := openMySQL(user, password, host, port, dbname, log)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(db, goose.DialectMySQL, "mysql", log); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &MySQLStorage{db: db, log: log}, nil
+}
+
+func openMySQL(user string, password string, host string, port string, dbname string, log *zap.Logger) (*sql.DB, error) {
+	log.Info("Connecting to MySQL database",
+		zap.String("dbname", dbname),
+		zap.String("user", user))
+
+	cfg := mysql.NewConfig()
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(host, port)
+	cfg.DBName = dbname
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		log.Error("Failed to open database connection", zap.Error(err))
+		return nil, err
+	}
+
+	log.Info("Testing database connection")
+	if err := db.Ping(); err != nil {
+		log.Error("Failed to ping database", zap.Error(err))
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close закрывает соединение с базой данных
+func (s *MySQLStorage) Close() error {
+	s.log.Info("Closing database connection")
+	return s.db.Close()
+}
+
+// MySQLRepository реализует тот же контракт хранилища, что и Repository для PostgreSQL.
+// В MySQL нет RETURNING, поэтому измененная строка перечитывается в той же транзакции.
+type MySQLRepository struct {
+	db  *sql.DB
+	log *zap.Logger
+}
+
+func (s *MySQLStorage) NewRepository() *MySQLRepository {
+	return &MySQLRepository{db: s.db, log: s.log.Named("MySQLRepository")}
+}
+
+func (r *MySQLRepository) AddCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := mysqlLockCoin(ctx, tx, coin.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO tracked_coins (symbol, name, provider_id, category, description, logo_url, decimals)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE
+            name = VALUES(name),
+            provider_id = VALUES(provider_id),
+            category = VALUES(category),
+            description = VALUES(description),
+            logo_url = VALUES(logo_url),
+            decimals = VALUES(decimals),
+            status = 'active',
+            untracked_at = NULL,
+            updated_at = CURRENT_TIMESTAMP`,
+		coin.Symbol,
+		coin.Name,
+		coin.ProviderID,
+		coin.Category,
+		coin.Description,
+		coin.LogoURL,
+		coin.Decimals,
+	)
+	if err != nil {
+		r.log.Error("Failed to insert coin", zap.Error(err))
+		return fmt.Errorf("failed to insert coin: %w", err)
+	}
+	after, err := mysqlLockCoin(ctx, tx, coin.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+
+	if err := writeMySQLAudit(ctx, tx, models.AuditCoinAdd, after.ID, after.Symbol, actor, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RemoveCoin убирает монету из списка наблюдения, сохраняя ее историю цен
+func (r *MySQLRepository) RemoveCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := mysqlLockCoin(ctx, tx, coin.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil || before.Status == models.CoinStatusUntracked {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE tracked_coins
+        SET status = 'untracked',
+            untracked_at = CURRENT_TIMESTAMP,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = ?`, before.ID)
+	if err != nil {
+		r.log.Error("Failed to remove coin", zap.Error(err))
+		return fmt.Errorf("failed to remove coin: %w", err)
+	}
+	after, err := mysqlLockCoin(ctx, tx, coin.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+
+	if err := writeMySQLAudit(ctx, tx, models.AuditCoinRemove, after.ID, after.Symbol, actor, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// PurgeCoin безвозвратно удаляет убранную из списка наблюдения монету вместе с историей цен
+func (r *MySQLRepository) PurgeCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := mysqlLockCoin(ctx, tx, coin.Symbol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil {
+		return 0, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+	if before.Status != models.CoinStatusUntracked {
+		return 0, fmt.Errorf("coin %s is still tracked, remove it first", coin.Symbol)
+	}
+	// Проверка после блокировки монеты: добавление ее в список ждет конца транзакции
+	watched, err := queryCoinWatched(ctx, tx, mysqlWatchlists, before.ID)
+	if err != nil {
+		return 0, err
+	}
+	if watched {
+		return 0, fmt.Errorf("coin %s is in users' watchlists: %w", coin.Symbol, models.ErrReferenced)
+	}
+
+	var deleted int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM coin_prices WHERE coin_id = ?", before.ID).Scan(&deleted); err != nil {
+		return 0, fmt.Errorf("failed to count prices: %w", err)
+	}
+	// Цены удаляются каскадно
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tracked_coins WHERE id = ?", before.ID); err != nil {
+		return 0, fmt.Errorf("failed to purge coin: %w", constraintError(err))
+	}
+
+	after := map[string]int64{"deleted_prices": deleted}
+	if err := writeMySQLAudit(ctx, tx, models.AuditCoinPurge, before.ID, before.Symbol, actor, before, after); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.log.Info("Purged coin", zap.String("symbol", coin.Symbol), zap.Int64("prices", deleted))
+	return deleted, nil
+}
+
+// mysqlLockCoin блокирует строку монеты до конца транзакции и возвращает ее; nil - монеты нет
+func mysqlLockCoin(ctx context.Context, tx *sql.Tx, symbol string) (*models.TrackedCoin, error) {
+	coin, err := scanCoin(tx.QueryRowContext(ctx, `SELECT `+coinColumns+` FROM tracked_coins WHERE symbol = ? FOR UPDATE`, symbol))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return coin, err
+}
+
+// mysqlGetPriceQuery - ближайшая цена по индексу (coin_id, timestamp), как в getPriceQuery.
+// Параметры: symbol, timestamp, symbol, timestamp, timestamp.
+const mysqlGetPriceQuery = `
+        SELECT id, coin_id, symbol, price, timestamp
+        FROM (
+            (SELECT cp.id, cp.coin_id, tc.symbol, cp.price, cp.timestamp
+             FROM tracked_coins tc
+             JOIN coin_prices cp ON cp.coin_id = tc.id
+             WHERE tc.symbol = ? AND cp.timestamp <= ?
+             ORDER BY cp.timestamp DESC
+             LIMIT 1)
+            UNION ALL
+            (SELECT cp.id, cp.coin_id, tc.symbol, cp.price, cp.timestamp
+             FROM tracked_coins tc
+             JOIN coin_prices cp ON cp.coin_id = tc.id
+             WHERE tc.symbol = ? AND cp.timestamp > ?
+             ORDER BY cp.timestamp ASC
+             LIMIT 1)
+        ) nearest
+        ORDER BY ABS(timestamp - ?), timestamp
+        LIMIT 1`
+
+func (r *MySQLRepository) GetPrice(ctx context.Context, coin *models.GetPriceRequest) (*models.CryptoPrice, error) {
+	timestamp := coin.Timestamp
+
+	var price models.CryptoPrice
+	err := r.db.QueryRowContext(ctx, mysqlGetPriceQuery, coin.Coin, timestamp, coin.Coin, timestamp, timestamp).Scan(
+		&price.ID,
+		&price.CoinID,
+		&price.Symbol,
+		&price.Price,
+		&price.Timestamp,
+	)
+	if err != nil {
+		r.log.Error("Failed to get price", zap.Error(err), zap.String("coin", coin.Coin))
+		return nil, fmt.Errorf("failed to get price: %w", err)
+	}
+	return &price, nil
+}
+
+// GetAllCoins возвращает список наблюдения; убранные из него монеты не входят
+func (r *MySQLRepository) GetAllCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	r.log.Debug("Getting all coins")
+	return queryCoins(ctx, r.db, r.log, `SELECT `+coinColumns+` FROM tracked_coins WHERE status <> ? ORDER BY symbol`, models.CoinStatusUntracked)
+}
+
+// GetActiveCoins возвращает монеты, цены которых нужно опрашивать
+func (r *MySQLRepository) GetActiveCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	r.log.Debug("Getting active coins")
+	return queryCoins(ctx, r.db, r.log, activeCoinsQuery(mysqlWatchlists.param), models.CoinStatusActive, models.CoinStatusUntracked)
+}
+
+func (r *MySQLRepository) GetCoin(ctx context.Context, symbol string) (*models.TrackedCoin, error) {
+	coin, err := scanCoin(r.db.QueryRowContext(ctx, `SELECT `+coinColumns+` FROM tracked_coins WHERE symbol = ?`, symbol))
+	if err != nil {
+		r.log.Error("Failed to get coin", zap.Error(err), zap.String("coin", symbol))
+		return nil, fmt.Errorf("failed to get coin: %w", err)
+	}
+	return coin, nil
+}
+
+// SetCoinStatus меняет состояние опроса монеты
+func (r *MySQLRepository) SetCoinStatus(ctx context.Context, symbol string, status models.CoinStatus, actor *models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := mysqlLockCoin(ctx, tx, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil || before.Status == models.CoinStatusUntracked {
+		return fmt.Errorf("failed to set coin status: %w", sql.ErrNoRows)
+	}
+	if before.Status == status {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE tracked_coins
+        SET status = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ?`, status, before.ID)
+	if err != nil {
+		r.log.Error("Failed to set coin status", zap.Error(err), zap.String("coin", symbol))
+		return fmt.Errorf("failed to set coin status: %w", err)
+	}
+	after, err := mysqlLockCoin(ctx, tx, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+
+	if err := writeMySQLAudit(ctx, tx, models.AuditCoinStatus, after.ID, after.Symbol, actor, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateCoinMetadata меняет заданные в запросе поля метаданных монеты
+func (r *MySQLRepository) UpdateCoinMetadata(ctx context.Context, req *models.UpdateMetadataRequest, actor *models.Actor) (*models.TrackedCoin, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := mysqlLockCoin(ctx, tx, req.Coin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil {
+		return nil, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE tracked_coins
+        SET name = COALESCE(?, name),
+            category = COALESCE(?, category),
+            description = COALESCE(?, description),
+            logo_url = COALESCE(?, logo_url),
+            decimals = COALESCE(?, decimals),
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = ?`,
+		req.Name,
+		req.Category,
+		req.Description,
+		req.LogoURL,
+		req.Decimals,
+		before.ID,
+	)
+	if err != nil {
+		r.log.Error("Failed to update coin metadata", zap.Error(err), zap.String("coin", req.Coin))
+		return nil, fmt.Errorf("failed to update coin metadata: %w", err)
+	}
+	after, err := mysqlLockCoin(ctx, tx, req.Coin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coin: %w", err)
+	}
+
+	if err := writeMySQLAudit(ctx, tx, models.AuditCoinMetadata, after.ID, after.Symbol, actor, before, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
+}
+
+// CorrectPrice вручную записывает цену монеты в конкретный момент
+func (r *MySQLRepository) CorrectPrice(ctx context.Context, price *models.CryptoPrice, actor *models.Actor) (*models.CryptoPrice, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	coin, err := mysqlLockCoin(ctx, tx, price.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coin: %w", err)
+	}
+	if coin == nil {
+		return nil, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+
+	const selectPrice = `
+        SELECT id, coin_id, price, timestamp
+        FROM coin_prices
+        WHERE coin_id = ? AND timestamp = ?`
+
+	var before *models.CryptoPrice
+	var existing models.CryptoPrice
+	err = tx.QueryRowContext(ctx, selectPrice, coin.ID, price.Timestamp).
+		Scan(&existing.ID, &existing.CoinID, &existing.Price, &existing.Timestamp)
+	switch {
+	case err == nil:
+		existing.Symbol = coin.Symbol
+		before = &existing
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("failed to get price: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO coin_prices (coin_id, price, timestamp)
+        VALUES (?, ?, ?)
+        ON DUPLICATE KEY UPDATE price = VALUES(price)`,
+		coin.ID, price.Price, price.Timestamp)
+	if err != nil {
+		r.log.Error("Failed to correct price", zap.Error(err), zap.String("coin", price.Symbol))
+		return nil, fmt.Errorf("failed to correct price: %w", err)
+	}
+
+	after := models.CryptoPrice{Symbol: coin.Symbol}
+	err = tx.QueryRowContext(ctx, selectPrice, coin.ID, price.Timestamp).
+		Scan(&after.ID, &after.CoinID, &after.Price, &after.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price: %w", err)
+	}
+
+	if err := writeMySQLAudit(ctx, tx, models.AuditPriceCorrect, coin.ID, coin.Symbol, actor, before, &after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &after, nil
+}
+
+var mysqlAudit = auditDialect{
+	insert: "INSERT INTO audit_log (action, coin_id, symbol, actor, request_id, `before`, `after`)" +
+		" VALUES (?, ?, ?, ?, ?, ?, ?)",
+	states:     "`before`, `after`",
+	timeColumn: "CAST(UNIX_TIMESTAMP(created_at) * 1000 AS SIGNED)",
+	timeParam:  "FROM_UNIXTIME(%s / 1000)",
+	param:      func(int) string { return "?" },
+}
+
+func writeMySQLAudit(ctx context.Context, tx *sql.Tx, action models.AuditAction, coinID int64, symbol string, actor *models.Actor, before, after any) error {
+	return insertAudit(ctx, tx, mysqlAudit, action, coinID, symbol, actor, before, after)
+}
+
+// GetAuditLog возвращает записи журнала аудита от новых к старым
+func (r *MySQLRepository) GetAuditLog(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	return queryAuditLog(ctx, r.db, r.log, filter, mysqlAudit)
+}
+
+func (r *MySQLRepository) AddNewPrice(ctx context.Context, coin *models.CryptoPrice) error {
+	r.log.Debug("Adding new price", zap.String("symbol", coin.Symbol))
+
+	errs, err := r.AddNewPrices(ctx, []*models.CryptoPrice{coin})
+	if err != nil {
+		return err
+	}
+	if errs[0] != nil {
+		return errs[0]
+	}
+
+	r.log.Debug("Successfully added new price",
+		zap.String("symbol", coin.Symbol),
+		zap.Stringer("price", coin.Price))
+	return nil
+}
+
+// AddNewPrices записывает цены за цикл опроса одним INSERT ... ON DUPLICATE KEY UPDATE.
+// Возвращает ошибки по строкам в порядке входного среза (nil - строка записана).
+func (r *MySQLRepository) AddNewPrices(ctx context.Context, prices []*models.CryptoPrice) ([]error, error) {
+	r.log.Debug("Adding new prices", zap.Int("count", len(prices)))
+
+	errs := make([]error, len(prices))
+	if len(prices) == 0 {
+		return errs, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	// Строки удаленных монет отбрасываются заранее: нарушение внешнего ключа
+	// в многострочном INSERT отменило бы всю пачку. Разделяемая блокировка
+	// не дает удалить монету до конца транзакции.
+	ids := make([]any, len(prices))
+	for i, price := range prices {
+		ids[i] = price.CoinID
+	}
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id FROM tracked_coins
+        WHERE id IN (`+mysqlPlaceholders(len(ids), 1)+`)
+        LOCK IN SHARE MODE`, ids...)
+	if err != nil {
+		r.log.Error("Failed to get coins", zap.Error(err))
+		return nil, fmt.Errorf("failed to get coins: %w", err)
+	}
+	existing := make(map[int64]struct{})
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan coin: %w", err)
+		}
+		existing[id] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	var args []any
+	for i, price := range prices {
+		if _, ok := existing[price.CoinID]; !ok {
+			errs[i] = fmt.Errorf("coin with id %d not found", price.CoinID)
+			continue
+		}
+		args = append(args, price.CoinID, price.Price, price.Timestamp)
+	}
+
+	written := len(args) / 3
+	if written > 0 {
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO coin_prices (coin_id, price, timestamp)
+            VALUES `+mysqlPlaceholders(written, 3)+`
+            ON DUPLICATE KEY UPDATE price = VALUES(price)`, args...)
+		if err != nil {
+			r.log.Error("Failed to insert prices", zap.Error(err))
+			return nil, fmt.Errorf("failed to insert prices: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.log.Debug("Successfully added new prices",
+		zap.Int("written", written),
+		zap.Int("failed", len(prices)-written))
+	return errs, nil
+}
+
+// mysqlPlaceholders возвращает rows групп по columns параметров: "(?, ?), (?, ?)".
+// При columns = 1 скобки не ставятся: "?, ?".
+func mysqlPlaceholders(rows, columns int) string {
+	group := strings.TrimSuffix(strings.Repeat("?, ", columns), ", ")
+	if columns > 1 {
+		group = "(" + group + ")"
+	}
+	return strings.TrimSuffix(strings.Repeat(group+", ", rows), ", ")
+}
+
+// GetPriceBuckets агрегирует цены монеты по интервалам. В MySQL нет date_bin, поэтому
+// интервалы группируются по смещениям часового пояса, вычисленным в приложении.
+func (r *MySQLRepository) GetPriceBuckets(ctx context.Context, q *models.BucketQuery) ([]*models.PriceBucket, error) {
+	r.log.Debug("Getting price buckets",
+		zap.String("symbol", q.Symbol),
+		zap.Duration("interval", q.Interval),
+		zap.String("timezone", q.Timezone))
+
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	query, args := bucketQuery(q, loc, func(int) string { return "?" },
+		"MAX(price)", "MIN(price)", "ROUND(AVG(price), 18)")
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.log.Error("Failed to get price buckets", zap.Error(err), zap.String("coin", q.Symbol))
+		return nil, fmt.Errorf("failed to get price buckets: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []*models.PriceBucket
+	for rows.Next() {
+		var bucket models.PriceBucket
+		var wall int64
+		if err := rows.Scan(&wall, &bucket.Open, &bucket.Close, &bucket.Count, &bucket.High, &bucket.Low, &bucket.Mean); err != nil {
+			r.log.Error("Failed to scan price bucket", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan price bucket: %w", err)
+		}
+		bucket.Start = bucketAt(wall, loc)
+		buckets = append(buckets, &bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	r.log.Debug("Successfully fetched price buckets", zap.Int("count", len(buckets)))
+
+	return buckets, nil
+}
+
+var mysqlAPIKeys = apiKeyDialect{
+	param:   func(int) string { return "?" },
+	timeArg: func(ts models.Timestamp) any { return ts },
+}
+
+// CreateAPIKey сохраняет ключ по его хешу и заполняет key.ID
+func (r *MySQLRepository) CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error {
+	return insertAPIKey(ctx, r.db, r.log, mysqlAPIKeys, key, hash)
+}
+
+// GetAPIKeyByHash ищет действующий ключ по хешу
+func (r *MySQLRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return queryAPIKeyByHash(ctx, r.db, mysqlAPIKeys, hash)
+}
+
+// ListAPIKeys возвращает все ключи, включая отозванные, в порядке создания
+func (r *MySQLRepository) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	return queryAPIKeys(ctx, r.db, r.log)
+}
+
+func (r *MySQLRepository) RevokeAPIKey(ctx context.Context, id int64, revokedBy string, at models.Timestamp) error {
+	return revokeAPIKey(ctx, r.db, r.log, mysqlAPIKeys, id, revokedBy, at)
+}
+
+var mysqlQuotas = quotaDialect{
+	upsert: "INSERT INTO api_quota_usage (client, day, requests) VALUES (?, ?, ?)" +
+		" ON DUPLICATE KEY UPDATE requests = requests + VALUES(requests)",
+	param: func(int) string { return "?" },
+}
+
+// AddQuotaUsage прибавляет запросы клиентов за сутки day (YYYY-MM-DD, UTC)
+func (r *MySQLRepository) AddQuotaUsage(ctx context.Context, day string, usage map[string]int64) error {
+	return addQuotaUsage(ctx, r.db, r.log, mysqlQuotas, day, usage)
+}
+
+// GetQuotaUsage возвращает запросы клиентов за сутки day
+func (r *MySQLRepository) GetQuotaUsage(ctx context.Context, day string) (map[string]int64, error) {
+	return queryQuotaUsage(ctx, r.db, r.log, mysqlQuotas, day)
+}
+
+// DeleteQuotaUsageBefore удаляет учет запросов за сутки раньше day
+func (r *MySQLRepository) DeleteQuotaUsageBefore(ctx context.Context, day string) (int64, error) {
+	return deleteQuotaUsageBefore(ctx, r.db, mysqlQuotas, day)
+}
+
+var mysqlWatchlists = watchlistDialect{
+	param:   func(int) string { return "?" },
+	timeArg: func(ts models.Timestamp) any { return ts },
+	registerCoin: `
+        INSERT IGNORE INTO tracked_coins (symbol, name, provider_id, category, description, logo_url, decimals, status, untracked_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, 'untracked', CURRENT_TIMESTAMP)`,
+	addCoin: `INSERT IGNORE INTO watchlist_coins (watchlist_id, coin_id) VALUES (?, ?)`,
+	share:   `INSERT IGNORE INTO watchlist_shares (watchlist_id, username) VALUES (?, ?)`,
+}
+
+// CreateWatchlist создает пустой список и заполняет w.ID
+func (r *MySQLRepository) CreateWatchlist(ctx context.Context, w *models.Watchlist) error {
+	return insertWatchlist(ctx, r.db, r.log, mysqlWatchlists, w)
+}
+
+func (r *MySQLRepository) GetWatchlist(ctx context.Context, id int64) (*models.Watchlist, error) {
+	return queryWatchlist(ctx, r.db, r.log, mysqlWatchlists, id)
+}
+
+// ListWatchlists возвращает списки пользователя и открытые ему списки
+func (r *MySQLRepository) ListWatchlists(ctx context.Context, user string) ([]*models.Watchlist, error) {
+	return queryWatchlists(ctx, r.db, r.log, mysqlWatchlists, user)
+}
+
+func (r *MySQLRepository) RenameWatchlist(ctx context.Context, id int64, name string) error {
+	return renameWatchlist(ctx, r.db, mysqlWatchlists, id, name)
+}
+
+func (r *MySQLRepository) DeleteWatchlist(ctx context.Context, id int64) error {
+	return deleteWatchlist(ctx, r.db, mysqlWatchlists, id)
+}
+
+// AddWatchlistCoin добавляет монету в список и при необходимости в справочник; заполняет coin.ID
+func (r *MySQLRepository) AddWatchlistCoin(ctx context.Context, id int64, coin *models.TrackedCoin) error {
+	return addWatchlistCoin(ctx, r.db, r.log, mysqlWatchlists, id, coin)
+}
+
+func (r *MySQLRepository) RemoveWatchlistCoin(ctx context.Context, id, coinID int64) error {
+	return removeWatchlistCoin(ctx, r.db, mysqlWatchlists, id, coinID)
+}
+
+func (r *MySQLRepository) ShareWatchlist(ctx context.Context, id int64, user string) error {
+	return shareWatchlist(ctx, r.db, mysqlWatchlists, id, user)
+}
+
+func (r *MySQLRepository) UnshareWatchlist(ctx context.Context, id int64, user string) error {
+	return unshareWatchlist(ctx, r.db, mysqlWatchlists, id, user)
+}
+
+// IsCoinWatched сообщает, есть ли монета хотя бы в одном списке пользователя
+func (r *MySQLRepository) IsCoinWatched(ctx context.Context, coinID int64) (bool, error) {
+	return queryCoinWatched(ctx, r.db, mysqlWatchlists, coinID)
+}
+
+var mysqlAlerts = alertDialect{
+	param:   func(int) string { return "?" },
+	timeArg: func(ts models.Timestamp) any { return ts },
+}
+
+// CreateAlertRule создает правило и заполняет rule.ID
+func (r *MySQLRepository) CreateAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	return insertAlertRule(ctx, r.db, r.log, mysqlAlerts, rule)
+}
+
+func (r *MySQLRepository) GetAlertRule(ctx context.Context, id int64) (*models.AlertRule, error) {
+	return queryAlertRule(ctx, r.db, mysqlAlerts, id)
+}
+
+// ListAlertRules возвращает правила владельца owner, а при пустом owner - все правила
+func (r *MySQLRepository) ListAlertRules(ctx context.Context, owner string) ([]*models.AlertRule, error) {
+	return queryAlertRules(ctx, r.db, r.log, mysqlAlerts, owner)
+}
+
+func (r *MySQLRepository) DeleteAlertRule(ctx context.Context, id int64) error {
+	return deleteAlertRule(ctx, r.db, mysqlAlerts, id)
+}
+
+// UpdateAlertState условно по версии записывает состояние правила и срабатывание event
+func (r *MySQLRepository) UpdateAlertState(ctx context.Context, rule *models.AlertRule, event *models.AlertEvent) (bool, error) {
+	return updateAlertState(ctx, r.db, r.log, mysqlAlerts, rule, event)
+}
+
+// GetAlertEvents возвращает историю срабатываний от новых к старым
+func (r *MySQLRepository) GetAlertEvents(ctx context.Context, filter *models.AlertEventFilter) ([]*models.AlertEvent, error) {
+	return queryAlertEvents(ctx, r.db, r.log, mysqlAlerts, filter)
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"awesomeProject/internal/repository/repotest"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"go.uber.org/zap"
+)
+
+var mysqlDatabases atomic.Int64
+
+// TestMySQLRepository запускает общий набор проверок на MySQL/MariaDB из TEST_MYSQL_DSN,
+// например root:secret@tcp(localhost:3306)/. Каждая проверка получает отдельную базу.
+func TestMySQLRepository(t *testing.T) {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set")
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("invalid TEST_MYSQL_DSN: %v", err)
+	}
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		t.Fatalf("invalid TEST_MYSQL_DSN address: %v", err)
+	}
+
+	admin, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer admin.Close()
+
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		name := fmt.Sprintf("conformance_%d_%d", time.Now().UnixNano(), mysqlDatabases.Add(1))
+		if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+			t.Fatalf("failed to create database: %v", err)
+		}
+		t.Cleanup(func() { admin.Exec("DROP DATABASE " + name) })
+
+		storage, err := NewMySQLStorage(cfg.User, cfg.Passwd, host, port, name, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to open storage: %v", err)
+		}
+		t.Cleanup(func() { storage.Close() })
+		return storage.NewRepository()
+	})
+}
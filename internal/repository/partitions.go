@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Секции coin_prices называются coin_prices_YYYYMM и покрывают календарный месяц UTC
+const (
+	partitionPrefix = "coin_prices_"
+	partitionLayout = "200601"
+)
+
+func partitionName(month time.Time) string {
+	return partitionPrefix + month.Format(partitionLayout)
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// EnsurePartitions создает недостающие месячные секции для месяцев с from по to включительно.
+// Возвращает имена созданных секций.
+func (r *Repository) EnsurePartitions(ctx context.Context, from, to time.Time) ([]string, error) {
+	existing, err := r.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+	for month := monthStart(from); !month.After(to); month = month.AddDate(0, 1, 0) {
+		name := partitionName(month)
+		if _, ok := existing[name]; ok {
+			continue
+		}
+		if err := r.createPartition(ctx, name, month, month.AddDate(0, 1, 0)); err != nil {
+			r.log.Error("Failed to create partition", zap.String("partition", name), zap.Error(err))
+			return created, fmt.Errorf("failed to create partition %s: %w", name, err)
+		}
+		r.log.Info("Created partition", zap.String("partition", name))
+		created = append(created, name)
+	}
+	return created, nil
+}
+
+// createPartition создает секцию [from, to). Цены этого периода, уже попавшие
+// в секцию по умолчанию, переносятся в новую секцию в той же транзакции.
+func (r *Repository) createPartition(ctx context.Context, name string, from, to time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	lower, upper := from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339)
+	statements := []string{
+		fmt.Sprintf(`CREATE TEMP TABLE moved_prices ON COMMIT DROP AS
+            SELECT * FROM coin_prices_default WHERE timestamp >= '%s' AND timestamp < '%s'`, lower, upper),
+		fmt.Sprintf(`DELETE FROM coin_prices_default WHERE timestamp >= '%s' AND timestamp < '%s'`, lower, upper),
+		fmt.Sprintf(`CREATE TABLE %s PARTITION OF coin_prices FOR VALUES FROM ('%s') TO ('%s')`, name, lower, upper),
+		`INSERT INTO coin_prices SELECT * FROM moved_prices`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DropPartitionsBefore отсоединяет секции, месяц которых целиком раньше before.
+// При drop отсоединенные секции удаляются, иначе остаются отдельными таблицами.
+// Возвращает имена обработанных секций.
+func (r *Repository) DropPartitionsBefore(ctx context.Context, before time.Time, drop bool) ([]string, error) {
+	existing, err := r.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []string
+	for name, month := range existing {
+		if month.AddDate(0, 1, 0).After(before) {
+			continue
+		}
+		if _, err := r.db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE coin_prices DETACH PARTITION %s`, name)); err != nil {
+			r.log.Error("Failed to detach partition", zap.String("partition", name), zap.Error(err))
+			return expired, fmt.Errorf("failed to detach partition %s: %w", name, err)
+		}
+		if drop {
+			if _, err := r.db.ExecContext(ctx, fmt.Sprintf(`DROP TABLE %s`, name)); err != nil {
+				r.log.Error("Failed to drop partition", zap.String("partition", name), zap.Error(err))
+				return expired, fmt.Errorf("failed to drop partition %s: %w", name, err)
+			}
+		}
+		r.log.Info("Expired partition", zap.String("partition", name), zap.Bool("dropped", drop))
+		expired = append(expired, name)
+	}
+	return expired, nil
+}
+
+// partitions возвращает месячные секции coin_prices и начало их месяца.
+// Секция по умолчанию в список не входит.
+func (r *Repository) partitions(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT c.relname
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        JOIN pg_class p ON p.oid = i.inhparent
+        WHERE p.relname = 'coin_prices'`)
+	if err != nil {
+		r.log.Error("Failed to list partitions", zap.Error(err))
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	defer rows.Close()
+
+	partitions := make(map[string]time.Time)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan partition: %w", err)
+		}
+		month, err := time.Parse(partitionLayout, strings.TrimPrefix(name, partitionPrefix))
+		if err != nil {
+			continue // coin_prices_default и посторонние таблицы
+		}
+		partitions[name] = month
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return partitions, nil
+}
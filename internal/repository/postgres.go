@@ -2,67 +2,243 @@ package repository
 
 import (
 	"awesomeProject/internal/models"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
+// Repository - хранилище PostgreSQL. Запросы идут через database/sql поверх пула pgx
+// (stdlib.OpenDBFromPool): драйвер и кэш подготовленных запросов - pgx, а SQL общий с
+// остальными хранилищами. Сам пул нужен только для проверки соединения и сведений о его состоянии.
 type Repository struct {
-	db  *sql.DB
-	log *zap.Logger
+	pool     *pgxpool.Pool // nil, если репозиторий создан поверх готового db
+	db       *sql.DB
+	replicas *replicaSet
+	timeout  time.Duration
+	log      *zap.Logger
 }
 
 func (s *Storage) NewRepository() *Repository {
-	return &Repository{db: s.db, log: s.log.Named("Repository")}
+	return &Repository{pool: s.pool, db: s.db, replicas: s.replicas, timeout: s.timeout, log: s.log.Named("Repository")}
+}
+
+// withTimeout ограничивает время запроса настройкой query_timeout.
+// Контекст запроса клиента сохраняется: его отмена прерывает запрос к базе.
+func (r *Repository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// read выполняет запрос чтения истории и справочников на реплике, если они заданы.
+// Изменения и чтения внутри транзакций всегда идут в основную базу.
+func (r *Repository) read(ctx context.Context, query func(db *sql.DB) error) error {
+	if r.replicas == nil {
+		return query(r.db)
+	}
+	return r.replicas.read(ctx, query)
 }
-func (r *Repository) AddCoin(coin *models.TrackedCoin) error {
-	stmt, err := r.db.Prepare(`
-        INSERT INTO tracked_coins (symbol) 
-        VALUES ($1) 
-        ON CONFLICT (symbol) DO NOTHING`)
+
+func (r *Repository) AddCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := lockCoin(ctx, tx, coin.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+
+	after, err := scanCoin(tx.QueryRowContext(ctx, `
+        INSERT INTO tracked_coins (symbol, name, provider_id, category, description, logo_url, decimals)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (symbol) DO UPDATE
+        SET name = EXCLUDED.name,
+            provider_id = EXCLUDED.provider_id,
+            category = EXCLUDED.category,
+            description = EXCLUDED.description,
+            logo_url = EXCLUDED.logo_url,
+            decimals = EXCLUDED.decimals,
+            status = 'active',
+            untracked_at = NULL,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING `+coinColumns,
+		coin.Symbol,
+		coin.Name,
+		coin.ProviderID,
+		coin.Category,
+		coin.Description,
+		coin.LogoURL,
+		coin.Decimals,
+	))
 	if err != nil {
 		r.log.Error("Failed to insert coin", zap.Error(err))
 		return fmt.Errorf("failed to insert coin: %w", err)
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(coin.Symbol)
-	return err
+	if err := writeAudit(ctx, tx, models.AuditCoinAdd, after.ID, after.Symbol, actor, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
-func (r *Repository) RemoveCoin(coin *models.TrackedCoin) error {
-	stmt, err := r.db.Prepare(`
-		DELETE FROM tracked_coins 
-        WHERE symbol = $1`)
+
+// RemoveCoin убирает монету из списка наблюдения. Строка монеты и ее история
+// цен сохраняются, повторное добавление монеты продолжает ту же историю.
+func (r *Repository) RemoveCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := lockCoin(ctx, tx, coin.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil || before.Status == models.CoinStatusUntracked {
+		return nil
+	}
+
+	after, err := scanCoin(tx.QueryRowContext(ctx, `
+        UPDATE tracked_coins
+        SET status = 'untracked',
+            untracked_at = CURRENT_TIMESTAMP,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING `+coinColumns, before.ID))
 	if err != nil {
 		r.log.Error("Failed to remove coin", zap.Error(err))
 		return fmt.Errorf("failed to remove coin: %w", err)
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(coin.Symbol)
-	return err
+
+	if err := writeAudit(ctx, tx, models.AuditCoinRemove, after.ID, after.Symbol, actor, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
-func (r *Repository) GetPrice(coin *models.GetPriceRequest) (*models.CryptoPrice, error) {
-	var price models.CryptoPrice
-	query := `
-        SELECT 
-            cp.id,
-            cp.coin_id,
-            tc.symbol,
-            cp.price,
-            cp.timestamp
-        FROM coin_prices cp
-        JOIN tracked_coins tc ON tc.id = cp.coin_id
-        WHERE tc.symbol = $1
-        ORDER BY ABS(cp.timestamp - $2)
+
+// PurgeCoin безвозвратно удаляет монету, убранную из списка наблюдения,
+// вместе со всей историей цен. Возвращает число удаленных цен.
+func (r *Repository) PurgeCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := lockCoin(ctx, tx, coin.Symbol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil {
+		return 0, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+	if before.Status != models.CoinStatusUntracked {
+		return 0, fmt.Errorf("coin %s is still tracked, remove it first", coin.Symbol)
+	}
+	// Проверка после блокировки монеты: добавление ее в список ждет конца транзакции
+	watched, err := queryCoinWatched(ctx, tx, postgresWatchlists, before.ID)
+	if err != nil {
+		return 0, err
+	}
+	if watched {
+		return 0, fmt.Errorf("coin %s is in users' watchlists: %w", coin.Symbol, models.ErrReferenced)
+	}
+
+	var deleted int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM coin_prices WHERE coin_id = $1", before.ID).Scan(&deleted); err != nil {
+		return 0, fmt.Errorf("failed to count prices: %w", err)
+	}
+	// Цены удаляются каскадно
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tracked_coins WHERE id = $1", before.ID); err != nil {
+		return 0, fmt.Errorf("failed to purge coin: %w", constraintError(err))
+	}
+
+	after := map[string]int64{"deleted_prices": deleted}
+	if err := writeAudit(ctx, tx, models.AuditCoinPurge, before.ID, before.Symbol, actor, before, after); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.log.Info("Purged coin", zap.String("symbol", coin.Symbol), zap.Int64("prices", deleted))
+	return deleted, nil
+}
+
+// lockCoin блокирует строку монеты до конца транзакции; nil - монеты нет
+func lockCoin(ctx context.Context, tx *sql.Tx, symbol string) (*models.TrackedCoin, error) {
+	coin, err := scanCoin(tx.QueryRowContext(ctx, `SELECT `+coinColumns+` FROM tracked_coins WHERE symbol = $1 FOR UPDATE`, symbol))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return coin, err
+}
+
+// getPriceQuery ищет ближайшую к моменту времени цену двумя поисками по индексу
+// (coin_id, timestamp): последнюю цену не позже момента и первую после него.
+// Из двух кандидатов выбирается ближайший, при равенстве - более ранний.
+const getPriceQuery = `
+        WITH coin AS (
+            SELECT id, symbol FROM tracked_coins WHERE symbol = $1
+        )
+        SELECT id, coin_id, symbol, price, timestamp
+        FROM (
+            (SELECT cp.id, cp.coin_id, coin.symbol, cp.price, cp.timestamp
+             FROM coin
+             JOIN coin_prices cp ON cp.coin_id = coin.id
+             WHERE cp.timestamp <= $2
+             ORDER BY cp.timestamp DESC
+             LIMIT 1)
+            UNION ALL
+            (SELECT cp.id, cp.coin_id, coin.symbol, cp.price, cp.timestamp
+             FROM coin
+             JOIN coin_prices cp ON cp.coin_id = coin.id
+             WHERE cp.timestamp > $2
+             ORDER BY cp.timestamp ASC
+             LIMIT 1)
+        ) nearest
+        ORDER BY ABS(EXTRACT(EPOCH FROM timestamp - $2)), timestamp
         LIMIT 1`
 
-	err := r.db.QueryRow(query, coin.Coin, coin.Timestamp).Scan(
-		&price.ID,
-		&price.CoinID,
-		&price.Symbol,
-		&price.Price,
-		&price.Timestamp,
-	)
+func (r *Repository) GetPrice(ctx context.Context, coin *models.GetPriceRequest) (*models.CryptoPrice, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var price models.CryptoPrice
+	err := r.read(ctx, func(db *sql.DB) error {
+		return db.QueryRowContext(ctx, getPriceQuery, coin.Coin, coin.Timestamp.Time()).Scan(
+			&price.ID,
+			&price.CoinID,
+			&price.Symbol,
+			&price.Price,
+			&price.Timestamp,
+		)
+	})
 
 	if err != nil {
 		r.log.Error("Failed to get price", zap.Error(err), zap.String("coin", coin.Coin))
@@ -70,43 +246,339 @@ func (r *Repository) GetPrice(coin *models.GetPriceRequest) (*models.CryptoPrice
 	}
 	return &price, nil
 }
-func (r *Repository) GetAllCoins() ([]*models.TrackedCoin, error) {
-	query := `SELECT id, symbol FROM tracked_coins ORDER BY symbol`
+
+// coinColumns - колонки tracked_coins в порядке полей scanCoin
+const coinColumns = `id, symbol, status, name, provider_id, category, description, logo_url, decimals`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCoin(row rowScanner) (*models.TrackedCoin, error) {
+	var coin models.TrackedCoin
+	err := row.Scan(
+		&coin.ID,
+		&coin.Symbol,
+		&coin.Status,
+		&coin.Name,
+		&coin.ProviderID,
+		&coin.Category,
+		&coin.Description,
+		&coin.LogoURL,
+		&coin.Decimals,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &coin, nil
+}
+
+// GetAllCoins возвращает список наблюдения; убранные из него монеты не входят
+func (r *Repository) GetAllCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	r.log.Debug("Getting all coins")
-	rows, err := r.db.Query(query)
+	var coins []*models.TrackedCoin
+	err := r.read(ctx, func(db *sql.DB) (err error) {
+		coins, err = queryCoins(ctx, db, r.log, `SELECT `+coinColumns+` FROM tracked_coins WHERE status <> $1 ORDER BY symbol`, models.CoinStatusUntracked)
+		return err
+	})
+	return coins, err
+}
+
+// GetActiveCoins возвращает монеты, цены которых нужно опрашивать.
+// Читает из основной базы, чтобы опрос сразу видел изменения списка наблюдения.
+func (r *Repository) GetActiveCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	r.log.Debug("Getting active coins")
+	return queryCoins(ctx, r.db, r.log, activeCoinsQuery(numberedParam), models.CoinStatusActive, models.CoinStatusUntracked)
+}
+
+func queryCoins(ctx context.Context, db *sql.DB, log *zap.Logger, query string, args ...any) ([]*models.TrackedCoin, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		r.log.Error("Failed to get all coins", zap.Error(err))
+		log.Error("Failed to get coins", zap.Error(err))
 		return nil, fmt.Errorf("failed to fetch tracked coins: %w", err)
 	}
 	defer rows.Close()
 
 	var coins []*models.TrackedCoin
 	for rows.Next() {
-		var coin models.TrackedCoin
-		if err := rows.Scan(
-			&coin.ID,
-			&coin.Symbol,
-		); err != nil {
-			r.log.Error("Failed to scan coin", zap.Error(err))
+		coin, err := scanCoin(rows)
+		if err != nil {
+			log.Error("Failed to scan coin", zap.Error(err))
 			return nil, fmt.Errorf("failed to scan coin: %w", err)
 		}
-		coins = append(coins, &coin)
+		coins = append(coins, coin)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows error: %w", err)
 	}
 
-	r.log.Debug("Successfully fetched all coins", zap.Int("count", len(coins)))
+	log.Debug("Successfully fetched coins", zap.Int("count", len(coins)))
 
 	return coins, nil
 }
 
-func (r *Repository) AddNewPrice(coin *models.CryptoPrice) error {
+func (r *Repository) GetCoin(ctx context.Context, symbol string) (*models.TrackedCoin, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var coin *models.TrackedCoin
+	err := r.read(ctx, func(db *sql.DB) (err error) {
+		coin, err = scanCoin(db.QueryRowContext(ctx, `SELECT `+coinColumns+` FROM tracked_coins WHERE symbol = $1`, symbol))
+		return err
+	})
+	if err != nil {
+		r.log.Error("Failed to get coin", zap.Error(err), zap.String("coin", symbol))
+		return nil, fmt.Errorf("failed to get coin: %w", err)
+	}
+	return coin, nil
+}
+
+// SetCoinStatus меняет состояние опроса монеты
+func (r *Repository) SetCoinStatus(ctx context.Context, symbol string, status models.CoinStatus, actor *models.Actor) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := lockCoin(ctx, tx, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil || before.Status == models.CoinStatusUntracked {
+		return fmt.Errorf("failed to set coin status: %w", sql.ErrNoRows)
+	}
+	if before.Status == status {
+		return nil
+	}
+
+	after, err := scanCoin(tx.QueryRowContext(ctx, `
+        UPDATE tracked_coins
+        SET status = $2, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING `+coinColumns, before.ID, status))
+	if err != nil {
+		r.log.Error("Failed to set coin status", zap.Error(err), zap.String("coin", symbol))
+		return fmt.Errorf("failed to set coin status: %w", err)
+	}
+
+	if err := writeAudit(ctx, tx, models.AuditCoinStatus, after.ID, after.Symbol, actor, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateCoinMetadata меняет заданные в запросе поля метаданных монеты
+func (r *Repository) UpdateCoinMetadata(ctx context.Context, req *models.UpdateMetadataRequest, actor *models.Actor) (*models.TrackedCoin, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := lockCoin(ctx, tx, req.Coin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil {
+		return nil, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+
+	after, err := scanCoin(tx.QueryRowContext(ctx, `
+        UPDATE tracked_coins
+        SET name = COALESCE($2, name),
+            category = COALESCE($3, category),
+            description = COALESCE($4, description),
+            logo_url = COALESCE($5, logo_url),
+            decimals = COALESCE($6, decimals),
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING `+coinColumns,
+		before.ID,
+		req.Name,
+		req.Category,
+		req.Description,
+		req.LogoURL,
+		req.Decimals,
+	))
+	if err != nil {
+		r.log.Error("Failed to update coin metadata", zap.Error(err), zap.String("coin", req.Coin))
+		return nil, fmt.Errorf("failed to update coin metadata: %w", err)
+	}
+
+	if err := writeAudit(ctx, tx, models.AuditCoinMetadata, after.ID, after.Symbol, actor, before, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
+}
+
+// CorrectPrice вручную записывает цену монеты в конкретный момент,
+// заменяя полученную от провайдера
+func (r *Repository) CorrectPrice(ctx context.Context, price *models.CryptoPrice, actor *models.Actor) (*models.CryptoPrice, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	coin, err := lockCoin(ctx, tx, price.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coin: %w", err)
+	}
+	if coin == nil {
+		return nil, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+
+	var before *models.CryptoPrice
+	var existing models.CryptoPrice
+	err = tx.QueryRowContext(ctx, `
+        SELECT id, coin_id, price, timestamp
+        FROM coin_prices
+        WHERE coin_id = $1 AND timestamp = $2`,
+		coin.ID, price.Timestamp.Time(),
+	).Scan(&existing.ID, &existing.CoinID, &existing.Price, &existing.Timestamp)
+	switch {
+	case err == nil:
+		existing.Symbol = coin.Symbol
+		before = &existing
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("failed to get price: %w", err)
+	}
+
+	after := models.CryptoPrice{Symbol: coin.Symbol}
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO coin_prices (coin_id, price, timestamp)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (coin_id, timestamp) DO UPDATE
+        SET price = EXCLUDED.price
+        RETURNING id, coin_id, price, timestamp`,
+		coin.ID, price.Price, price.Timestamp.Time(),
+	).Scan(&after.ID, &after.CoinID, &after.Price, &after.Timestamp)
+	if err != nil {
+		r.log.Error("Failed to correct price", zap.Error(err), zap.String("coin", price.Symbol))
+		return nil, fmt.Errorf("failed to correct price: %w", err)
+	}
+
+	if err := writeAudit(ctx, tx, models.AuditPriceCorrect, coin.ID, coin.Symbol, actor, before, &after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &after, nil
+}
+
+// GetPriceBuckets агрегирует цены монеты по интервалам фиксированной длины.
+// Границы интервалов считаются в локальном времени часового пояса запроса,
+// поэтому дневные свечи закрываются в полночь этого пояса.
+func (r *Repository) GetPriceBuckets(ctx context.Context, q *models.BucketQuery) ([]*models.PriceBucket, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+        WITH ticks AS (
+            SELECT
+                cp.price,
+                cp.timestamp,
+                date_bin(
+                    $2::interval,
+                    cp.timestamp AT TIME ZONE $3,
+                    TIMESTAMP '2000-01-03 00:00:00'
+                ) AS bucket
+            FROM coin_prices cp
+            JOIN tracked_coins tc ON tc.id = cp.coin_id
+            WHERE tc.symbol = $1
+              AND cp.timestamp >= $4
+              AND cp.timestamp < $5
+        )
+        SELECT
+            bucket AT TIME ZONE $3,
+            (array_agg(price ORDER BY timestamp ASC))[1],
+            MAX(price),
+            MIN(price),
+            (array_agg(price ORDER BY timestamp DESC))[1],
+            ROUND(AVG(price), 18),
+            COUNT(*)
+        FROM ticks
+        GROUP BY bucket
+        ORDER BY bucket`
+
+	r.log.Debug("Getting price buckets",
+		zap.String("symbol", q.Symbol),
+		zap.Duration("interval", q.Interval),
+		zap.String("timezone", q.Timezone))
+
+	interval := fmt.Sprintf("%d seconds", int64(q.Interval.Seconds()))
+	var buckets []*models.PriceBucket
+	err := r.read(ctx, func(db *sql.DB) error {
+		buckets = nil
+		rows, err := db.QueryContext(ctx, query, q.Symbol, interval, q.Timezone, q.From.Time(), q.To.Time())
+		if err != nil {
+			return fmt.Errorf("failed to get price buckets: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var bucket models.PriceBucket
+			if err := rows.Scan(
+				&bucket.Start,
+				&bucket.Open,
+				&bucket.High,
+				&bucket.Low,
+				&bucket.Close,
+				&bucket.Mean,
+				&bucket.Count,
+			); err != nil {
+				return fmt.Errorf("failed to scan price bucket: %w", err)
+			}
+			buckets = append(buckets, &bucket)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("rows error: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		r.log.Error("Failed to get price buckets", zap.Error(err), zap.String("coin", q.Symbol))
+		return nil, err
+	}
+
+	r.log.Debug("Successfully fetched price buckets", zap.Int("count", len(buckets)))
+
+	return buckets, nil
+}
+
+func (r *Repository) AddNewPrice(ctx context.Context, coin *models.CryptoPrice) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	r.log.Debug("Adding new price", zap.String("symbol", coin.Symbol))
 
 	// Используем транзакцию
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -114,7 +586,7 @@ func (r *Repository) AddNewPrice(coin *models.CryptoPrice) error {
 
 	// Проверяем существование монеты
 	var exists bool
-	err = tx.QueryRow(
+	err = tx.QueryRowContext(ctx,
 		"SELECT EXISTS(SELECT 1 FROM tracked_coins WHERE id = $1)",
 		coin.CoinID,
 	).Scan(&exists)
@@ -127,14 +599,14 @@ func (r *Repository) AddNewPrice(coin *models.CryptoPrice) error {
 	}
 
 	// Вставляем цену
-	_, err = tx.Exec(`
+	_, err = tx.ExecContext(ctx, `
         INSERT INTO coin_prices (coin_id, price, timestamp)
         VALUES ($1, $2, $3)
         ON CONFLICT (coin_id, timestamp) DO UPDATE
         SET price = EXCLUDED.price`,
 		coin.CoinID,
 		coin.Price,
-		coin.Timestamp,
+		coin.Timestamp.Time(),
 	)
 
 	if err != nil {
@@ -147,6 +619,84 @@ func (r *Repository) AddNewPrice(coin *models.CryptoPrice) error {
 
 	r.log.Debug("Successfully added new price",
 		zap.String("symbol", coin.Symbol),
-		zap.Float64("price", coin.Price))
+		zap.Stringer("price", coin.Price))
 	return nil
 }
+
+// AddNewPrices записывает цены за цикл опроса одним запросом.
+// Возвращает ошибки по строкам в порядке входного среза (nil - строка записана);
+// вторая ошибка означает, что не удалось записать весь пакет.
+func (r *Repository) AddNewPrices(ctx context.Context, prices []*models.CryptoPrice) ([]error, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	r.log.Debug("Adding new prices", zap.Int("count", len(prices)))
+
+	errs := make([]error, len(prices))
+	if len(prices) == 0 {
+		return errs, nil
+	}
+
+	// Повтор (coin_id, timestamp) внутри одного INSERT ... ON CONFLICT DO UPDATE
+	// недопустим, поэтому для дубликатов остается последняя цена
+	last := make(map[[2]int64]int, len(prices))
+	for i, price := range prices {
+		last[[2]int64{price.CoinID, int64(price.Timestamp)}] = i
+	}
+	coinIDs := make([]int64, 0, len(last))
+	values := make([]decimal.Decimal, 0, len(last))
+	timestamps := make([]time.Time, 0, len(last))
+	for i, price := range prices {
+		if last[[2]int64{price.CoinID, int64(price.Timestamp)}] != i {
+			continue
+		}
+		coinIDs = append(coinIDs, price.CoinID)
+		values = append(values, price.Price)
+		timestamps = append(timestamps, price.Timestamp.Time())
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        INSERT INTO coin_prices (coin_id, price, timestamp)
+        SELECT batch.coin_id, batch.price, batch.timestamp
+        FROM unnest($1::INTEGER[], $2::NUMERIC[], $3::TIMESTAMPTZ[]) AS batch(coin_id, price, timestamp)
+        JOIN tracked_coins tc ON tc.id = batch.coin_id
+        ON CONFLICT (coin_id, timestamp) DO UPDATE
+        SET price = EXCLUDED.price
+        RETURNING coin_id, timestamp`,
+		coinIDs,
+		values,
+		timestamps,
+	)
+	if err != nil {
+		r.log.Error("Failed to insert prices", zap.Error(err))
+		return nil, fmt.Errorf("failed to insert prices: %w", err)
+	}
+	defer rows.Close()
+
+	inserted := make(map[[2]int64]bool, len(last))
+	for rows.Next() {
+		var coinID int64
+		var timestamp models.Timestamp
+		if err := rows.Scan(&coinID, &timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan inserted price: %w", err)
+		}
+		inserted[[2]int64{coinID, int64(timestamp)}] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	written := 0
+	for i, price := range prices {
+		if !inserted[[2]int64{price.CoinID, int64(price.Timestamp)}] {
+			errs[i] = fmt.Errorf("coin with id %d not found", price.CoinID)
+			continue
+		}
+		written++
+	}
+
+	r.log.Debug("Successfully added new prices",
+		zap.Int("written", written),
+		zap.Int("failed", len(prices)-written))
+	return errs, nil
+}
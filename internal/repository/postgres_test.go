@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"awesomeProject/internal/repository/repotest"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+)
+
+var postgresSchemas atomic.Int64
+
+// TestPostgresRepository запускает общий набор проверок на PostgreSQL из TEST_POSTGRES_DSN.
+// Каждая проверка получает отдельную схему, которая удаляется после нее.
+func TestPostgresRepository(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer admin.Close()
+
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return &Repository{db: openPostgresSchema(t, admin, dsn), log: zap.NewNop()}
+	})
+}
+
+// TestPoolStatsWithoutPool проверяет репозиторий поверх готового *sql.DB, как в проверках выше:
+// без пула pgx состояние берется из database/sql. Соединение с базой не открывается.
+func TestPoolStatsWithoutPool(t *testing.T) {
+	db, err := sql.Open("pgx", "postgres://localhost/coins")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	repo := &Repository{db: db, log: zap.NewNop()}
+	if stats := repo.PoolStats(); stats.MaxConns != 7 || stats.TotalConns != 0 {
+		t.Errorf("PoolStats = %+v, want max 7 and no connections", stats)
+	}
+}
+
+// openPostgresSchema создает отдельную схему с примененными миграциями,
+// которая удаляется после проверки
+func openPostgresSchema(t *testing.T, admin *sql.DB, dsn string) *sql.DB {
+	schema := fmt.Sprintf("conformance_%d_%d", time.Now().UnixNano(), postgresSchemas.Add(1))
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	db, err := sql.Open("pgx", dsn+separator+"search_path="+schema)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrate(db, goose.DialectPostgres, "postgres", zap.NewNop()); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+	return db
+}
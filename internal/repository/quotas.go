@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+
+	"go.uber.org/zap"
+)
+
+// quotaDialect - особенности SQL таблицы api_quota_usage в диалекте хранилища
+type quotaDialect struct {
+	upsert string             // прибавление запросов клиента за сутки: client, day, requests
+	param  func(n int) string // n-й параметр запроса
+}
+
+var postgresQuotas = quotaDialect{
+	upsert: `
+        INSERT INTO api_quota_usage (client, day, requests) VALUES ($1, $2, $3)
+        ON CONFLICT (client, day) DO UPDATE SET requests = api_quota_usage.requests + excluded.requests`,
+	param: numberedParam,
+}
+
+// addQuotaUsage прибавляет запросы клиентов за сутки day одной транзакцией.
+// Клиенты обходятся в порядке имен, чтобы параллельные экземпляры не взаимоблокировались.
+func addQuotaUsage(ctx context.Context, db *sql.DB, log *zap.Logger, dialect quotaDialect, day string, usage map[string]int64) error {
+	if len(usage) == 0 {
+		return nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	for _, client := range slices.Sorted(maps.Keys(usage)) {
+		if _, err := tx.ExecContext(ctx, dialect.upsert, client, day, usage[client]); err != nil {
+			log.Error("Failed to add quota usage", zap.Error(err), zap.String("client", client), zap.String("day", day))
+			return fmt.Errorf("failed to add quota usage: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func queryQuotaUsage(ctx context.Context, db *sql.DB, log *zap.Logger, dialect quotaDialect, day string) (map[string]int64, error) {
+	rows, err := db.QueryContext(ctx, `SELECT client, requests FROM api_quota_usage WHERE day = `+dialect.param(1), day)
+	if err != nil {
+		log.Error("Failed to get quota usage", zap.Error(err), zap.String("day", day))
+		return nil, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]int64)
+	for rows.Next() {
+		var client string
+		var requests int64
+		if err := rows.Scan(&client, &requests); err != nil {
+			return nil, fmt.Errorf("failed to scan quota usage: %w", err)
+		}
+		usage[client] = requests
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return usage, nil
+}
+
+func deleteQuotaUsageBefore(ctx context.Context, db *sql.DB, dialect quotaDialect, day string) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM api_quota_usage WHERE day < `+dialect.param(1), day)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete quota usage: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// AddQuotaUsage прибавляет запросы клиентов за сутки day (YYYY-MM-DD, UTC)
+func (r *Repository) AddQuotaUsage(ctx context.Context, day string, usage map[string]int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return addQuotaUsage(ctx, r.db, r.log, postgresQuotas, day, usage)
+}
+
+// GetQuotaUsage возвращает запросы клиентов за сутки day по всем экземплярам сервиса.
+// Чтение идет в основную базу: на реплике только что записанные запросы могут отсутствовать.
+func (r *Repository) GetQuotaUsage(ctx context.Context, day string) (map[string]int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return queryQuotaUsage(ctx, r.db, r.log, postgresQuotas, day)
+}
+
+// DeleteQuotaUsageBefore удаляет учет запросов за сутки раньше day
+func (r *Repository) DeleteQuotaUsageBefore(ctx context.Context, day string) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return deleteQuotaUsageBefore(ctx, r.db, postgresQuotas, day)
+}
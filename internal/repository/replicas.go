@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultReplicaCheckInterval = 10 * time.Second
+	replicaPingTimeout          = 2 * time.Second
+)
+
+// replicaSet распределяет запросы чтения по репликам PostgreSQL по кругу.
+// Недоступная реплика исключается до следующей успешной проверки,
+// а при отсутствии доступных реплик чтение идет в основную базу.
+type replicaSet struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+	log      *zap.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type replica struct {
+	index   int
+	pool    *pgxpool.Pool
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// newReplicaSet открывает соединения с репликами. Реплика, недоступная при запуске,
+// не мешает старту сервиса: она начнет получать запросы после успешной проверки.
+func newReplicaSet(primary *sql.DB, dsns []string, config PoolConfig, log *zap.Logger) (*replicaSet, error) {
+	set := &replicaSet{primary: primary, log: log, stop: make(chan struct{})}
+	for i, dsn := range dsns {
+		pool, err := newPool(dsn, config)
+		if err != nil {
+			set.close()
+			return nil, err
+		}
+		replica := &replica{index: i, pool: pool, db: stdlib.OpenDBFromPool(pool)}
+		replica.healthy.Store(true) // первая проверка сообщит о недоступных репликах
+		set.replicas = append(set.replicas, replica)
+	}
+	set.check()
+	return set, nil
+}
+
+// start периодически проверяет доступность реплик до вызова close
+func (s *replicaSet) start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.check()
+		}
+	}
+}
+
+func (s *replicaSet) check() {
+	for _, replica := range s.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+		err := replica.pool.Ping(ctx)
+		cancel()
+		s.setHealthy(replica, err)
+	}
+}
+
+func (s *replicaSet) setHealthy(replica *replica, err error) {
+	healthy := err == nil
+	if replica.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		s.log.Info("Replica is available", zap.Int("replica", replica.index))
+	} else {
+		s.log.Warn("Replica is unavailable, reading from other replicas or primary",
+			zap.Int("replica", replica.index), zap.Error(err))
+	}
+}
+
+// pick возвращает следующую доступную реплику; nil - доступных реплик нет
+func (s *replicaSet) pick() *replica {
+	n := uint64(len(s.replicas))
+	for i := uint64(0); i < n; i++ {
+		replica := s.replicas[(s.next.Add(1)-1)%n]
+		if replica.healthy.Load() {
+			return replica
+		}
+	}
+	return nil
+}
+
+// read выполняет запрос на доступной реплике. Если реплика вернула ошибку,
+// отличную от отсутствия строк, она исключается и запрос повторяется на основной базе.
+// Отмена или истечение ctx не считается отказом реплики.
+func (s *replicaSet) read(ctx context.Context, query func(db *sql.DB) error) error {
+	replica := s.pick()
+	if replica == nil {
+		return query(s.primary)
+	}
+	err := query(replica.db)
+	if err == nil || errors.Is(err, sql.ErrNoRows) || ctx.Err() != nil {
+		return err
+	}
+	s.setHealthy(replica, err)
+	return query(s.primary)
+}
+
+func (s *replicaSet) close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	for _, replica := range s.replicas {
+		replica.db.Close()
+		replica.pool.Close()
+	}
+}
@@ -0,0 +1,1153 @@
+// Package repotest содержит общий набор проверок хранилища монет.
+// Каждая реализация хранилища (PostgreSQL, MySQL, SQLite, память) запускает
+// его из своих тестов, поэтому новое хранилище проверяется так же, как остальные:
+//
+//	func TestMemoryRepository(t *testing.T) {
+//	    repotest.Run(t, func(t *testing.T) repotest.Repository {
+//	        return NewMemoryRepository()
+//	    })
+//	}
+package repotest
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Repository повторяет контракт хранилища, который использует service.CoinService
+type Repository interface {
+	AddCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error
+	RemoveCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error
+	PurgeCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) (int64, error)
+	GetPrice(ctx context.Context, coin *models.GetPriceRequest) (*models.CryptoPrice, error)
+	GetAllCoins(ctx context.Context) ([]*models.TrackedCoin, error)
+	GetActiveCoins(ctx context.Context) ([]*models.TrackedCoin, error)
+	GetCoin(ctx context.Context, symbol string) (*models.TrackedCoin, error)
+	SetCoinStatus(ctx context.Context, symbol string, status models.CoinStatus, actor *models.Actor) error
+	UpdateCoinMetadata(ctx context.Context, req *models.UpdateMetadataRequest, actor *models.Actor) (*models.TrackedCoin, error)
+	CorrectPrice(ctx context.Context, price *models.CryptoPrice, actor *models.Actor) (*models.CryptoPrice, error)
+	GetAuditLog(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error)
+	AddNewPrice(ctx context.Context, coin *models.CryptoPrice) error
+	AddNewPrices(ctx context.Context, prices []*models.CryptoPrice) ([]error, error)
+	GetPriceBuckets(ctx context.Context, q *models.BucketQuery) ([]*models.PriceBucket, error)
+	CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64, revokedBy string, at models.Timestamp) error
+	AddQuotaUsage(ctx context.Context, day string, usage map[string]int64) error
+	GetQuotaUsage(ctx context.Context, day string) (map[string]int64, error)
+	DeleteQuotaUsageBefore(ctx context.Context, day string) (int64, error)
+	CreateWatchlist(ctx context.Context, w *models.Watchlist) error
+	GetWatchlist(ctx context.Context, id int64) (*models.Watchlist, error)
+	ListWatchlists(ctx context.Context, user string) ([]*models.Watchlist, error)
+	RenameWatchlist(ctx context.Context, id int64, name string) error
+	DeleteWatchlist(ctx context.Context, id int64) error
+	AddWatchlistCoin(ctx context.Context, id int64, coin *models.TrackedCoin) error
+	RemoveWatchlistCoin(ctx context.Context, id, coinID int64) error
+	ShareWatchlist(ctx context.Context, id int64, user string) error
+	UnshareWatchlist(ctx context.Context, id int64, user string) error
+	IsCoinWatched(ctx context.Context, coinID int64) (bool, error)
+	CreateAlertRule(ctx context.Context, rule *models.AlertRule) error
+	GetAlertRule(ctx context.Context, id int64) (*models.AlertRule, error)
+	ListAlertRules(ctx context.Context, owner string) ([]*models.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, id int64) error
+	UpdateAlertState(ctx context.Context, rule *models.AlertRule, event *models.AlertEvent) (bool, error)
+	GetAlertEvents(ctx context.Context, filter *models.AlertEventFilter) ([]*models.AlertEvent, error)
+}
+
+// Run выполняет набор проверок. open вызывается для каждой проверки
+// и должен возвращать пустое хранилище.
+func Run(t *testing.T, open func(t *testing.T) Repository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, r Repository)
+	}{
+		{"AddCoin", testAddCoin},
+		{"AddCoinUpsert", testAddCoinUpsert},
+		{"ListOrdering", testListOrdering},
+		{"RemoveCoin", testRemoveCoin},
+		{"PurgeCoin", testPurgeCoin},
+		{"SetCoinStatus", testSetCoinStatus},
+		{"UpdateCoinMetadata", testUpdateCoinMetadata},
+		{"GetPriceNearest", testGetPriceNearest},
+		{"SubSecondPrices", testSubSecondPrices},
+		{"AddNewPriceUpsert", testAddNewPriceUpsert},
+		{"AddNewPrices", testAddNewPrices},
+		{"CorrectPrice", testCorrectPrice},
+		{"GetPriceBuckets", testGetPriceBuckets},
+		{"GetPriceBucketsTimezone", testGetPriceBucketsTimezone},
+		{"GetAuditLog", testGetAuditLog},
+		{"APIKeys", testAPIKeys},
+		{"QuotaUsage", testQuotaUsage},
+		{"Watchlists", testWatchlists},
+		{"WatchlistPolling", testWatchlistPolling},
+		{"AlertRules", testAlertRules},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, open(t))
+		})
+	}
+}
+
+var (
+	alice = &models.Actor{Name: "alice", RequestID: "req-alice"}
+	bob   = &models.Actor{Name: "bob", RequestID: "req-bob"}
+)
+
+// baseTs - полночь 2023-11-14 UTC
+const baseTs = models.Timestamp(1_699_920_000_000)
+
+// sec - секунда в единицах models.Timestamp
+const sec = models.Timestamp(1000)
+
+func addCoin(t *testing.T, r Repository, symbol string) *models.TrackedCoin {
+	t.Helper()
+	coin := &models.TrackedCoin{
+		Symbol: symbol,
+		CoinMetadata: models.CoinMetadata{
+			Name:        symbol + " coin",
+			ProviderID:  "provider-" + symbol,
+			Category:    "layer-1",
+			Description: "about " + symbol,
+			LogoURL:     "https://example.com/" + symbol + ".png",
+			Decimals:    8,
+		},
+	}
+	if err := r.AddCoin(t.Context(), coin, alice); err != nil {
+		t.Fatalf("AddCoin(%s): %v", symbol, err)
+	}
+	return getCoin(t, r, symbol)
+}
+
+func getCoin(t *testing.T, r Repository, symbol string) *models.TrackedCoin {
+	t.Helper()
+	coin, err := r.GetCoin(t.Context(), symbol)
+	if err != nil {
+		t.Fatalf("GetCoin(%s): %v", symbol, err)
+	}
+	return coin
+}
+
+func addPrices(t *testing.T, r Repository, coinID int64, prices map[models.Timestamp]string) {
+	t.Helper()
+	for ts, price := range prices {
+		if err := r.AddNewPrice(t.Context(), &models.CryptoPrice{CoinID: coinID, Price: dec(price), Timestamp: ts}); err != nil {
+			t.Fatalf("AddNewPrice(%d, %s): %v", ts, price, err)
+		}
+	}
+}
+
+func getPrice(t *testing.T, r Repository, symbol string, ts models.Timestamp) *models.CryptoPrice {
+	t.Helper()
+	price, err := r.GetPrice(t.Context(), &models.GetPriceRequest{Coin: symbol, Timestamp: ts})
+	if err != nil {
+		t.Fatalf("GetPrice(%s, %d): %v", symbol, ts, err)
+	}
+	return price
+}
+
+func symbols(coins []*models.TrackedCoin) []string {
+	result := make([]string, len(coins))
+	for i, coin := range coins {
+		result[i] = coin.Symbol
+	}
+	return result
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func expectDecimal(t *testing.T, what string, got decimal.Decimal, want string) {
+	t.Helper()
+	if !got.Equal(dec(want)) {
+		t.Errorf("%s = %s, want %s", what, got, want)
+	}
+}
+
+func expectNoRows(t *testing.T, what string, err error) {
+	t.Helper()
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("%s: error = %v, want sql.ErrNoRows", what, err)
+	}
+}
+
+func expectSymbols(t *testing.T, what string, got []*models.TrackedCoin, want ...string) {
+	t.Helper()
+	gotSymbols := symbols(got)
+	if len(gotSymbols) != len(want) {
+		t.Errorf("%s = %v, want %v", what, gotSymbols, want)
+		return
+	}
+	for i := range want {
+		if gotSymbols[i] != want[i] {
+			t.Errorf("%s = %v, want %v", what, gotSymbols, want)
+			return
+		}
+	}
+}
+
+func auditLog(t *testing.T, r Repository, filter *models.AuditFilter) []*models.AuditEntry {
+	t.Helper()
+	entries, err := r.GetAuditLog(t.Context(), filter)
+	if err != nil {
+		t.Fatalf("GetAuditLog: %v", err)
+	}
+	return entries
+}
+
+func testAddCoin(t *testing.T, r Repository) {
+	coin := addCoin(t, r, "BTC")
+	if coin.ID == 0 {
+		t.Errorf("ID = 0, want assigned id")
+	}
+	if coin.Status != models.CoinStatusActive {
+		t.Errorf("Status = %q, want %q", coin.Status, models.CoinStatusActive)
+	}
+	want := models.CoinMetadata{
+		Name:        "BTC coin",
+		ProviderID:  "provider-BTC",
+		Category:    "layer-1",
+		Description: "about BTC",
+		LogoURL:     "https://example.com/BTC.png",
+		Decimals:    8,
+	}
+	if coin.CoinMetadata != want {
+		t.Errorf("CoinMetadata = %+v, want %+v", coin.CoinMetadata, want)
+	}
+
+	_, err := r.GetCoin(t.Context(), "ETH")
+	expectNoRows(t, "GetCoin(ETH)", err)
+}
+
+func testAddCoinUpsert(t *testing.T, r Repository) {
+	first := addCoin(t, r, "BTC")
+
+	err := r.AddCoin(t.Context(), &models.TrackedCoin{
+		Symbol:       "BTC",
+		CoinMetadata: models.CoinMetadata{Name: "Bitcoin", ProviderID: "bitcoin", Decimals: 8},
+	}, bob)
+	if err != nil {
+		t.Fatalf("AddCoin(BTC) again: %v", err)
+	}
+
+	second := getCoin(t, r, "BTC")
+	if second.ID != first.ID {
+		t.Errorf("ID after upsert = %d, want %d", second.ID, first.ID)
+	}
+	if second.Name != "Bitcoin" || second.ProviderID != "bitcoin" {
+		t.Errorf("metadata after upsert = %+v, want refreshed", second.CoinMetadata)
+	}
+
+	coins, err := r.GetAllCoins(t.Context())
+	if err != nil {
+		t.Fatalf("GetAllCoins: %v", err)
+	}
+	expectSymbols(t, "GetAllCoins", coins, "BTC")
+}
+
+func testListOrdering(t *testing.T, r Repository) {
+	for _, symbol := range []string{"SOL", "BTC", "XRP", "ETH"} {
+		addCoin(t, r, symbol)
+	}
+	if err := r.SetCoinStatus(t.Context(), "XRP", models.CoinStatusPaused, alice); err != nil {
+		t.Fatalf("SetCoinStatus(XRP): %v", err)
+	}
+	if err := r.RemoveCoin(t.Context(), &models.TrackedCoin{Symbol: "ETH"}, alice); err != nil {
+		t.Fatalf("RemoveCoin(ETH): %v", err)
+	}
+
+	all, err := r.GetAllCoins(t.Context())
+	if err != nil {
+		t.Fatalf("GetAllCoins: %v", err)
+	}
+	expectSymbols(t, "GetAllCoins", all, "BTC", "SOL", "XRP")
+
+	active, err := r.GetActiveCoins(t.Context())
+	if err != nil {
+		t.Fatalf("GetActiveCoins: %v", err)
+	}
+	expectSymbols(t, "GetActiveCoins", active, "BTC", "SOL")
+}
+
+func testRemoveCoin(t *testing.T, r Repository) {
+	coin := addCoin(t, r, "BTC")
+	addPrices(t, r, coin.ID, map[models.Timestamp]string{baseTs: "100"})
+
+	if err := r.RemoveCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, alice); err != nil {
+		t.Fatalf("RemoveCoin(BTC): %v", err)
+	}
+	removed := getCoin(t, r, "BTC")
+	if removed.Status != models.CoinStatusUntracked {
+		t.Errorf("Status = %q, want %q", removed.Status, models.CoinStatusUntracked)
+	}
+	// История цен сохраняется
+	expectDecimal(t, "price after remove", getPrice(t, r, "BTC", baseTs).Price, "100")
+
+	// Повторное и несуществующее удаление - не ошибка
+	if err := r.RemoveCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, alice); err != nil {
+		t.Errorf("RemoveCoin(BTC) again: %v", err)
+	}
+	if err := r.RemoveCoin(t.Context(), &models.TrackedCoin{Symbol: "ETH"}, alice); err != nil {
+		t.Errorf("RemoveCoin(ETH): %v", err)
+	}
+
+	// Повторное добавление возвращает монету с той же историей
+	readded := addCoin(t, r, "BTC")
+	if readded.ID != coin.ID || readded.Status != models.CoinStatusActive {
+		t.Errorf("re-added coin = %+v, want id %d and active", readded, coin.ID)
+	}
+	expectDecimal(t, "price after re-add", getPrice(t, r, "BTC", baseTs).Price, "100")
+}
+
+func testPurgeCoin(t *testing.T, r Repository) {
+	coin := addCoin(t, r, "BTC")
+	addPrices(t, r, coin.ID, map[models.Timestamp]string{baseTs: "100", baseTs + 60*sec: "101"})
+
+	if _, err := r.PurgeCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, alice); err == nil {
+		t.Errorf("PurgeCoin of a tracked coin succeeded, want error")
+	}
+	_, err := r.PurgeCoin(t.Context(), &models.TrackedCoin{Symbol: "ETH"}, alice)
+	expectNoRows(t, "PurgeCoin(ETH)", err)
+
+	if err := r.RemoveCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, alice); err != nil {
+		t.Fatalf("RemoveCoin(BTC): %v", err)
+	}
+	deleted, err := r.PurgeCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, alice)
+	if err != nil {
+		t.Fatalf("PurgeCoin(BTC): %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted prices = %d, want 2", deleted)
+	}
+
+	_, err = r.GetCoin(t.Context(), "BTC")
+	expectNoRows(t, "GetCoin after purge", err)
+	_, err = r.GetPrice(t.Context(), &models.GetPriceRequest{Coin: "BTC", Timestamp: baseTs})
+	expectNoRows(t, "GetPrice after purge", err)
+
+	entries := auditLog(t, r, &models.AuditFilter{Coin: "BTC", Limit: 1})
+	if len(entries) != 1 || entries[0].Action != models.AuditCoinPurge {
+		t.Fatalf("last audit entry = %+v, want %s", entries, models.AuditCoinPurge)
+	}
+	var after struct {
+		DeletedPrices int64 `json:"deleted_prices"`
+	}
+	if err := json.Unmarshal(entries[0].After, &after); err != nil || after.DeletedPrices != 2 {
+		t.Errorf("purge audit after = %s, want deleted_prices 2", entries[0].After)
+	}
+}
+
+func testSetCoinStatus(t *testing.T, r Repository) {
+	addCoin(t, r, "BTC")
+
+	if err := r.SetCoinStatus(t.Context(), "BTC", models.CoinStatusPaused, alice); err != nil {
+		t.Fatalf("SetCoinStatus(BTC): %v", err)
+	}
+	if coin := getCoin(t, r, "BTC"); coin.Status != models.CoinStatusPaused {
+		t.Errorf("Status = %q, want %q", coin.Status, models.CoinStatusPaused)
+	}
+
+	// Установка того же состояния ничего не меняет и не пишется в журнал
+	before := len(auditLog(t, r, &models.AuditFilter{Coin: "BTC"}))
+	if err := r.SetCoinStatus(t.Context(), "BTC", models.CoinStatusPaused, alice); err != nil {
+		t.Fatalf("SetCoinStatus(BTC) again: %v", err)
+	}
+	if after := len(auditLog(t, r, &models.AuditFilter{Coin: "BTC"})); after != before {
+		t.Errorf("audit entries after no-op status = %d, want %d", after, before)
+	}
+
+	expectNoRows(t, "SetCoinStatus(ETH)", r.SetCoinStatus(t.Context(), "ETH", models.CoinStatusPaused, alice))
+
+	if err := r.RemoveCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, alice); err != nil {
+		t.Fatalf("RemoveCoin(BTC): %v", err)
+	}
+	expectNoRows(t, "SetCoinStatus of an untracked coin", r.SetCoinStatus(t.Context(), "BTC", models.CoinStatusActive, alice))
+}
+
+func testUpdateCoinMetadata(t *testing.T, r Repository) {
+	addCoin(t, r, "BTC")
+
+	name := "Bitcoin"
+	decimals := 18
+	updated, err := r.UpdateCoinMetadata(t.Context(), &models.UpdateMetadataRequest{Coin: "BTC", Name: &name, Decimals: &decimals}, alice)
+	if err != nil {
+		t.Fatalf("UpdateCoinMetadata(BTC): %v", err)
+	}
+	if updated.Name != name || updated.Decimals != decimals {
+		t.Errorf("updated = %+v, want name %q and decimals %d", updated.CoinMetadata, name, decimals)
+	}
+	// Поля, не заданные в запросе, не меняются
+	if updated.Category != "layer-1" || updated.Description != "about BTC" {
+		t.Errorf("updated = %+v, want untouched category and description", updated.CoinMetadata)
+	}
+	if stored := getCoin(t, r, "BTC"); *stored != *updated {
+		t.Errorf("GetCoin = %+v, want %+v", stored, updated)
+	}
+
+	_, err = r.UpdateCoinMetadata(t.Context(), &models.UpdateMetadataRequest{Coin: "ETH", Name: &name}, alice)
+	expectNoRows(t, "UpdateCoinMetadata(ETH)", err)
+}
+
+func testGetPriceNearest(t *testing.T, r Repository) {
+	btc := addCoin(t, r, "BTC")
+	eth := addCoin(t, r, "ETH")
+	addPrices(t, r, btc.ID, map[models.Timestamp]string{baseTs + 100*sec: "1", baseTs + 200*sec: "2", baseTs + 300*sec: "3"})
+	addPrices(t, r, eth.ID, map[models.Timestamp]string{baseTs + 150*sec: "50"})
+
+	tests := []struct {
+		ts   models.Timestamp
+		want models.Timestamp
+	}{
+		{baseTs, baseTs + 100*sec},           // раньше всей истории
+		{baseTs + 100*sec, baseTs + 100*sec}, // точное совпадение
+		{baseTs + 140*sec, baseTs + 100*sec},
+		{baseTs + 150*sec, baseTs + 100*sec}, // посередине - более ранняя цена
+		{baseTs + 160*sec, baseTs + 200*sec},
+		{baseTs + 1000*sec, baseTs + 300*sec}, // позже всей истории
+	}
+	for _, tt := range tests {
+		price := getPrice(t, r, "BTC", tt.ts)
+		if price.Timestamp != tt.want || price.Symbol != "BTC" || price.CoinID != btc.ID {
+			t.Errorf("GetPrice(BTC, %d) = %+v, want BTC at %d", tt.ts, price, tt.want)
+		}
+	}
+
+	_, err := r.GetPrice(t.Context(), &models.GetPriceRequest{Coin: "SOL", Timestamp: baseTs})
+	expectNoRows(t, "GetPrice(SOL)", err)
+}
+
+func testSubSecondPrices(t *testing.T, r Repository) {
+	coin := addCoin(t, r, "BTC")
+	addPrices(t, r, coin.ID, map[models.Timestamp]string{baseTs + 100: "1", baseTs + 400: "2", baseTs + 900: "3"})
+
+	tests := []struct {
+		ts   models.Timestamp
+		want string
+	}{
+		{baseTs, "1"},
+		{baseTs + 400, "2"}, // точное совпадение в пределах секунды
+		{baseTs + 600, "2"},
+		{baseTs + 700, "3"},
+		{baseTs + sec, "3"},
+	}
+	for _, tt := range tests {
+		price := getPrice(t, r, "BTC", tt.ts)
+		expectDecimal(t, "price at "+tt.ts.String(), price.Price, tt.want)
+	}
+	if price := getPrice(t, r, "BTC", baseTs+400); price.Timestamp != baseTs+400 {
+		t.Errorf("timestamp = %d, want %d", price.Timestamp, baseTs+400)
+	}
+}
+
+func testAddNewPriceUpsert(t *testing.T, r Repository) {
+	coin := addCoin(t, r, "BTC")
+	addPrices(t, r, coin.ID, map[models.Timestamp]string{baseTs: "1.5"})
+	first := getPrice(t, r, "BTC", baseTs)
+	expectDecimal(t, "price", first.Price, "1.5")
+
+	addPrices(t, r, coin.ID, map[models.Timestamp]string{baseTs: "2.000000000000000001"})
+	second := getPrice(t, r, "BTC", baseTs)
+	expectDecimal(t, "price after upsert", second.Price, "2.000000000000000001")
+	if second.ID != first.ID {
+		t.Errorf("ID after upsert = %d, want %d", second.ID, first.ID)
+	}
+
+	err := r.AddNewPrice(t.Context(), &models.CryptoPrice{CoinID: coin.ID + 1000, Price: dec("1"), Timestamp: baseTs})
+	if err == nil {
+		t.Errorf("AddNewPrice for an unknown coin succeeded, want error")
+	}
+}
+
+func testAddNewPrices(t *testing.T, r Repository) {
+	btc := addCoin(t, r, "BTC")
+	eth := addCoin(t, r, "ETH")
+
+	errs, err := r.AddNewPrices(t.Context(), nil)
+	if err != nil || len(errs) != 0 {
+		t.Fatalf("AddNewPrices(nil) = %v, %v, want no errors", errs, err)
+	}
+
+	errs, err = r.AddNewPrices(t.Context(), []*models.CryptoPrice{
+		{CoinID: btc.ID, Price: dec("10"), Timestamp: baseTs},
+		{CoinID: eth.ID + 1000, Price: dec("20"), Timestamp: baseTs},
+		{CoinID: eth.ID, Price: dec("30"), Timestamp: baseTs},
+		{CoinID: btc.ID, Price: dec("11"), Timestamp: baseTs}, // повтор в пакете - остается последняя цена
+	})
+	if err != nil {
+		t.Fatalf("AddNewPrices: %v", err)
+	}
+	if len(errs) != 4 {
+		t.Fatalf("len(errs) = %d, want 4", len(errs))
+	}
+	for i, e := range errs {
+		if failed := e != nil; failed != (i == 1) {
+			t.Errorf("errs[%d] = %v", i, e)
+		}
+	}
+
+	expectDecimal(t, "BTC price", getPrice(t, r, "BTC", baseTs).Price, "11")
+	expectDecimal(t, "ETH price", getPrice(t, r, "ETH", baseTs).Price, "30")
+}
+
+func testCorrectPrice(t *testing.T, r Repository) {
+	coin := addCoin(t, r, "BTC")
+
+	inserted, err := r.CorrectPrice(t.Context(), &models.CryptoPrice{Symbol: "BTC", Price: dec("100"), Timestamp: baseTs}, alice)
+	if err != nil {
+		t.Fatalf("CorrectPrice(BTC): %v", err)
+	}
+	if inserted.ID == 0 || inserted.CoinID != coin.ID || inserted.Symbol != "BTC" || inserted.Timestamp != baseTs {
+		t.Errorf("CorrectPrice = %+v, want new BTC price at %d", inserted, int64(baseTs))
+	}
+	expectDecimal(t, "corrected price", inserted.Price, "100")
+
+	replaced, err := r.CorrectPrice(t.Context(), &models.CryptoPrice{Symbol: "BTC", Price: dec("99.5"), Timestamp: baseTs}, bob)
+	if err != nil {
+		t.Fatalf("CorrectPrice(BTC) again: %v", err)
+	}
+	if replaced.ID != inserted.ID {
+		t.Errorf("ID after correction = %d, want %d", replaced.ID, inserted.ID)
+	}
+	expectDecimal(t, "replaced price", replaced.Price, "99.5")
+	expectDecimal(t, "GetPrice", getPrice(t, r, "BTC", baseTs).Price, "99.5")
+
+	entries := auditLog(t, r, &models.AuditFilter{Actor: "bob"})
+	if len(entries) != 1 || entries[0].Action != models.AuditPriceCorrect {
+		t.Fatalf("bob's audit entries = %+v, want one %s", entries, models.AuditPriceCorrect)
+	}
+	var before models.CryptoPrice
+	if err := json.Unmarshal(entries[0].Before, &before); err != nil || !before.Price.Equal(dec("100")) {
+		t.Errorf("correction audit before = %s, want price 100", entries[0].Before)
+	}
+
+	_, err = r.CorrectPrice(t.Context(), &models.CryptoPrice{Symbol: "ETH", Price: dec("1"), Timestamp: baseTs}, alice)
+	expectNoRows(t, "CorrectPrice(ETH)", err)
+}
+
+func testGetPriceBuckets(t *testing.T, r Repository) {
+	coin := addCoin(t, r, "BTC")
+	addPrices(t, r, coin.ID, map[models.Timestamp]string{
+		baseTs:            "1",
+		baseTs + 1800*sec: "3",
+		baseTs + 3599*sec: "2",
+		baseTs + 3600*sec: "5",
+		baseTs + 7200*sec: "7", // за пределами [From, To)
+	})
+
+	buckets, err := r.GetPriceBuckets(t.Context(), &models.BucketQuery{
+		Symbol:   "BTC",
+		From:     baseTs,
+		To:       baseTs + 7200*sec,
+		Interval: time.Hour,
+		Timezone: "UTC",
+	})
+	if err != nil {
+		t.Fatalf("GetPriceBuckets: %v", err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("len(buckets) = %d, want 2", len(buckets))
+	}
+
+	first := buckets[0]
+	if first.Start != baseTs || first.Count != 3 {
+		t.Errorf("first bucket start %d count %d, want %d and 3", first.Start, first.Count, int64(baseTs))
+	}
+	expectDecimal(t, "open", first.Open, "1")
+	expectDecimal(t, "high", first.High, "3")
+	expectDecimal(t, "low", first.Low, "1")
+	expectDecimal(t, "close", first.Close, "2")
+	expectDecimal(t, "mean", first.Mean, "2")
+
+	second := buckets[1]
+	if second.Start != baseTs+3600*sec || second.Count != 1 {
+		t.Errorf("second bucket start %d count %d, want %d and 1", second.Start, second.Count, int64(baseTs+3600*sec))
+	}
+	expectDecimal(t, "second close", second.Close, "5")
+
+	none, err := r.GetPriceBuckets(t.Context(), &models.BucketQuery{Symbol: "ETH", From: baseTs, To: baseTs + 7200*sec, Interval: time.Hour, Timezone: "UTC"})
+	if err != nil || len(none) != 0 {
+		t.Errorf("GetPriceBuckets(ETH) = %v, %v, want no buckets", none, err)
+	}
+}
+
+func testGetPriceBucketsTimezone(t *testing.T, r Repository) {
+	coin := addCoin(t, r, "BTC")
+	// Asia/Kolkata - UTC+5:30: сутки начинаются в 18:30 UTC предыдущего дня
+	const dayStart = baseTs - 19800*sec
+	addPrices(t, r, coin.ID, map[models.Timestamp]string{
+		baseTs:               "1",
+		baseTs + 18000*sec:   "2",
+		dayStart + 86400*sec: "3",
+	})
+
+	buckets, err := r.GetPriceBuckets(t.Context(), &models.BucketQuery{
+		Symbol:   "BTC",
+		From:     dayStart,
+		To:       dayStart + 2*86400*sec,
+		Interval: 24 * time.Hour,
+		Timezone: "Asia/Kolkata",
+	})
+	if err != nil {
+		t.Fatalf("GetPriceBuckets: %v", err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("len(buckets) = %d, want 2", len(buckets))
+	}
+	if buckets[0].Start != dayStart || buckets[0].Count != 2 {
+		t.Errorf("first bucket start %d count %d, want %d and 2", buckets[0].Start, buckets[0].Count, int64(dayStart))
+	}
+	if buckets[1].Start != dayStart+86400*sec || buckets[1].Count != 1 {
+		t.Errorf("second bucket start %d count %d, want %d and 1", buckets[1].Start, buckets[1].Count, int64(dayStart+86400*sec))
+	}
+	expectDecimal(t, "mean", buckets[0].Mean, "1.5")
+
+	// Сутки перехода на летнее время в Берлине короче на час: 31 марта 2024 года
+	// начинается в 23:00 UTC предыдущего дня, а 1 апреля - уже в 22:00 UTC
+	eth := addCoin(t, r, "ETH")
+	at := func(day, hour, minute int) models.Timestamp {
+		return models.TimestampOf(time.Date(2024, time.March, day, hour, minute, 0, 0, time.UTC))
+	}
+	addPrices(t, r, eth.ID, map[models.Timestamp]string{
+		at(31, 0, 30):  "4", // 01:30 CET
+		at(31, 21, 30): "6", // 23:30 CEST
+		at(31, 22, 30): "5", // 00:30 CEST 1 апреля
+	})
+	buckets, err = r.GetPriceBuckets(t.Context(), &models.BucketQuery{
+		Symbol:   "ETH",
+		From:     at(30, 23, 0),
+		To:       at(32, 22, 0),
+		Interval: 24 * time.Hour,
+		Timezone: "Europe/Berlin",
+	})
+	if err != nil {
+		t.Fatalf("GetPriceBuckets(Europe/Berlin): %v", err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("len(buckets) = %d, want 2", len(buckets))
+	}
+	if buckets[0].Start != at(30, 23, 0) || buckets[0].Count != 2 || buckets[1].Start != at(31, 22, 0) || buckets[1].Count != 1 {
+		t.Errorf("buckets start %d/%d count %d/%d, want %d/%d and 2/1", buckets[0].Start, buckets[1].Start,
+			buckets[0].Count, buckets[1].Count, int64(at(30, 23, 0)), int64(at(31, 22, 0)))
+	}
+	expectDecimal(t, "open", buckets[0].Open, "4")
+	expectDecimal(t, "close", buckets[0].Close, "6")
+}
+
+func testGetAuditLog(t *testing.T, r Repository) {
+	started := models.TimestampOf(time.Now())
+	btc := addCoin(t, r, "BTC")
+	if err := r.AddCoin(t.Context(), &models.TrackedCoin{Symbol: "ETH"}, bob); err != nil {
+		t.Fatalf("AddCoin(ETH): %v", err)
+	}
+	if err := r.SetCoinStatus(t.Context(), "BTC", models.CoinStatusPaused, bob); err != nil {
+		t.Fatalf("SetCoinStatus(BTC): %v", err)
+	}
+
+	entries := auditLog(t, r, &models.AuditFilter{})
+	if len(entries) != 3 {
+		t.Fatalf("len(entries) = %d, want 3", len(entries))
+	}
+	// От новых к старым
+	wantActions := []models.AuditAction{models.AuditCoinStatus, models.AuditCoinAdd, models.AuditCoinAdd}
+	wantSymbols := []string{"BTC", "ETH", "BTC"}
+	for i, entry := range entries {
+		if entry.Action != wantActions[i] || entry.Symbol != wantSymbols[i] {
+			t.Errorf("entries[%d] = %s %s, want %s %s", i, entry.Action, entry.Symbol, wantActions[i], wantSymbols[i])
+		}
+	}
+
+	added := entries[2]
+	if added.Actor != alice.Name || added.RequestID != alice.RequestID {
+		t.Errorf("actor = %q/%q, want %q/%q", added.Actor, added.RequestID, alice.Name, alice.RequestID)
+	}
+	if added.CoinID == nil || *added.CoinID != btc.ID {
+		t.Errorf("CoinID = %v, want %d", added.CoinID, btc.ID)
+	}
+	if added.Timestamp < started-60*sec || added.Timestamp > models.TimestampOf(time.Now())+60*sec {
+		t.Errorf("Timestamp = %d, want about %d", added.Timestamp, started)
+	}
+	if string(added.Before) != "null" {
+		t.Errorf("Before of a new coin = %s, want null", added.Before)
+	}
+	var after models.TrackedCoin
+	if err := json.Unmarshal(added.After, &after); err != nil || after.Symbol != "BTC" || after.Status != models.CoinStatusActive {
+		t.Errorf("After = %s, want active BTC", added.After)
+	}
+
+	var statusBefore, statusAfter models.TrackedCoin
+	if err := json.Unmarshal(entries[0].Before, &statusBefore); err != nil || statusBefore.Status != models.CoinStatusActive {
+		t.Errorf("status Before = %s, want active", entries[0].Before)
+	}
+	if err := json.Unmarshal(entries[0].After, &statusAfter); err != nil || statusAfter.Status != models.CoinStatusPaused {
+		t.Errorf("status After = %s, want paused", entries[0].After)
+	}
+
+	if got := auditLog(t, r, &models.AuditFilter{Coin: "BTC"}); len(got) != 2 {
+		t.Errorf("entries for BTC = %d, want 2", len(got))
+	}
+	if got := auditLog(t, r, &models.AuditFilter{Actor: "bob"}); len(got) != 2 {
+		t.Errorf("entries by bob = %d, want 2", len(got))
+	}
+	if got := auditLog(t, r, &models.AuditFilter{Coin: "BTC", Actor: "bob"}); len(got) != 1 {
+		t.Errorf("entries for BTC by bob = %d, want 1", len(got))
+	}
+	if got := auditLog(t, r, &models.AuditFilter{Limit: 1}); len(got) != 1 || got[0].Action != models.AuditCoinStatus {
+		t.Errorf("limited entries = %+v, want the newest one", got)
+	}
+	if got := auditLog(t, r, &models.AuditFilter{From: started - 60*sec, To: models.TimestampOf(time.Now()) + 60*sec}); len(got) != 3 {
+		t.Errorf("entries in time range = %d, want 3", len(got))
+	}
+	if got := auditLog(t, r, &models.AuditFilter{From: models.TimestampOf(time.Now()) + 3600*sec}); len(got) != 0 {
+		t.Errorf("entries from the future = %d, want 0", len(got))
+	}
+}
+
+func testAPIKeys(t *testing.T, r Repository) {
+	readKey := &models.APIKey{Name: "grafana", Prefix: "cct_a1b2", Scopes: []models.Scope{models.ScopeRead}, CreatedBy: "admin", CreatedAt: baseTs}
+	if err := r.CreateAPIKey(t.Context(), readKey, "hash-read"); err != nil {
+		t.Fatalf("CreateAPIKey(grafana): %v", err)
+	}
+	adminKey := &models.APIKey{Name: "ops", Prefix: "cct_c3d4", Scopes: []models.Scope{models.ScopeWrite, models.ScopeAdmin}, CreatedBy: "admin", CreatedAt: baseTs + sec}
+	if err := r.CreateAPIKey(t.Context(), adminKey, "hash-admin"); err != nil {
+		t.Fatalf("CreateAPIKey(ops): %v", err)
+	}
+	if readKey.ID == 0 || adminKey.ID == readKey.ID {
+		t.Fatalf("key IDs = %d, %d, want distinct assigned IDs", readKey.ID, adminKey.ID)
+	}
+	if err := r.CreateAPIKey(t.Context(), &models.APIKey{Name: "dup", Prefix: "cct_a1b2", Scopes: []models.Scope{models.ScopeRead}, CreatedBy: "admin", CreatedAt: baseTs}, "hash-read"); err == nil {
+		t.Errorf("CreateAPIKey with a duplicate hash succeeded")
+	}
+	// Имена уникальны без учета регистра, повтор - models.ErrDuplicate
+	for _, name := range []string{"grafana", "GraFana"} {
+		err := r.CreateAPIKey(t.Context(), &models.APIKey{Name: name, Prefix: "cct_e5f6", Scopes: []models.Scope{models.ScopeRead}, CreatedBy: "admin", CreatedAt: baseTs}, "hash-"+name)
+		if !errors.Is(err, models.ErrDuplicate) {
+			t.Errorf("CreateAPIKey(%s) error = %v, want ErrDuplicate", name, err)
+		}
+	}
+
+	got, err := r.GetAPIKeyByHash(t.Context(), "hash-admin")
+	if err != nil {
+		t.Fatalf("GetAPIKeyByHash: %v", err)
+	}
+	if got.ID != adminKey.ID || got.Name != "ops" || got.Prefix != "cct_c3d4" || got.CreatedBy != "admin" || got.CreatedAt != baseTs+sec || got.RevokedAt != nil {
+		t.Errorf("GetAPIKeyByHash = %+v, want %+v", got, adminKey)
+	}
+	if len(got.Scopes) != 2 || got.Scopes[0] != models.ScopeWrite || got.Scopes[1] != models.ScopeAdmin {
+		t.Errorf("Scopes = %v, want [write admin]", got.Scopes)
+	}
+	_, err = r.GetAPIKeyByHash(t.Context(), "hash-unknown")
+	expectNoRows(t, "GetAPIKeyByHash(unknown)", err)
+
+	if err := r.RevokeAPIKey(t.Context(), readKey.ID, "bob", baseTs+2*sec); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	_, err = r.GetAPIKeyByHash(t.Context(), "hash-read")
+	expectNoRows(t, "GetAPIKeyByHash(revoked)", err)
+	expectNoRows(t, "RevokeAPIKey(revoked)", r.RevokeAPIKey(t.Context(), readKey.ID, "bob", baseTs+3*sec))
+	expectNoRows(t, "RevokeAPIKey(unknown)", r.RevokeAPIKey(t.Context(), adminKey.ID+100, "bob", baseTs+3*sec))
+
+	keys, err := r.ListAPIKeys(t.Context())
+	if err != nil {
+		t.Fatalf("ListAPIKeys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != readKey.ID || keys[1].ID != adminKey.ID {
+		t.Fatalf("ListAPIKeys = %+v, want grafana and ops", keys)
+	}
+	if keys[0].RevokedBy != "bob" || keys[0].RevokedAt == nil || *keys[0].RevokedAt != baseTs+2*sec {
+		t.Errorf("revoked key = %+v, want revoked by bob at %d", keys[0], baseTs+2*sec)
+	}
+	if keys[1].RevokedAt != nil {
+		t.Errorf("active key has RevokedAt = %v", *keys[1].RevokedAt)
+	}
+}
+
+func testQuotaUsage(t *testing.T, r Repository) {
+	if err := r.AddQuotaUsage(t.Context(), "2023-11-13", map[string]int64{"key:1": 7}); err != nil {
+		t.Fatalf("AddQuotaUsage(2023-11-13): %v", err)
+	}
+	if err := r.AddQuotaUsage(t.Context(), "2023-11-14", map[string]int64{"key:1": 3, "ip:10.0.0.1": 2}); err != nil {
+		t.Fatalf("AddQuotaUsage: %v", err)
+	}
+	// Второй экземпляр сервиса прибавляет свои запросы к тем же суткам
+	if err := r.AddQuotaUsage(t.Context(), "2023-11-14", map[string]int64{"key:1": 4}); err != nil {
+		t.Fatalf("AddQuotaUsage(again): %v", err)
+	}
+
+	usage, err := r.GetQuotaUsage(t.Context(), "2023-11-14")
+	if err != nil {
+		t.Fatalf("GetQuotaUsage: %v", err)
+	}
+	if len(usage) != 2 || usage["key:1"] != 7 || usage["ip:10.0.0.1"] != 2 {
+		t.Errorf("usage = %v, want key:1=7 ip:10.0.0.1=2", usage)
+	}
+
+	deleted, err := r.DeleteQuotaUsageBefore(t.Context(), "2023-11-14")
+	if err != nil {
+		t.Fatalf("DeleteQuotaUsageBefore: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted = %d, want 1", deleted)
+	}
+	if usage, err := r.GetQuotaUsage(t.Context(), "2023-11-13"); err != nil || len(usage) != 0 {
+		t.Errorf("usage of a deleted day = %v, %v, want empty", usage, err)
+	}
+	if usage, err := r.GetQuotaUsage(t.Context(), "2023-11-14"); err != nil || len(usage) != 2 {
+		t.Errorf("usage of a kept day = %v, %v, want 2 clients", usage, err)
+	}
+}
+
+func createWatchlist(t *testing.T, r Repository, owner, name string) *models.Watchlist {
+	t.Helper()
+	w := &models.Watchlist{Owner: owner, Name: name, CreatedAt: baseTs}
+	if err := r.CreateWatchlist(t.Context(), w); err != nil {
+		t.Fatalf("CreateWatchlist(%s/%s): %v", owner, name, err)
+	}
+	return w
+}
+
+func getWatchlist(t *testing.T, r Repository, id int64) *models.Watchlist {
+	t.Helper()
+	w, err := r.GetWatchlist(t.Context(), id)
+	if err != nil {
+		t.Fatalf("GetWatchlist(%d): %v", id, err)
+	}
+	return w
+}
+
+func testWatchlists(t *testing.T, r Repository) {
+	btc := addCoin(t, r, "BTC")
+	defi := createWatchlist(t, r, "alice", "defi")
+	majors := createWatchlist(t, r, "alice", "majors")
+	bobs := createWatchlist(t, r, "bob", "defi")
+	if defi.ID == 0 || majors.ID == defi.ID {
+		t.Fatalf("watchlist ids = %d, %d, want distinct", defi.ID, majors.ID)
+	}
+	if err := r.CreateWatchlist(t.Context(), &models.Watchlist{Owner: "alice", Name: "defi", CreatedAt: baseTs}); !errors.Is(err, models.ErrDuplicate) {
+		t.Errorf("CreateWatchlist with a duplicate name error = %v, want ErrDuplicate", err)
+	}
+
+	// Монета из справочника и новая монета, которой в нем еще нет
+	if err := r.AddWatchlistCoin(t.Context(), majors.ID, &models.TrackedCoin{Symbol: "BTC"}); err != nil {
+		t.Fatalf("AddWatchlistCoin(BTC): %v", err)
+	}
+	eth := &models.TrackedCoin{Symbol: "ETH", CoinMetadata: models.CoinMetadata{Name: "Ether", Decimals: 18}}
+	if err := r.AddWatchlistCoin(t.Context(), majors.ID, eth); err != nil {
+		t.Fatalf("AddWatchlistCoin(ETH): %v", err)
+	}
+	if err := r.AddWatchlistCoin(t.Context(), majors.ID, &models.TrackedCoin{Symbol: "ETH"}); err != nil {
+		t.Errorf("AddWatchlistCoin(ETH) again: %v", err)
+	}
+	registered := getCoin(t, r, "ETH")
+	if registered.ID != eth.ID || registered.Status != models.CoinStatusUntracked || registered.Name != "Ether" {
+		t.Errorf("registered coin = %+v, want id %d, untracked, named Ether", registered, eth.ID)
+	}
+	expectSymbols(t, "GetAllCoins", allCoins(t, r), "BTC")
+
+	if err := r.ShareWatchlist(t.Context(), majors.ID, "carol"); err != nil {
+		t.Fatalf("ShareWatchlist: %v", err)
+	}
+	if err := r.ShareWatchlist(t.Context(), majors.ID, "carol"); err != nil {
+		t.Errorf("ShareWatchlist again: %v", err)
+	}
+	if err := r.RenameWatchlist(t.Context(), majors.ID, "defi"); !errors.Is(err, models.ErrDuplicate) {
+		t.Errorf("RenameWatchlist to a duplicate name error = %v, want ErrDuplicate", err)
+	}
+	if err := r.RenameWatchlist(t.Context(), majors.ID, "large caps"); err != nil {
+		t.Fatalf("RenameWatchlist: %v", err)
+	}
+
+	got := getWatchlist(t, r, majors.ID)
+	if got.Owner != "alice" || got.Name != "large caps" || got.CreatedAt != baseTs {
+		t.Errorf("watchlist = %+v, want alice/large caps created at %d", got, baseTs)
+	}
+	expectSymbols(t, "watchlist coins", got.Coins, "BTC", "ETH")
+	if len(got.SharedWith) != 1 || got.SharedWith[0] != "carol" {
+		t.Errorf("SharedWith = %v, want [carol]", got.SharedWith)
+	}
+	if empty := getWatchlist(t, r, defi.ID); len(empty.Coins) != 0 || empty.SharedWith == nil {
+		t.Errorf("empty watchlist = %+v, want no coins and empty SharedWith", empty)
+	}
+
+	for user, want := range map[string][]int64{
+		"alice": {defi.ID, majors.ID},
+		"bob":   {bobs.ID},
+		"carol": {majors.ID},
+		"dave":  nil,
+	} {
+		lists, err := r.ListWatchlists(t.Context(), user)
+		if err != nil {
+			t.Fatalf("ListWatchlists(%s): %v", user, err)
+		}
+		ids := make([]int64, len(lists))
+		for i, w := range lists {
+			ids[i] = w.ID
+		}
+		if len(ids) != len(want) || len(ids) > 0 && (ids[0] != want[0] || ids[len(ids)-1] != want[len(want)-1]) {
+			t.Errorf("ListWatchlists(%s) = %v, want %v", user, ids, want)
+		}
+	}
+
+	if err := r.RemoveWatchlistCoin(t.Context(), majors.ID, btc.ID); err != nil {
+		t.Fatalf("RemoveWatchlistCoin: %v", err)
+	}
+	expectNoRows(t, "RemoveWatchlistCoin again", r.RemoveWatchlistCoin(t.Context(), majors.ID, btc.ID))
+	if err := r.UnshareWatchlist(t.Context(), majors.ID, "carol"); err != nil {
+		t.Fatalf("UnshareWatchlist: %v", err)
+	}
+	expectNoRows(t, "UnshareWatchlist again", r.UnshareWatchlist(t.Context(), majors.ID, "carol"))
+	expectSymbols(t, "coins after remove", getWatchlist(t, r, majors.ID).Coins, "ETH")
+
+	if err := r.DeleteWatchlist(t.Context(), majors.ID); err != nil {
+		t.Fatalf("DeleteWatchlist: %v", err)
+	}
+	_, err := r.GetWatchlist(t.Context(), majors.ID)
+	expectNoRows(t, "GetWatchlist after delete", err)
+	expectNoRows(t, "DeleteWatchlist again", r.DeleteWatchlist(t.Context(), majors.ID))
+	expectNoRows(t, "RenameWatchlist of a deleted list", r.RenameWatchlist(t.Context(), majors.ID, "x"))
+}
+
+func allCoins(t *testing.T, r Repository) []*models.TrackedCoin {
+	t.Helper()
+	coins, err := r.GetAllCoins(t.Context())
+	if err != nil {
+		t.Fatalf("GetAllCoins: %v", err)
+	}
+	return coins
+}
+
+// testWatchlistPolling проверяет, что монета опрашивается, пока она в общем списке
+// или хотя бы в одном списке пользователя, и не удаляется безвозвратно из справочника
+func testWatchlistPolling(t *testing.T, r Repository) {
+	addCoin(t, r, "BTC")
+	sol := addCoin(t, r, "SOL")
+	first := createWatchlist(t, r, "alice", "first")
+	second := createWatchlist(t, r, "bob", "second")
+	for _, id := range []int64{first.ID, second.ID} {
+		if err := r.AddWatchlistCoin(t.Context(), id, &models.TrackedCoin{Symbol: "SOL"}); err != nil {
+			t.Fatalf("AddWatchlistCoin(SOL): %v", err)
+		}
+	}
+	if err := r.RemoveCoin(t.Context(), &models.TrackedCoin{Symbol: "SOL"}, alice); err != nil {
+		t.Fatalf("RemoveCoin(SOL): %v", err)
+	}
+
+	active := func() []*models.TrackedCoin {
+		t.Helper()
+		coins, err := r.GetActiveCoins(t.Context())
+		if err != nil {
+			t.Fatalf("GetActiveCoins: %v", err)
+		}
+		return coins
+	}
+	watched := func() bool {
+		t.Helper()
+		ok, err := r.IsCoinWatched(t.Context(), sol.ID)
+		if err != nil {
+			t.Fatalf("IsCoinWatched: %v", err)
+		}
+		return ok
+	}
+
+	expectSymbols(t, "GetActiveCoins watched by two lists", active(), "BTC", "SOL")
+	if err := r.RemoveWatchlistCoin(t.Context(), first.ID, sol.ID); err != nil {
+		t.Fatalf("RemoveWatchlistCoin: %v", err)
+	}
+	expectSymbols(t, "GetActiveCoins watched by one list", active(), "BTC", "SOL")
+	if !watched() {
+		t.Errorf("IsCoinWatched = false, want true")
+	}
+	if _, err := r.PurgeCoin(t.Context(), &models.TrackedCoin{Symbol: "SOL"}, alice); !errors.Is(err, models.ErrReferenced) {
+		t.Errorf("PurgeCoin of a watched coin error = %v, want ErrReferenced", err)
+	}
+
+	// Удаление списка освобождает его монеты
+	if err := r.DeleteWatchlist(t.Context(), second.ID); err != nil {
+		t.Fatalf("DeleteWatchlist: %v", err)
+	}
+	expectSymbols(t, "GetActiveCoins watched by nobody", active(), "BTC")
+	if watched() {
+		t.Errorf("IsCoinWatched = true, want false")
+	}
+
+	// Приостановленная монета не опрашивается, даже если она в списке
+	if err := r.SetCoinStatus(t.Context(), "BTC", models.CoinStatusPaused, alice); err != nil {
+		t.Fatalf("SetCoinStatus: %v", err)
+	}
+	if err := r.AddWatchlistCoin(t.Context(), first.ID, &models.TrackedCoin{Symbol: "BTC"}); err != nil {
+		t.Fatalf("AddWatchlistCoin(BTC): %v", err)
+	}
+	expectSymbols(t, "GetActiveCoins with a paused coin", active())
+}
+
+func createAlertRule(t *testing.T, r Repository, owner string, coin *models.TrackedCoin, condition models.AlertCondition, threshold string) *models.AlertRule {
+	t.Helper()
+	rule := &models.AlertRule{
+		Owner:          owner,
+		CoinID:         coin.ID,
+		Coin:           coin.Symbol,
+		Condition:      condition,
+		Threshold:      dec(threshold),
+		Hysteresis:     dec("1"),
+		Cooldown:       models.Duration(15 * time.Minute),
+		State:          models.AlertArmed,
+		StateChangedAt: baseTs,
+		CreatedAt:      baseTs,
+	}
+	if err := r.CreateAlertRule(t.Context(), rule); err != nil {
+		t.Fatalf("CreateAlertRule(%s %s): %v", owner, condition, err)
+	}
+	return rule
+}
+
+// fireAlert переводит правило в состояние fired и записывает срабатывание в момент ts
+func fireAlert(t *testing.T, r Repository, rule *models.AlertRule, ts models.Timestamp, price string) {
+	t.Helper()
+	rule.State = models.AlertFired
+	rule.StateChangedAt = ts
+	rule.LastFiredAt = &ts
+	event := &models.AlertEvent{RuleID: rule.ID, Price: dec(price), Value: dec(price), FiredAt: ts}
+	updated, err := r.UpdateAlertState(t.Context(), rule, event)
+	if err != nil || !updated {
+		t.Fatalf("UpdateAlertState(%d) = %v, %v, want true", rule.ID, updated, err)
+	}
+	if event.ID == 0 {
+		t.Errorf("alert event id was not filled")
+	}
+}
+
+func alertEvents(t *testing.T, r Repository, filter *models.AlertEventFilter) []*models.AlertEvent {
+	t.Helper()
+	events, err := r.GetAlertEvents(t.Context(), filter)
+	if err != nil {
+		t.Fatalf("GetAlertEvents(%+v): %v", filter, err)
+	}
+	return events
+}
+
+func testAlertRules(t *testing.T, r Repository) {
+	btc := addCoin(t, r, "BTC")
+	eth := addCoin(t, r, "ETH")
+	above := createAlertRule(t, r, "alice", btc, models.AlertPriceAbove, "100.5")
+	cross := &models.AlertRule{
+		Owner:          "alice",
+		CoinID:         eth.ID,
+		Coin:           eth.Symbol,
+		Condition:      models.AlertCrossBelowMA,
+		Window:         models.Duration(24 * time.Hour),
+		Hysteresis:     dec("0.5"),
+		Cooldown:       models.Duration(time.Hour),
+		State:          models.AlertArmed,
+		StateChangedAt: baseTs,
+		CreatedAt:      baseTs,
+	}
+	if err := r.CreateAlertRule(t.Context(), cross); err != nil {
+		t.Fatalf("CreateAlertRule(cross): %v", err)
+	}
+	bobs := createAlertRule(t, r, "bob", btc, models.AlertPriceBelow, "90")
+	if above.ID == 0 || cross.ID == above.ID || above.Version != 1 {
+		t.Fatalf("alert rules = %+v, %+v, want distinct ids and version 1", above, cross)
+	}
+
+	got, err := r.GetAlertRule(t.Context(), cross.ID)
+	if err != nil {
+		t.Fatalf("GetAlertRule(%d): %v", cross.ID, err)
+	}
+	if got.Owner != "alice" || got.Coin != "ETH" || got.CoinID != eth.ID || got.Condition != models.AlertCrossBelowMA ||
+		got.Window != cross.Window || got.Cooldown != cross.Cooldown || got.State != models.AlertArmed ||
+		got.StateChangedAt != baseTs || got.CreatedAt != baseTs || got.LastFiredAt != nil || got.Version != 1 {
+		t.Errorf("GetAlertRule = %+v, want %+v", got, cross)
+	}
+	expectDecimal(t, "hysteresis", got.Hysteresis, "0.5")
+	expectDecimal(t, "threshold", got.Threshold, "0")
+	_, err = r.GetAlertRule(t.Context(), bobs.ID+100)
+	expectNoRows(t, "GetAlertRule(missing)", err)
+
+	rules, err := r.ListAlertRules(t.Context(), "alice")
+	if err != nil || len(rules) != 2 || rules[0].ID != above.ID || rules[1].ID != cross.ID {
+		t.Fatalf("ListAlertRules(alice) = %v, %v, want 2 rules in creation order", rules, err)
+	}
+	expectDecimal(t, "listed threshold", rules[0].Threshold, "100.5")
+	if rules, err = r.ListAlertRules(t.Context(), ""); err != nil || len(rules) != 3 {
+		t.Errorf("ListAlertRules(all) = %d rules, %v, want 3", len(rules), err)
+	}
+
+	// Срабатывание записывается только при совпадении версии
+	fireAlert(t, r, above, baseTs+60*sec, "101")
+	stale, current := *got, *got
+	current.State = models.AlertCoolingDown
+	if updated, err := r.UpdateAlertState(t.Context(), &current, nil); err != nil || !updated || current.Version != 2 {
+		t.Fatalf("UpdateAlertState(cross) = %v, %v, version %d, want true, version 2", updated, err, current.Version)
+	}
+	stale.State = models.AlertFired
+	if updated, err := r.UpdateAlertState(t.Context(), &stale, &models.AlertEvent{RuleID: cross.ID, FiredAt: baseTs}); err != nil || updated {
+		t.Errorf("UpdateAlertState with a stale version = %v, %v, want false", updated, err)
+	}
+	if events := alertEvents(t, r, &models.AlertEventFilter{RuleID: cross.ID}); len(events) != 0 {
+		t.Errorf("events after a stale update = %+v, want none", events)
+	}
+	got, err = r.GetAlertRule(t.Context(), above.ID)
+	if err != nil || got.State != models.AlertFired || got.LastFiredAt == nil || *got.LastFiredAt != baseTs+60*sec || got.Version != 2 {
+		t.Fatalf("rule after fire = %+v, %v, want fired at %d, version 2", got, err, baseTs+60*sec)
+	}
+
+	above.State = models.AlertArmed
+	above.StateChangedAt = baseTs + 120*sec
+	if updated, err := r.UpdateAlertState(t.Context(), above, nil); err != nil || !updated {
+		t.Fatalf("UpdateAlertState(rearm) = %v, %v", updated, err)
+	}
+	fireAlert(t, r, above, baseTs+180*sec, "102.25")
+	fireAlert(t, r, bobs, baseTs+240*sec, "89")
+
+	events := alertEvents(t, r, &models.AlertEventFilter{Owner: "alice"})
+	if len(events) != 2 || events[0].FiredAt != baseTs+180*sec || events[1].FiredAt != baseTs+60*sec {
+		t.Fatalf("alice events = %+v, want 2 newest first", events)
+	}
+	if events[0].RuleID != above.ID || events[0].Coin != "BTC" || events[0].Condition != models.AlertPriceAbove {
+		t.Errorf("event = %+v, want rule %d on BTC", events[0], above.ID)
+	}
+	expectDecimal(t, "event price", events[0].Price, "102.25")
+	if events = alertEvents(t, r, &models.AlertEventFilter{From: baseTs + 120*sec, To: baseTs + 240*sec}); len(events) != 1 || events[0].RuleID != above.ID {
+		t.Errorf("events in [120s, 240s) = %+v, want 1", events)
+	}
+	if events = alertEvents(t, r, &models.AlertEventFilter{RuleID: bobs.ID}); len(events) != 1 || events[0].Coin != "BTC" {
+		t.Errorf("events of bob's rule = %+v, want 1", events)
+	}
+	if events = alertEvents(t, r, &models.AlertEventFilter{Limit: 1}); len(events) != 1 || events[0].RuleID != bobs.ID {
+		t.Errorf("latest event = %+v, want bob's", events)
+	}
+
+	// Удаление правила удаляет историю его срабатываний
+	if err := r.DeleteAlertRule(t.Context(), above.ID); err != nil {
+		t.Fatalf("DeleteAlertRule(%d): %v", above.ID, err)
+	}
+	expectNoRows(t, "DeleteAlertRule twice", r.DeleteAlertRule(t.Context(), above.ID))
+	if events = alertEvents(t, r, &models.AlertEventFilter{Owner: "alice"}); len(events) != 0 {
+		t.Errorf("events after delete = %+v, want none", events)
+	}
+	if updated, err := r.UpdateAlertState(t.Context(), above, nil); err != nil || updated {
+		t.Errorf("UpdateAlertState of a deleted rule = %v, %v, want false", updated, err)
+	}
+
+	// Правила удаляются вместе с монетой
+	if err := r.RemoveCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, alice); err != nil {
+		t.Fatalf("RemoveCoin(BTC): %v", err)
+	}
+	if _, err := r.PurgeCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, alice); err != nil {
+		t.Fatalf("PurgeCoin(BTC): %v", err)
+	}
+	if rules, err = r.ListAlertRules(t.Context(), ""); err != nil || len(rules) != 1 || rules[0].ID != cross.ID {
+		t.Errorf("rules after purge = %v, %v, want only %d", rules, err, cross.ID)
+	}
+	if events = alertEvents(t, r, &models.AlertEventFilter{}); len(events) != 0 {
+		t.Errorf("events after purge = %+v, want none", events)
+	}
+}
@@ -0,0 +1,680 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStorage представляет слой доступа к данным SQLite для развертывания одним файлом
+type SQLiteStorage struct {
+	db  *sql.DB
+	log *zap.Logger
+}
+
+// NewSQLiteStorage открывает базу SQLite по пути path и применяет встроенные миграции
+func NewSQLiteStorage(path string, log *zap.Logger) (*SQLiteStorage, error) {
+	log = log.With(zap.String("type", "SQLiteStorage"))
+
+	db, err := openSQLite(path, log)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(db, goose.DialectSQLite3, "sqlite", log); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStorage{db: db, log: log}, nil
+}
+
+func openSQLite(path string, log *zap.Logger) (*sql.DB, error) {
+	log.Info("Opening SQLite database", zap.String("path", path))
+
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		log.Error("Failed to open database", zap.Error(err))
+		return nil, err
+	}
+	// SQLite допускает одну пишущую транзакцию; одно соединение сериализует
+	// изменения и заменяет блокировки строк SELECT ... FOR UPDATE
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		log.Error("Failed to ping database", zap.Error(err))
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close закрывает соединение с базой данных
+func (s *SQLiteStorage) Close() error {
+	s.log.Info("Closing database connection")
+	return s.db.Close()
+}
+
+// SQLiteRepository реализует тот же контракт хранилища, что и Repository для PostgreSQL
+type SQLiteRepository struct {
+	db  *sql.DB
+	log *zap.Logger
+}
+
+func (s *SQLiteStorage) NewRepository() *SQLiteRepository {
+	return &SQLiteRepository{db: s.db, log: s.log.Named("SQLiteRepository")}
+}
+
+func (r *SQLiteRepository) AddCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := getCoinTx(ctx, tx, coin.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+
+	after, err := scanCoin(tx.QueryRowContext(ctx, `
+        INSERT INTO tracked_coins (symbol, name, provider_id, category, description, logo_url, decimals)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (symbol) DO UPDATE
+        SET name = EXCLUDED.name,
+            provider_id = EXCLUDED.provider_id,
+            category = EXCLUDED.category,
+            description = EXCLUDED.description,
+            logo_url = EXCLUDED.logo_url,
+            decimals = EXCLUDED.decimals,
+            status = 'active',
+            untracked_at = NULL,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING `+coinColumns,
+		coin.Symbol,
+		coin.Name,
+		coin.ProviderID,
+		coin.Category,
+		coin.Description,
+		coin.LogoURL,
+		coin.Decimals,
+	))
+	if err != nil {
+		r.log.Error("Failed to insert coin", zap.Error(err))
+		return fmt.Errorf("failed to insert coin: %w", err)
+	}
+
+	if err := writeAudit(ctx, tx, models.AuditCoinAdd, after.ID, after.Symbol, actor, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RemoveCoin убирает монету из списка наблюдения, сохраняя ее историю цен
+func (r *SQLiteRepository) RemoveCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := getCoinTx(ctx, tx, coin.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil || before.Status == models.CoinStatusUntracked {
+		return nil
+	}
+
+	after, err := scanCoin(tx.QueryRowContext(ctx, `
+        UPDATE tracked_coins
+        SET status = 'untracked',
+            untracked_at = CURRENT_TIMESTAMP,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING `+coinColumns, before.ID))
+	if err != nil {
+		r.log.Error("Failed to remove coin", zap.Error(err))
+		return fmt.Errorf("failed to remove coin: %w", err)
+	}
+
+	if err := writeAudit(ctx, tx, models.AuditCoinRemove, after.ID, after.Symbol, actor, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// PurgeCoin безвозвратно удаляет убранную из списка наблюдения монету вместе с историей цен
+func (r *SQLiteRepository) PurgeCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := getCoinTx(ctx, tx, coin.Symbol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil {
+		return 0, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+	if before.Status != models.CoinStatusUntracked {
+		return 0, fmt.Errorf("coin %s is still tracked, remove it first", coin.Symbol)
+	}
+	// Проверка в транзакции удаления: транзакции SQLite выполняются по одной
+	watched, err := queryCoinWatched(ctx, tx, sqliteWatchlists, before.ID)
+	if err != nil {
+		return 0, err
+	}
+	if watched {
+		return 0, fmt.Errorf("coin %s is in users' watchlists: %w", coin.Symbol, models.ErrReferenced)
+	}
+
+	var deleted int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM coin_prices WHERE coin_id = $1", before.ID).Scan(&deleted); err != nil {
+		return 0, fmt.Errorf("failed to count prices: %w", err)
+	}
+	// Цены удаляются каскадно
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tracked_coins WHERE id = $1", before.ID); err != nil {
+		return 0, fmt.Errorf("failed to purge coin: %w", constraintError(err))
+	}
+
+	after := map[string]int64{"deleted_prices": deleted}
+	if err := writeAudit(ctx, tx, models.AuditCoinPurge, before.ID, before.Symbol, actor, before, after); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.log.Info("Purged coin", zap.String("symbol", coin.Symbol), zap.Int64("prices", deleted))
+	return deleted, nil
+}
+
+// getCoinTx возвращает монету внутри транзакции; nil - монеты нет.
+// Блокировка строки не нужна: транзакции SQLite выполняются по одной.
+func getCoinTx(ctx context.Context, tx *sql.Tx, symbol string) (*models.TrackedCoin, error) {
+	coin, err := scanCoin(tx.QueryRowContext(ctx, `SELECT `+coinColumns+` FROM tracked_coins WHERE symbol = $1`, symbol))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return coin, err
+}
+
+// sqliteGetPriceQuery - ближайшая цена по индексу (coin_id, timestamp), как в getPriceQuery
+const sqliteGetPriceQuery = `
+        SELECT id, coin_id, symbol, price, timestamp
+        FROM (
+            SELECT * FROM (
+                SELECT cp.id, cp.coin_id, tc.symbol, cp.price, cp.timestamp
+                FROM tracked_coins tc
+                JOIN coin_prices cp ON cp.coin_id = tc.id
+                WHERE tc.symbol = $1 AND cp.timestamp <= $2
+                ORDER BY cp.timestamp DESC
+                LIMIT 1
+            )
+            UNION ALL
+            SELECT * FROM (
+                SELECT cp.id, cp.coin_id, tc.symbol, cp.price, cp.timestamp
+                FROM tracked_coins tc
+                JOIN coin_prices cp ON cp.coin_id = tc.id
+                WHERE tc.symbol = $1 AND cp.timestamp > $2
+                ORDER BY cp.timestamp ASC
+                LIMIT 1
+            )
+        )
+        ORDER BY ABS(timestamp - $2), timestamp
+        LIMIT 1`
+
+func (r *SQLiteRepository) GetPrice(ctx context.Context, coin *models.GetPriceRequest) (*models.CryptoPrice, error) {
+	timestamp := coin.Timestamp
+
+	var price models.CryptoPrice
+	err := r.db.QueryRowContext(ctx, sqliteGetPriceQuery, coin.Coin, timestamp).Scan(
+		&price.ID,
+		&price.CoinID,
+		&price.Symbol,
+		&price.Price,
+		&price.Timestamp,
+	)
+	if err != nil {
+		r.log.Error("Failed to get price", zap.Error(err), zap.String("coin", coin.Coin))
+		return nil, fmt.Errorf("failed to get price: %w", err)
+	}
+	return &price, nil
+}
+
+// GetAllCoins возвращает список наблюдения; убранные из него монеты не входят
+func (r *SQLiteRepository) GetAllCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	r.log.Debug("Getting all coins")
+	return queryCoins(ctx, r.db, r.log, `SELECT `+coinColumns+` FROM tracked_coins WHERE status <> $1 ORDER BY symbol`, models.CoinStatusUntracked)
+}
+
+// GetActiveCoins возвращает монеты, цены которых нужно опрашивать
+func (r *SQLiteRepository) GetActiveCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	r.log.Debug("Getting active coins")
+	return queryCoins(ctx, r.db, r.log, activeCoinsQuery(numberedParam), models.CoinStatusActive, models.CoinStatusUntracked)
+}
+
+func (r *SQLiteRepository) GetCoin(ctx context.Context, symbol string) (*models.TrackedCoin, error) {
+	coin, err := scanCoin(r.db.QueryRowContext(ctx, `SELECT `+coinColumns+` FROM tracked_coins WHERE symbol = $1`, symbol))
+	if err != nil {
+		r.log.Error("Failed to get coin", zap.Error(err), zap.String("coin", symbol))
+		return nil, fmt.Errorf("failed to get coin: %w", err)
+	}
+	return coin, nil
+}
+
+// SetCoinStatus меняет состояние опроса монеты
+func (r *SQLiteRepository) SetCoinStatus(ctx context.Context, symbol string, status models.CoinStatus, actor *models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := getCoinTx(ctx, tx, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil || before.Status == models.CoinStatusUntracked {
+		return fmt.Errorf("failed to set coin status: %w", sql.ErrNoRows)
+	}
+	if before.Status == status {
+		return nil
+	}
+
+	after, err := scanCoin(tx.QueryRowContext(ctx, `
+        UPDATE tracked_coins
+        SET status = $2, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING `+coinColumns, before.ID, status))
+	if err != nil {
+		r.log.Error("Failed to set coin status", zap.Error(err), zap.String("coin", symbol))
+		return fmt.Errorf("failed to set coin status: %w", err)
+	}
+
+	if err := writeAudit(ctx, tx, models.AuditCoinStatus, after.ID, after.Symbol, actor, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateCoinMetadata меняет заданные в запросе поля метаданных монеты
+func (r *SQLiteRepository) UpdateCoinMetadata(ctx context.Context, req *models.UpdateMetadataRequest, actor *models.Actor) (*models.TrackedCoin, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	before, err := getCoinTx(ctx, tx, req.Coin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coin: %w", err)
+	}
+	if before == nil {
+		return nil, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+
+	after, err := scanCoin(tx.QueryRowContext(ctx, `
+        UPDATE tracked_coins
+        SET name = COALESCE($2, name),
+            category = COALESCE($3, category),
+            description = COALESCE($4, description),
+            logo_url = COALESCE($5, logo_url),
+            decimals = COALESCE($6, decimals),
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING `+coinColumns,
+		before.ID,
+		req.Name,
+		req.Category,
+		req.Description,
+		req.LogoURL,
+		req.Decimals,
+	))
+	if err != nil {
+		r.log.Error("Failed to update coin metadata", zap.Error(err), zap.String("coin", req.Coin))
+		return nil, fmt.Errorf("failed to update coin metadata: %w", err)
+	}
+
+	if err := writeAudit(ctx, tx, models.AuditCoinMetadata, after.ID, after.Symbol, actor, before, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
+}
+
+// CorrectPrice вручную записывает цену монеты в конкретный момент
+func (r *SQLiteRepository) CorrectPrice(ctx context.Context, price *models.CryptoPrice, actor *models.Actor) (*models.CryptoPrice, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	coin, err := getCoinTx(ctx, tx, price.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coin: %w", err)
+	}
+	if coin == nil {
+		return nil, fmt.Errorf("failed to get coin: %w", sql.ErrNoRows)
+	}
+
+	var before *models.CryptoPrice
+	var existing models.CryptoPrice
+	err = tx.QueryRowContext(ctx, `
+        SELECT id, coin_id, price, timestamp
+        FROM coin_prices
+        WHERE coin_id = $1 AND timestamp = $2`,
+		coin.ID, price.Timestamp,
+	).Scan(&existing.ID, &existing.CoinID, &existing.Price, &existing.Timestamp)
+	switch {
+	case err == nil:
+		existing.Symbol = coin.Symbol
+		before = &existing
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("failed to get price: %w", err)
+	}
+
+	after := models.CryptoPrice{Symbol: coin.Symbol}
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO coin_prices (coin_id, price, timestamp)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (coin_id, timestamp) DO UPDATE
+        SET price = EXCLUDED.price
+        RETURNING id, coin_id, price, timestamp`,
+		coin.ID, price.Price, price.Timestamp,
+	).Scan(&after.ID, &after.CoinID, &after.Price, &after.Timestamp)
+	if err != nil {
+		r.log.Error("Failed to correct price", zap.Error(err), zap.String("coin", price.Symbol))
+		return nil, fmt.Errorf("failed to correct price: %w", err)
+	}
+
+	if err := writeAudit(ctx, tx, models.AuditPriceCorrect, coin.ID, coin.Symbol, actor, before, &after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &after, nil
+}
+
+var sqliteAudit = auditDialect{
+	insert:     postgresAudit.insert,
+	states:     "before, after",
+	timeColumn: "created_at",
+	timeParam:  "%s",
+	param:      numberedParam,
+}
+
+// GetAuditLog возвращает записи журнала аудита от новых к старым
+func (r *SQLiteRepository) GetAuditLog(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	return queryAuditLog(ctx, r.db, r.log, filter, sqliteAudit)
+}
+
+func (r *SQLiteRepository) AddNewPrice(ctx context.Context, coin *models.CryptoPrice) error {
+	r.log.Debug("Adding new price", zap.String("symbol", coin.Symbol))
+
+	errs, err := r.AddNewPrices(ctx, []*models.CryptoPrice{coin})
+	if err != nil {
+		return err
+	}
+	if errs[0] != nil {
+		return errs[0]
+	}
+
+	r.log.Debug("Successfully added new price",
+		zap.String("symbol", coin.Symbol),
+		zap.Stringer("price", coin.Price))
+	return nil
+}
+
+// AddNewPrices записывает цены за цикл опроса в одной транзакции.
+// Возвращает ошибки по строкам в порядке входного среза (nil - строка записана).
+func (r *SQLiteRepository) AddNewPrices(ctx context.Context, prices []*models.CryptoPrice) ([]error, error) {
+	r.log.Debug("Adding new prices", zap.Int("count", len(prices)))
+
+	errs := make([]error, len(prices))
+	if len(prices) == 0 {
+		return errs, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT INTO coin_prices (coin_id, price, timestamp)
+        SELECT $1, $2, $3
+        WHERE EXISTS(SELECT 1 FROM tracked_coins WHERE id = $1)
+        ON CONFLICT (coin_id, timestamp) DO UPDATE
+        SET price = EXCLUDED.price`)
+	if err != nil {
+		r.log.Error("Failed to insert prices", zap.Error(err))
+		return nil, fmt.Errorf("failed to insert prices: %w", err)
+	}
+	defer stmt.Close()
+
+	written := 0
+	for i, price := range prices {
+		res, err := stmt.ExecContext(ctx, price.CoinID, price.Price, price.Timestamp)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to insert price: %w", err)
+			continue
+		}
+		if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+			errs[i] = fmt.Errorf("coin with id %d not found", price.CoinID)
+			continue
+		}
+		written++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.log.Debug("Successfully added new prices",
+		zap.Int("written", written),
+		zap.Int("failed", len(prices)-written))
+	return errs, nil
+}
+
+// GetPriceBuckets агрегирует цены монеты по интервалам. Интервалы группирует SQLite
+// по смещениям часового пояса, вычисленным в приложении; цены хранятся строками, а точной
+// десятичной арифметики в SQLite нет, поэтому максимум, минимум и среднее считаются
+// в приложении по ценам интервала.
+func (r *SQLiteRepository) GetPriceBuckets(ctx context.Context, q *models.BucketQuery) ([]*models.PriceBucket, error) {
+	r.log.Debug("Getting price buckets",
+		zap.String("symbol", q.Symbol),
+		zap.Duration("interval", q.Interval),
+		zap.String("timezone", q.Timezone))
+
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	query, args := bucketQuery(q, loc, numberedParam, "GROUP_CONCAT(price)")
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.log.Error("Failed to get price buckets", zap.Error(err), zap.String("coin", q.Symbol))
+		return nil, fmt.Errorf("failed to get price buckets: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []*models.PriceBucket
+	for rows.Next() {
+		var bucket models.PriceBucket
+		var wall int64
+		var prices string
+		if err := rows.Scan(&wall, &bucket.Open, &bucket.Close, &bucket.Count, &prices); err != nil {
+			r.log.Error("Failed to scan price bucket", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan price bucket: %w", err)
+		}
+		bucket.Start = bucketAt(wall, loc)
+		if err := bucketStats(&bucket, strings.Split(prices, ",")); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, &bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	r.log.Debug("Successfully fetched price buckets", zap.Int("count", len(buckets)))
+
+	return buckets, nil
+}
+
+var sqliteAPIKeys = apiKeyDialect{
+	param:   numberedParam,
+	timeArg: func(ts models.Timestamp) any { return ts },
+}
+
+// CreateAPIKey сохраняет ключ по его хешу и заполняет key.ID
+func (r *SQLiteRepository) CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error {
+	return insertAPIKey(ctx, r.db, r.log, sqliteAPIKeys, key, hash)
+}
+
+// GetAPIKeyByHash ищет действующий ключ по хешу
+func (r *SQLiteRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return queryAPIKeyByHash(ctx, r.db, sqliteAPIKeys, hash)
+}
+
+// ListAPIKeys возвращает все ключи, включая отозванные, в порядке создания
+func (r *SQLiteRepository) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	return queryAPIKeys(ctx, r.db, r.log)
+}
+
+func (r *SQLiteRepository) RevokeAPIKey(ctx context.Context, id int64, revokedBy string, at models.Timestamp) error {
+	return revokeAPIKey(ctx, r.db, r.log, sqliteAPIKeys, id, revokedBy, at)
+}
+
+var sqliteQuotas = quotaDialect{
+	upsert: postgresQuotas.upsert,
+	param:  numberedParam,
+}
+
+// AddQuotaUsage прибавляет запросы клиентов за сутки day (YYYY-MM-DD, UTC)
+func (r *SQLiteRepository) AddQuotaUsage(ctx context.Context, day string, usage map[string]int64) error {
+	return addQuotaUsage(ctx, r.db, r.log, sqliteQuotas, day, usage)
+}
+
+// GetQuotaUsage возвращает запросы клиентов за сутки day
+func (r *SQLiteRepository) GetQuotaUsage(ctx context.Context, day string) (map[string]int64, error) {
+	return queryQuotaUsage(ctx, r.db, r.log, sqliteQuotas, day)
+}
+
+// DeleteQuotaUsageBefore удаляет учет запросов за сутки раньше day
+func (r *SQLiteRepository) DeleteQuotaUsageBefore(ctx context.Context, day string) (int64, error) {
+	return deleteQuotaUsageBefore(ctx, r.db, sqliteQuotas, day)
+}
+
+var sqliteWatchlists = watchlistDialect{
+	param:        numberedParam,
+	timeArg:      func(ts models.Timestamp) any { return ts },
+	returning:    true,
+	registerCoin: postgresWatchlists.registerCoin,
+	addCoin:      postgresWatchlists.addCoin,
+	share:        postgresWatchlists.share,
+}
+
+// CreateWatchlist создает пустой список и заполняет w.ID
+func (r *SQLiteRepository) CreateWatchlist(ctx context.Context, w *models.Watchlist) error {
+	return insertWatchlist(ctx, r.db, r.log, sqliteWatchlists, w)
+}
+
+func (r *SQLiteRepository) GetWatchlist(ctx context.Context, id int64) (*models.Watchlist, error) {
+	return queryWatchlist(ctx, r.db, r.log, sqliteWatchlists, id)
+}
+
+// ListWatchlists возвращает списки пользователя и открытые ему списки
+func (r *SQLiteRepository) ListWatchlists(ctx context.Context, user string) ([]*models.Watchlist, error) {
+	return queryWatchlists(ctx, r.db, r.log, sqliteWatchlists, user)
+}
+
+func (r *SQLiteRepository) RenameWatchlist(ctx context.Context, id int64, name string) error {
+	return renameWatchlist(ctx, r.db, sqliteWatchlists, id, name)
+}
+
+func (r *SQLiteRepository) DeleteWatchlist(ctx context.Context, id int64) error {
+	return deleteWatchlist(ctx, r.db, sqliteWatchlists, id)
+}
+
+// AddWatchlistCoin добавляет монету в список и при необходимости в справочник; заполняет coin.ID
+func (r *SQLiteRepository) AddWatchlistCoin(ctx context.Context, id int64, coin *models.TrackedCoin) error {
+	return addWatchlistCoin(ctx, r.db, r.log, sqliteWatchlists, id, coin)
+}
+
+func (r *SQLiteRepository) RemoveWatchlistCoin(ctx context.Context, id, coinID int64) error {
+	return removeWatchlistCoin(ctx, r.db, sqliteWatchlists, id, coinID)
+}
+
+func (r *SQLiteRepository) ShareWatchlist(ctx context.Context, id int64, user string) error {
+	return shareWatchlist(ctx, r.db, sqliteWatchlists, id, user)
+}
+
+func (r *SQLiteRepository) UnshareWatchlist(ctx context.Context, id int64, user string) error {
+	return unshareWatchlist(ctx, r.db, sqliteWatchlists, id, user)
+}
+
+// IsCoinWatched сообщает, есть ли монета хотя бы в одном списке пользователя
+func (r *SQLiteRepository) IsCoinWatched(ctx context.Context, coinID int64) (bool, error) {
+	return queryCoinWatched(ctx, r.db, sqliteWatchlists, coinID)
+}
+
+var sqliteAlerts = alertDialect{
+	param:     numberedParam,
+	timeArg:   func(ts models.Timestamp) any { return ts },
+	returning: true,
+}
+
+// CreateAlertRule создает правило и заполняет rule.ID
+func (r *SQLiteRepository) CreateAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	return insertAlertRule(ctx, r.db, r.log, sqliteAlerts, rule)
+}
+
+func (r *SQLiteRepository) GetAlertRule(ctx context.Context, id int64) (*models.AlertRule, error) {
+	return queryAlertRule(ctx, r.db, sqliteAlerts, id)
+}
+
+// ListAlertRules возвращает правила владельца owner, а при пустом owner - все правила
+func (r *SQLiteRepository) ListAlertRules(ctx context.Context, owner string) ([]*models.AlertRule, error) {
+	return queryAlertRules(ctx, r.db, r.log, sqliteAlerts, owner)
+}
+
+func (r *SQLiteRepository) DeleteAlertRule(ctx context.Context, id int64) error {
+	return deleteAlertRule(ctx, r.db, sqliteAlerts, id)
+}
+
+// UpdateAlertState условно по версии записывает состояние правила и срабатывание event
+func (r *SQLiteRepository) UpdateAlertState(ctx context.Context, rule *models.AlertRule, event *models.AlertEvent) (bool, error) {
+	return updateAlertState(ctx, r.db, r.log, sqliteAlerts, rule, event)
+}
+
+// GetAlertEvents возвращает историю срабатываний от новых к старым
+func (r *SQLiteRepository) GetAlertEvents(ctx context.Context, filter *models.AlertEventFilter) ([]*models.AlertEvent, error) {
+	return queryAlertEvents(ctx, r.db, r.log, sqliteAlerts, filter)
+}
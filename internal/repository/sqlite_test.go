@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"awesomeProject/internal/repository/repotest"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "coins.db"), zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to open storage: %v", err)
+		}
+		t.Cleanup(func() { storage.Close() })
+		return storage.NewRepository()
+	})
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolStats возвращает состояние пула основной базы и пулов реплик.
+// Репозиторий без пула pgx (поверх готового *sql.DB) сообщает состояние пула database/sql.
+func (r *Repository) PoolStats() *models.PoolStats {
+	var stats *models.PoolStats
+	if r.pool != nil {
+		stats = pgxPoolStats(r.pool)
+	} else {
+		stats = sqlPoolStats(r.db)
+	}
+	if r.replicas != nil {
+		for _, replica := range r.replicas.replicas {
+			stats.Replicas = append(stats.Replicas, pgxPoolStats(replica.pool))
+		}
+	}
+	return stats
+}
+
+// PoolStats возвращает состояние пула соединений database/sql
+func (r *MySQLRepository) PoolStats() *models.PoolStats {
+	return sqlPoolStats(r.db)
+}
+
+// PoolStats возвращает состояние пула соединений database/sql
+func (r *SQLiteRepository) PoolStats() *models.PoolStats {
+	return sqlPoolStats(r.db)
+}
+
+func pgxPoolStats(pool *pgxpool.Pool) *models.PoolStats {
+	stat := pool.Stat()
+	return &models.PoolStats{
+		MaxConns:       stat.MaxConns(),
+		TotalConns:     stat.TotalConns(),
+		IdleConns:      stat.IdleConns(),
+		AcquiredConns:  stat.AcquiredConns(),
+		WaitCount:      stat.EmptyAcquireCount(),
+		WaitDurationMs: stat.EmptyAcquireWaitTime().Milliseconds(),
+		CanceledCount:  stat.CanceledAcquireCount(),
+	}
+}
+
+func sqlPoolStats(db *sql.DB) *models.PoolStats {
+	stat := db.Stats()
+	return &models.PoolStats{
+		MaxConns:       int32(stat.MaxOpenConnections),
+		TotalConns:     int32(stat.OpenConnections),
+		IdleConns:      int32(stat.Idle),
+		AcquiredConns:  int32(stat.InUse),
+		WaitCount:      stat.WaitCount,
+		WaitDurationMs: stat.WaitDuration.Milliseconds(),
+	}
+}
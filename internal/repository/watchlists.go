@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// watchlistDialect - особенности SQL списков наблюдения в диалекте хранилища
+type watchlistDialect struct {
+	param        func(n int) string         // n-й параметр запроса
+	timeArg      func(models.Timestamp) any // значение параметра для created_at
+	returning    bool                       // INSERT возвращает id через RETURNING, иначе LastInsertId
+	registerCoin string                     // добавление монеты в справочник вне общего списка, если ее там нет
+	addCoin      string                     // добавление монеты в список без ошибки для повтора: watchlist_id, coin_id
+	share        string                     // открытие списка пользователю без ошибки для повтора: watchlist_id, username
+}
+
+var postgresWatchlists = watchlistDialect{
+	param:     numberedParam,
+	timeArg:   func(ts models.Timestamp) any { return ts.Time() },
+	returning: true,
+	registerCoin: `
+        INSERT INTO tracked_coins (symbol, name, provider_id, category, description, logo_url, decimals, status, untracked_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, 'untracked', CURRENT_TIMESTAMP)
+        ON CONFLICT (symbol) DO NOTHING`,
+	addCoin: `INSERT INTO watchlist_coins (watchlist_id, coin_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+	share:   `INSERT INTO watchlist_shares (watchlist_id, username) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+}
+
+// watchedCondition - условие на tracked_coins: монета есть хотя бы в одном списке пользователя
+const watchedCondition = `EXISTS (SELECT 1 FROM watchlist_coins wc WHERE wc.coin_id = tracked_coins.id)`
+
+// activeCoinsQuery выбирает монеты для опроса: активные в общем списке и убранные из него,
+// но оставшиеся в списках пользователей. Приостановленные и снятые с торгов не опрашиваются.
+func activeCoinsQuery(param func(n int) string) string {
+	return `SELECT ` + coinColumns + ` FROM tracked_coins
+        WHERE status = ` + param(1) + ` OR (status = ` + param(2) + ` AND ` + watchedCondition + `)
+        ORDER BY symbol`
+}
+
+func insertWatchlist(ctx context.Context, db *sql.DB, log *zap.Logger, dialect watchlistDialect, w *models.Watchlist) error {
+	p := dialect.param
+	query := `INSERT INTO watchlists (owner, name, created_at) VALUES (` + p(1) + `, ` + p(2) + `, ` + p(3) + `)`
+	args := []any{w.Owner, w.Name, dialect.timeArg(w.CreatedAt)}
+
+	var err error
+	if dialect.returning {
+		err = db.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&w.ID)
+	} else {
+		var res sql.Result
+		if res, err = db.ExecContext(ctx, query, args...); err == nil {
+			w.ID, err = res.LastInsertId()
+		}
+	}
+	if err != nil {
+		log.Error("Failed to create watchlist", zap.Error(err), zap.String("owner", w.Owner), zap.String("name", w.Name))
+		return fmt.Errorf("failed to create watchlist: %w", constraintError(err))
+	}
+	return nil
+}
+
+// queryWatchlist возвращает список с монетами и пользователями, которым он открыт
+func queryWatchlist(ctx context.Context, db *sql.DB, log *zap.Logger, dialect watchlistDialect, id int64) (*models.Watchlist, error) {
+	var w models.Watchlist
+	err := db.QueryRowContext(ctx, `SELECT id, owner, name, created_at FROM watchlists WHERE id = `+dialect.param(1), id).
+		Scan(&w.ID, &w.Owner, &w.Name, &w.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watchlist: %w", err)
+	}
+	if err := loadWatchlistDetails(ctx, db, log, dialect, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// queryWatchlists возвращает списки пользователя и открытые ему списки в порядке создания
+func queryWatchlists(ctx context.Context, db *sql.DB, log *zap.Logger, dialect watchlistDialect, user string) ([]*models.Watchlist, error) {
+	p := dialect.param
+	rows, err := db.QueryContext(ctx, `
+        SELECT id, owner, name, created_at FROM watchlists
+        WHERE owner = `+p(1)+` OR id IN (SELECT watchlist_id FROM watchlist_shares WHERE username = `+p(2)+`)
+        ORDER BY id`, user, user)
+	if err != nil {
+		log.Error("Failed to list watchlists", zap.Error(err), zap.String("user", user))
+		return nil, fmt.Errorf("failed to list watchlists: %w", err)
+	}
+	defer rows.Close()
+
+	var watchlists []*models.Watchlist
+	for rows.Next() {
+		var w models.Watchlist
+		if err := rows.Scan(&w.ID, &w.Owner, &w.Name, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan watchlist: %w", err)
+		}
+		watchlists = append(watchlists, &w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	rows.Close()
+
+	for _, w := range watchlists {
+		if err := loadWatchlistDetails(ctx, db, log, dialect, w); err != nil {
+			return nil, err
+		}
+	}
+	return watchlists, nil
+}
+
+func loadWatchlistDetails(ctx context.Context, db *sql.DB, log *zap.Logger, dialect watchlistDialect, w *models.Watchlist) error {
+	coins, err := queryCoins(ctx, db, log, `
+        SELECT `+coinColumns+` FROM tracked_coins
+        WHERE id IN (SELECT coin_id FROM watchlist_coins WHERE watchlist_id = `+dialect.param(1)+`)
+        ORDER BY symbol`, w.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get watchlist coins: %w", err)
+	}
+	w.Coins = coins
+
+	rows, err := db.QueryContext(ctx, `SELECT username FROM watchlist_shares WHERE watchlist_id = `+dialect.param(1)+` ORDER BY username`, w.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get watchlist shares: %w", err)
+	}
+	defer rows.Close()
+	w.SharedWith = []string{}
+	for rows.Next() {
+		var user string
+		if err := rows.Scan(&user); err != nil {
+			return fmt.Errorf("failed to scan watchlist share: %w", err)
+		}
+		w.SharedWith = append(w.SharedWith, user)
+	}
+	return rows.Err()
+}
+
+// execAffecting выполняет изменение и возвращает sql.ErrNoRows, если оно не затронуло ни одной строки
+func execAffecting(ctx context.Context, db *sql.DB, what, query string, args ...any) error {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", what, constraintError(err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", what, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("failed to %s: %w", what, sql.ErrNoRows)
+	}
+	return nil
+}
+
+func renameWatchlist(ctx context.Context, db *sql.DB, dialect watchlistDialect, id int64, name string) error {
+	p := dialect.param
+	return execAffecting(ctx, db, "rename watchlist", `UPDATE watchlists SET name = `+p(1)+` WHERE id = `+p(2), name, id)
+}
+
+// deleteWatchlist удаляет список; его монеты и доступы удаляются каскадно
+func deleteWatchlist(ctx context.Context, db *sql.DB, dialect watchlistDialect, id int64) error {
+	return execAffecting(ctx, db, "delete watchlist", `DELETE FROM watchlists WHERE id = `+dialect.param(1), id)
+}
+
+// addWatchlistCoin добавляет монету в список, а новую монету - в справочник вне общего списка
+func addWatchlistCoin(ctx context.Context, db *sql.DB, log *zap.Logger, dialect watchlistDialect, id int64, coin *models.TrackedCoin) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Safe to call if tx is already committed
+
+	if _, err := tx.ExecContext(ctx, dialect.registerCoin,
+		coin.Symbol,
+		coin.Name,
+		coin.ProviderID,
+		coin.Category,
+		coin.Description,
+		coin.LogoURL,
+		coin.Decimals,
+	); err != nil {
+		log.Error("Failed to register coin", zap.Error(err), zap.String("coin", coin.Symbol))
+		return fmt.Errorf("failed to register coin: %w", err)
+	}
+	var coinID int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM tracked_coins WHERE symbol = `+dialect.param(1), coin.Symbol).Scan(&coinID); err != nil {
+		return fmt.Errorf("failed to get coin: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, dialect.addCoin, id, coinID); err != nil {
+		log.Error("Failed to add coin to watchlist", zap.Error(err), zap.Int64("watchlist_id", id), zap.String("coin", coin.Symbol))
+		// Монету могли удалить безвозвратно после регистрации в справочнике
+		return fmt.Errorf("failed to add coin to watchlist: %w", constraintError(err))
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	coin.ID = coinID
+	return nil
+}
+
+func removeWatchlistCoin(ctx context.Context, db *sql.DB, dialect watchlistDialect, id, coinID int64) error {
+	p := dialect.param
+	return execAffecting(ctx, db, "remove coin from watchlist",
+		`DELETE FROM watchlist_coins WHERE watchlist_id = `+p(1)+` AND coin_id = `+p(2), id, coinID)
+}
+
+func shareWatchlist(ctx context.Context, db *sql.DB, dialect watchlistDialect, id int64, user string) error {
+	if _, err := db.ExecContext(ctx, dialect.share, id, user); err != nil {
+		return fmt.Errorf("failed to share watchlist: %w", err)
+	}
+	return nil
+}
+
+func unshareWatchlist(ctx context.Context, db *sql.DB, dialect watchlistDialect, id int64, user string) error {
+	p := dialect.param
+	return execAffecting(ctx, db, "unshare watchlist",
+		`DELETE FROM watchlist_shares WHERE watchlist_id = `+p(1)+` AND username = `+p(2), id, user)
+}
+
+// rowQuerier - *sql.DB или *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// queryCoinWatched сообщает, есть ли монета хотя бы в одном списке; внутри транзакции
+// безвозвратного удаления проверка идет после блокировки строки монеты
+func queryCoinWatched(ctx context.Context, db rowQuerier, dialect watchlistDialect, coinID int64) (bool, error) {
+	var watched bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM watchlist_coins WHERE coin_id = `+dialect.param(1)+`)`, coinID).Scan(&watched)
+	if err != nil {
+		return false, fmt.Errorf("failed to check watchlists: %w", err)
+	}
+	return watched, nil
+}
+
+// CreateWatchlist создает пустой список и заполняет w.ID
+func (r *Repository) CreateWatchlist(ctx context.Context, w *models.Watchlist) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return insertWatchlist(ctx, r.db, r.log, postgresWatchlists, w)
+}
+
+// GetWatchlist возвращает список с монетами и доступами. Читает из основной базы:
+// по списку проверяются права на его изменение и строится ответ на изменение.
+func (r *Repository) GetWatchlist(ctx context.Context, id int64) (*models.Watchlist, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return queryWatchlist(ctx, r.db, r.log, postgresWatchlists, id)
+}
+
+// ListWatchlists возвращает списки пользователя и открытые ему списки
+func (r *Repository) ListWatchlists(ctx context.Context, user string) ([]*models.Watchlist, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var watchlists []*models.Watchlist
+	err := r.read(ctx, func(db *sql.DB) (err error) {
+		watchlists, err = queryWatchlists(ctx, db, r.log, postgresWatchlists, user)
+		return err
+	})
+	return watchlists, err
+}
+
+func (r *Repository) RenameWatchlist(ctx context.Context, id int64, name string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return renameWatchlist(ctx, r.db, postgresWatchlists, id, name)
+}
+
+func (r *Repository) DeleteWatchlist(ctx context.Context, id int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return deleteWatchlist(ctx, r.db, postgresWatchlists, id)
+}
+
+// AddWatchlistCoin добавляет монету в список; монета, которой нет в справочнике,
+// добавляется в него вне общего списка. Заполняет coin.ID.
+func (r *Repository) AddWatchlistCoin(ctx context.Context, id int64, coin *models.TrackedCoin) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return addWatchlistCoin(ctx, r.db, r.log, postgresWatchlists, id, coin)
+}
+
+func (r *Repository) RemoveWatchlistCoin(ctx context.Context, id, coinID int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return removeWatchlistCoin(ctx, r.db, postgresWatchlists, id, coinID)
+}
+
+func (r *Repository) ShareWatchlist(ctx context.Context, id int64, user string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return shareWatchlist(ctx, r.db, postgresWatchlists, id, user)
+}
+
+func (r *Repository) UnshareWatchlist(ctx context.Context, id int64, user string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return unshareWatchlist(ctx, r.db, postgresWatchlists, id, user)
+}
+
+// IsCoinWatched сообщает, есть ли монета хотя бы в одном списке пользователя
+func (r *Repository) IsCoinWatched(ctx context.Context, coinID int64) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return queryCoinWatched(ctx, r.db, postgresWatchlists, coinID)
+}
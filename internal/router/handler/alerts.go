@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"awesomeProject/internal/models"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// Обработчики правил оповещения /v1/alerts. Правила принадлежат инициатору запроса,
+// то есть имени API-ключа; чужие правила и их срабатывания не видны.
+
+// alertRuleID разбирает идентификатор правила из пути; при ошибке отвечает 400
+func alertRuleID(w http.ResponseWriter, r *http.Request, log *zap.Logger) (int64, bool) {
+	value := r.PathValue("id")
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		log.Warn("Invalid alert rule id", zap.String("value", value))
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid alert rule id")
+		return 0, false
+	}
+	return id, true
+}
+
+// ListAlertRules - GET /v1/alerts: правила пользователя с текущим состоянием
+func (h *Handler) ListAlertRules(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	log.Info("Handling list alert rules")
+
+	rules, err := h.coinService.ListAlertRules(r.Context(), actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to list alert rules", zap.Error(err))
+		writeError(w, r, err, "Failed to list alert rules")
+		return
+	}
+	writeJSON(w, r, log, http.StatusOK, rules)
+}
+
+// CreateAlertRule - POST /v1/alerts: создает взведенное правило
+func (h *Handler) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	log.Info("Handling create alert rule")
+
+	var req models.CreateAlertRuleRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		log.Warn("Invalid request body", zap.Error(err))
+		writeError(w, r, err, "Invalid request body")
+		return
+	}
+	rule, err := h.coinService.CreateAlertRule(r.Context(), &req, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to create alert rule", zap.Error(err))
+		writeError(w, r, err, "Failed to create alert rule")
+		return
+	}
+	log.Info("Created alert rule",
+		zap.Int64("rule_id", rule.ID),
+		zap.String("coin", rule.Coin),
+		zap.String("condition", string(rule.Condition)))
+	writeJSON(w, r, log, http.StatusCreated, rule)
+}
+
+// GetAlertRule - GET /v1/alerts/{id}: правило с текущим состоянием
+func (h *Handler) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	id, ok := alertRuleID(w, r, log)
+	if !ok {
+		return
+	}
+	log.Info("Handling get alert rule", zap.Int64("rule_id", id))
+
+	rule, err := h.coinService.GetAlertRule(r.Context(), id, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to get alert rule", zap.Error(err))
+		writeError(w, r, err, "Failed to get alert rule")
+		return
+	}
+	writeJSON(w, r, log, http.StatusOK, rule)
+}
+
+// DeleteAlertRule - DELETE /v1/alerts/{id}: удаляет правило и историю его срабатываний
+func (h *Handler) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	id, ok := alertRuleID(w, r, log)
+	if !ok {
+		return
+	}
+	log.Info("Handling delete alert rule", zap.Int64("rule_id", id))
+
+	if err := h.coinService.DeleteAlertRule(r.Context(), id, actorFromRequest(r)); err != nil {
+		log.Warn("Failed to delete alert rule", zap.Error(err))
+		writeError(w, r, err, "Failed to delete alert rule")
+		return
+	}
+	log.Info("Deleted alert rule", zap.Int64("rule_id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAlertHistory - GET /v1/alerts/history: срабатывания правил пользователя от новых к старым
+func (h *Handler) GetAlertHistory(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	log.Info("Handling get alert history")
+
+	query := r.URL.Query()
+	var filter models.AlertEventFilter
+	if value := query.Get("rule"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			log.Warn("Invalid query parameter", zap.String("param", "rule"), zap.String("value", value))
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid rule")
+			return
+		}
+		filter.RuleID = id
+	}
+	for name, dst := range map[string]*models.Timestamp{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := models.ParseTimestamp(value)
+			if err != nil || parsed < 0 {
+				log.Warn("Invalid query parameter", zap.String("param", name), zap.String("value", value))
+				WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid "+name)
+				return
+			}
+			*dst = parsed
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			log.Warn("Invalid query parameter", zap.String("param", "limit"), zap.String("value", value))
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := h.coinService.GetAlertEvents(r.Context(), &filter, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to get alert history", zap.Error(err))
+		writeError(w, r, err, "Failed to get alert history")
+		return
+	}
+	log.Info("Get alert history", zap.Int("count", len(events)))
+	writeJSON(w, r, log, http.StatusOK, events)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"awesomeProject/internal/openapi"
+	"awesomeProject/internal/service"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// maxBodySize ограничивает тело запроса; самые крупные тела - изменение метаданных - меньше 4 КиБ
+const maxBodySize = 64 << 10
+
+// errInvalidBody - тело запроса не разбирается как JSON нужной структуры
+var errInvalidBody = errors.New("invalid request body")
+
+// decodeBody строго разбирает JSON-тело запроса в dst: тело проверяется по схеме
+// маршрута в документе OpenAPI, неизвестные поля и данные после JSON отклоняются,
+// затем проверяются теги validate структуры dst.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any) error {
+	defer r.Body.Close()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+
+	// Шаблон маршрута без метода совпадает с путем в документе OpenAPI
+	_, path, found := strings.Cut(r.Pattern, " ")
+	if !found {
+		path = r.Pattern
+	}
+	var validationErr *openapi.ValidationError
+	if err := openapi.ValidateRequest(r.Method, path, body); errors.As(err, &validationErr) {
+		return fmt.Errorf("%w: %w", service.ErrInvalidInput, err)
+	} else if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidBody, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidBody, err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("%w: unexpected data after JSON object", errInvalidBody)
+	}
+	if err := validateStruct(dst); err != nil {
+		return fmt.Errorf("%w: %w", service.ErrInvalidInput, err)
+	}
+	return nil
+}
+
+// validateStruct проверяет теги validate полей структуры: required - поле не пустое,
+// alpha - строка только из латинских букв
+func validateStruct(v any) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		fieldValue := value.Field(i)
+		for _, rule := range strings.Split(tag, ",") {
+			switch rule {
+			case "required":
+				if fieldValue.IsZero() {
+					return fmt.Errorf("%s is required", name)
+				}
+			case "alpha":
+				if fieldValue.Kind() == reflect.String && strings.IndexFunc(fieldValue.String(), notASCIILetter) >= 0 {
+					return fmt.Errorf("%s must contain only letters", name)
+				}
+			default:
+				return fmt.Errorf("unknown validation rule %q of %s", rule, name)
+			}
+		}
+	}
+	return nil
+}
+
+func notASCIILetter(r rune) bool {
+	return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z')
+}
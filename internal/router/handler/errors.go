@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"awesomeProject/internal/models"
+	"awesomeProject/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Коды ошибок в поле code ответа application/problem+json
+const (
+	CodeInvalidRequest   = "invalid_request"      // 400: тело или параметры запроса не разбираются
+	CodeUnauthorized     = "unauthorized"         // 401
+	CodeForbidden        = "forbidden"            // 403
+	CodeNotFound         = "not_found"            // 404
+	CodeMethodNotAllowed = "method_not_allowed"   // 405
+	CodeConflict         = "conflict"             // 409: запрос противоречит состоянию монеты
+	CodeTooLarge         = "payload_too_large"    // 413: тело запроса больше maxBodySize
+	CodeInvalidInput     = "invalid_input"        // 422: запрос разобран, но не прошел проверку
+	CodeRateLimited      = "rate_limited"         // 429: клиент превысил частоту запросов
+	CodeQuotaExceeded    = "quota_exceeded"       // 429: клиент исчерпал суточную квоту
+	CodeInternal         = "internal_error"       // 500
+	CodeUnavailable      = "upstream_unavailable" // 503: провайдер цен или хранилище не отвечает
+)
+
+// WriteProblem отвечает ошибкой в формате RFC 7807
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	requestID, _ := r.Context().Value("request_id").(string)
+	problem := models.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: requestID,
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// writeError отвечает на ошибку разбора тела или сервиса. Статус выбирается по ошибке,
+// которую оборачивает err; прочие ошибки - 500 с сообщением message без подробностей
+// хранилища, подробности остаются в журнале.
+func writeError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		WriteProblem(w, r, http.StatusRequestEntityTooLarge, CodeTooLarge, fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit))
+	case errors.Is(err, errInvalidBody):
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+	case errors.Is(err, service.ErrInvalidInput):
+		WriteProblem(w, r, http.StatusUnprocessableEntity, CodeInvalidInput, err.Error())
+	case errors.Is(err, service.ErrForbidden):
+		WriteProblem(w, r, http.StatusForbidden, CodeForbidden, err.Error())
+	case errors.Is(err, service.ErrNotFound):
+		WriteProblem(w, r, http.StatusNotFound, CodeNotFound, err.Error())
+	case errors.Is(err, service.ErrConflict):
+		WriteProblem(w, r, http.StatusConflict, CodeConflict, err.Error())
+	case errors.Is(err, service.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		WriteProblem(w, r, http.StatusServiceUnavailable, CodeUnavailable, message)
+	default:
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, message)
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"awesomeProject/internal/models"
+	"awesomeProject/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"not found", fmt.Errorf("%w: coin BTC", service.ErrNotFound), http.StatusNotFound, CodeNotFound, "not found: coin BTC"},
+		{"conflict", fmt.Errorf("%w: coin BTC is tracked", service.ErrConflict), http.StatusConflict, CodeConflict, "conflict: coin BTC is tracked"},
+		{"invalid input", fmt.Errorf("%w: invalid interval", service.ErrInvalidInput), http.StatusUnprocessableEntity, CodeInvalidInput, "invalid input: invalid interval"},
+		{"forbidden", fmt.Errorf("%w: not the owner", service.ErrForbidden), http.StatusForbidden, CodeForbidden, "forbidden: not the owner"},
+		{"invalid body", fmt.Errorf("%w: unexpected EOF", errInvalidBody), http.StatusBadRequest, CodeInvalidRequest, "invalid request body: unexpected EOF"},
+		{"too large", &http.MaxBytesError{Limit: maxBodySize}, http.StatusRequestEntityTooLarge, CodeTooLarge, fmt.Sprintf("Request body is larger than %d bytes", maxBodySize)},
+		// Подробности ошибок хранилища в ответ не попадают
+		{"storage timeout", fmt.Errorf("%w: %w", service.ErrUnavailable, context.DeadlineExceeded), http.StatusServiceUnavailable, CodeUnavailable, "Failed to get coin"},
+		{"raw timeout", fmt.Errorf("failed to get price: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, CodeUnavailable, "Failed to get coin"},
+		{"internal", errors.New("relation \"coin_prices\" does not exist"), http.StatusInternalServerError, CodeInternal, "Failed to get coin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/coins/BTC/price", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "request_id", "req-1"))
+			rec := httptest.NewRecorder()
+			writeError(rec, req, tt.err, "Failed to get coin")
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", ct)
+			}
+			var got models.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode %s: %v", rec.Body, err)
+			}
+			want := models.Problem{
+				Type:      "about:blank",
+				Title:     http.StatusText(tt.status),
+				Status:    tt.status,
+				Detail:    tt.detail,
+				Instance:  "/v1/coins/BTC/price",
+				Code:      tt.code,
+				RequestID: "req-1",
+			}
+			if got != want {
+				t.Errorf("problem = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -6,42 +6,44 @@ import (
 	"encoding/json"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
 )
 
 type Handler struct {
 	coinService *service.CoinService
+	authService *service.AuthService
 }
 
-func NewHandler(coinService *service.CoinService) *Handler {
-	return &Handler{coinService: coinService}
+func NewHandler(coinService *service.CoinService, authService *service.AuthService) *Handler {
+	return &Handler{coinService: coinService, authService: authService}
+}
+
+// actorFromRequest возвращает инициатора запроса для журнала аудита
+func actorFromRequest(r *http.Request) *models.Actor {
+	name, _ := r.Context().Value("actor").(string)
+	requestID, _ := r.Context().Value("request_id").(string)
+	return &models.Actor{Name: name, RequestID: requestID}
 }
 
 func (h *Handler) AddCoin(w http.ResponseWriter, r *http.Request) {
 	log := r.Context().Value("logger").(*zap.Logger)
 	if r.Method != http.MethodPost {
 		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
 		return
 	}
 	log.Info("Handling add coin")
 	// Извлечение данных из запроса
 	var addReq models.AddCoinRequest
-	if err := json.NewDecoder(r.Body).Decode(&addReq); err != nil {
+	if err := decodeBody(w, r, &addReq); err != nil {
 		log.Warn("Invalid request body", zap.Error(err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, err, "Invalid request body")
 		return
 	}
-	defer r.Body.Close()
 
-	if addReq.Coin == "" {
-		log.Warn("Coin is required")
-		http.Error(w, "Coin is required", http.StatusBadRequest)
-		return
-	}
-
-	if err := h.coinService.AddCoin(&addReq); err != nil {
+	if err := h.coinService.AddCoin(r.Context(), &addReq, actorFromRequest(r)); err != nil {
 		log.Warn("Failed to add coin", zap.Error(err))
-		http.Error(w, "Failed to add coin", http.StatusInternalServerError)
+		writeError(w, r, err, "Failed to add coin")
 		return
 	}
 
@@ -53,52 +55,37 @@ func (h *Handler) GetCoin(w http.ResponseWriter, r *http.Request) {
 	log := r.Context().Value("logger").(*zap.Logger)
 	if r.Method != http.MethodPost {
 		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
 		return
 	}
 	log.Info("Handling get coin", zap.String("path", r.URL.Path), zap.String("method", r.Method))
 
 	// Извлечение данных из запроса
 	var getReq models.GetPriceRequest
-	if err := json.NewDecoder(r.Body).Decode(&getReq); err != nil {
+	if err := decodeBody(w, r, &getReq); err != nil {
 		log.Warn("Invalid request body", zap.Error(err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, err, "Invalid request body")
 		return
 	}
-	if getReq.Coin == "" {
-		log.Warn("Coin is required")
-		http.Error(w, "Coin is required", http.StatusBadRequest)
-		return
-	}
-	checkTimestamp, err := getReq.Timestamp.Int64()
-	if err != nil {
-		log.Warn("Invalid timestamp", zap.Error(err))
-		http.Error(w, "Invalid timestamp", http.StatusBadRequest)
-		return
-	}
-	if checkTimestamp <= 0 {
+	if getReq.Timestamp <= 0 {
 		log.Warn("Timestamp must be positive")
-		http.Error(w, "Timestamp must be positive", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusUnprocessableEntity, CodeInvalidInput, "Timestamp must be positive")
 		return
 	}
 
-	price, err := h.coinService.GetPrice(&getReq)
+	price, err := h.coinService.GetPrice(r.Context(), &getReq)
 	if err != nil {
 		log.Warn("Failed to get coin", zap.Error(err))
-		http.Error(w, "Failed to get coin", http.StatusInternalServerError)
+		writeError(w, r, err, "Failed to get coin")
 		return
 	}
-	log.Info("Get coin", zap.String("coin", getReq.Coin), zap.Float64("price", price.Price))
+	log.Info("Get coin", zap.String("coin", getReq.Coin), zap.Stringer("price", price.Price))
 
-	response := models.GetPriceResponse{
-		Coin:      price.Symbol,
-		Price:     price.Price,
-		Timestamp: price.Timestamp,
-	}
+	response := models.NewLegacyPriceResponse(price)
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Warn("Failed to encode response", zap.Error(err))
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		writeError(w, r, err, "Failed to encode response")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -108,32 +95,343 @@ func (h *Handler) DeleteCoin(w http.ResponseWriter, r *http.Request) {
 	log := r.Context().Value("logger").(*zap.Logger)
 	if r.Method != http.MethodPost {
 		log.Warn("Invalid request method", zap.String("path", r.URL.Path))
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
 		return
 	}
 	log.Info("Handling delete coin", zap.String("path", r.URL.Path))
 
 	// Извлечение данных из запроса
 	var deleteReq models.AddCoinRequest
-	if err := json.NewDecoder(r.Body).Decode(&deleteReq); err != nil {
+	if err := decodeBody(w, r, &deleteReq); err != nil {
 		log.Warn("Invalid request body", zap.Error(err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, err, "Invalid request body")
+		return
+	}
+
+	if err := h.coinService.RemoveCoin(r.Context(), &deleteReq, actorFromRequest(r)); err != nil {
+		log.Warn("Failed to remove coin", zap.Error(err))
+		writeError(w, r, err, "Failed to remove coin")
+		return
+	}
+	log.Info("Removed coin", zap.String("coin", deleteReq.Coin))
+	w.WriteHeader(http.StatusOK)
+
+}
+
+func (h *Handler) GetBuckets(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
+		return
+	}
+	log.Info("Handling get buckets", zap.String("path", r.URL.Path))
+
+	// Извлечение данных из запроса
+	var bucketsReq models.GetBucketsRequest
+	if err := decodeBody(w, r, &bucketsReq); err != nil {
+		log.Warn("Invalid request body", zap.Error(err))
+		writeError(w, r, err, "Invalid request body")
+		return
+	}
+
+	buckets, err := h.coinService.GetPriceBuckets(r.Context(), &bucketsReq)
+	if err != nil {
+		log.Warn("Failed to get buckets", zap.Error(err))
+		writeError(w, r, err, "Failed to get buckets")
+		return
+	}
+	log.Info("Get buckets", zap.String("coin", bucketsReq.Coin), zap.Int("count", len(buckets)))
+
+	response := models.LegacyBucketsResponse{
+		Coin:     bucketsReq.Coin,
+		Interval: bucketsReq.Interval,
+		Timezone: bucketsReq.Timezone,
+		Buckets:  models.NewLegacyBuckets(buckets),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+func (h *Handler) ListCoins(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	if r.Method != http.MethodGet {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
 		return
 	}
-	defer r.Body.Close()
+	log.Info("Handling list coins", zap.String("path", r.URL.Path))
 
-	if deleteReq.Coin == "" {
+	coins, err := h.coinService.GetAllCoins(r.Context())
+	if err != nil {
+		log.Warn("Failed to list coins", zap.Error(err))
+		writeError(w, r, err, "Failed to list coins")
+		return
+	}
+	if coins == nil {
+		coins = []*models.TrackedCoin{}
+	}
+	log.Info("Listed coins", zap.Int("count", len(coins)))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(coins); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+func (h *Handler) GetCoinInfo(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	if r.Method != http.MethodGet {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
+		return
+	}
+	log.Info("Handling get coin info", zap.String("path", r.URL.Path))
+
+	symbol := r.URL.Query().Get("coin")
+	if symbol == "" {
 		log.Warn("Coin is required")
-		http.Error(w, "Coin is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusUnprocessableEntity, CodeInvalidInput, "Coin is required")
 		return
 	}
 
-	if err := h.coinService.RemoveCoin(&deleteReq); err != nil {
-		log.Warn("Failed to remove coin", zap.Error(err))
-		http.Error(w, "Failed to remove coin", http.StatusInternalServerError)
+	coin, err := h.coinService.GetCoin(r.Context(), symbol)
+	if err != nil {
+		log.Warn("Failed to get coin info", zap.Error(err))
+		writeError(w, r, err, "Failed to get coin info")
 		return
 	}
-	log.Info("Removed coin", zap.String("coin", deleteReq.Coin))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(coin); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+func (h *Handler) SetCoinStatus(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
+		return
+	}
+	log.Info("Handling set coin status", zap.String("path", r.URL.Path))
+
+	// Извлечение данных из запроса
+	var statusReq models.SetStatusRequest
+	if err := decodeBody(w, r, &statusReq); err != nil {
+		log.Warn("Invalid request body", zap.Error(err))
+		writeError(w, r, err, "Invalid request body")
+		return
+	}
+
+	if !statusReq.Status.Valid() {
+		log.Warn("Invalid status", zap.String("status", string(statusReq.Status)))
+		WriteProblem(w, r, http.StatusUnprocessableEntity, CodeInvalidInput, "Invalid status")
+		return
+	}
+
+	if err := h.coinService.SetCoinStatus(r.Context(), &statusReq, actorFromRequest(r)); err != nil {
+		log.Warn("Failed to set coin status", zap.Error(err))
+		writeError(w, r, err, "Failed to set coin status")
+		return
+	}
+	log.Info("Set coin status", zap.String("coin", statusReq.Coin), zap.String("status", string(statusReq.Status)))
 	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) PurgeCoin(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
+		return
+	}
+	log.Info("Handling purge coin", zap.String("path", r.URL.Path))
 
+	// Извлечение данных из запроса
+	var purgeReq models.AddCoinRequest
+	if err := decodeBody(w, r, &purgeReq); err != nil {
+		log.Warn("Invalid request body", zap.Error(err))
+		writeError(w, r, err, "Invalid request body")
+		return
+	}
+
+	deleted, err := h.coinService.PurgeCoin(r.Context(), &purgeReq, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to purge coin", zap.Error(err))
+		writeError(w, r, err, "Failed to purge coin")
+		return
+	}
+	log.Info("Purged coin", zap.String("coin", purgeReq.Coin), zap.Int64("deleted_prices", deleted))
+
+	response := models.PurgeCoinResponse{
+		Coin:          purgeReq.Coin,
+		DeletedPrices: deleted,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+func (h *Handler) UpdateCoinMetadata(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
+		return
+	}
+	log.Info("Handling update coin metadata", zap.String("path", r.URL.Path))
+
+	// Извлечение данных из запроса
+	var metadataReq models.UpdateMetadataRequest
+	if err := decodeBody(w, r, &metadataReq); err != nil {
+		log.Warn("Invalid request body", zap.Error(err))
+		writeError(w, r, err, "Invalid request body")
+		return
+	}
+
+	coin, err := h.coinService.UpdateCoinMetadata(r.Context(), &metadataReq, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to update coin metadata", zap.Error(err))
+		writeError(w, r, err, "Failed to update coin metadata")
+		return
+	}
+	log.Info("Updated coin metadata", zap.String("coin", metadataReq.Coin))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(coin); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+func (h *Handler) CorrectPrice(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
+		return
+	}
+	log.Info("Handling correct price", zap.String("path", r.URL.Path))
+
+	// Извлечение данных из запроса
+	var correctReq models.CorrectPriceRequest
+	if err := decodeBody(w, r, &correctReq); err != nil {
+		log.Warn("Invalid request body", zap.Error(err))
+		writeError(w, r, err, "Invalid request body")
+		return
+	}
+
+	if correctReq.Timestamp <= 0 {
+		log.Warn("Timestamp must be positive")
+		WriteProblem(w, r, http.StatusUnprocessableEntity, CodeInvalidInput, "Timestamp must be positive")
+		return
+	}
+
+	price, err := h.coinService.CorrectPrice(r.Context(), &correctReq, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to correct price", zap.Error(err))
+		writeError(w, r, err, "Failed to correct price")
+		return
+	}
+	log.Info("Corrected price", zap.String("coin", price.Symbol), zap.Stringer("price", price.Price), zap.Stringer("timestamp", price.Timestamp))
+
+	response := models.NewLegacyPriceResponse(price)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	if r.Method != http.MethodGet {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
+		return
+	}
+	log.Info("Handling get audit log", zap.String("path", r.URL.Path))
+
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Coin:  query.Get("coin"),
+		Actor: query.Get("actor"),
+	}
+	for name, dst := range map[string]*models.Timestamp{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := models.ParseTimestamp(value)
+			if err != nil || parsed < 0 {
+				log.Warn("Invalid query parameter", zap.String("param", name), zap.String("value", value))
+				WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid "+name)
+				return
+			}
+			*dst = parsed
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			log.Warn("Invalid query parameter", zap.String("param", "limit"), zap.String("value", value))
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.coinService.GetAuditLog(r.Context(), &filter)
+	if err != nil {
+		log.Warn("Failed to get audit log", zap.Error(err))
+		writeError(w, r, err, "Failed to get audit log")
+		return
+	}
+	if entries == nil {
+		entries = []*models.AuditEntry{}
+	}
+	log.Info("Get audit log", zap.Int("count", len(entries)))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+func (h *Handler) GetPoolStats(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	if r.Method != http.MethodGet {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		WriteProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Invalid request method")
+		return
+	}
+	log.Info("Handling get pool stats", zap.String("path", r.URL.Path))
+
+	stats, err := h.coinService.PoolStats()
+	if err != nil {
+		log.Warn("Failed to get pool stats", zap.Error(err))
+		writeError(w, r, err, "Pool stats are not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
 }
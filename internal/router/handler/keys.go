@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"awesomeProject/internal/models"
+	"encoding/json"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// CreateAPIKey - POST /admin/keys: выпускает ключ. Ключ показывается только в этом ответе.
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	log.Info("Handling create api key")
+
+	var req models.CreateAPIKeyRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		log.Warn("Invalid request body", zap.Error(err))
+		writeError(w, r, err, "Invalid request body")
+		return
+	}
+
+	created, err := h.authService.CreateAPIKey(r.Context(), &req, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to create api key", zap.Error(err))
+		writeError(w, r, err, "Failed to create api key")
+		return
+	}
+	log.Info("Created api key", zap.Int64("key_id", created.ID), zap.String("name", created.Name))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+	}
+}
+
+// ListAPIKeys - GET /admin/keys: все ключи, включая отозванные, без самих ключей
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	log.Info("Handling list api keys")
+
+	keys, err := h.authService.ListAPIKeys(r.Context())
+	if err != nil {
+		log.Warn("Failed to list api keys", zap.Error(err))
+		writeError(w, r, err, "Failed to list api keys")
+		return
+	}
+	if keys == nil {
+		keys = []*models.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+// RevokeAPIKey - DELETE /admin/keys/{id}: отзывает ключ
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	value := r.PathValue("id")
+	log.Info("Handling revoke api key", zap.String("key_id", value))
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		log.Warn("Invalid key id", zap.String("value", value))
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid key id")
+		return
+	}
+	if err := h.authService.RevokeAPIKey(r.Context(), id, actorFromRequest(r)); err != nil {
+		log.Warn("Failed to revoke api key", zap.Error(err))
+		writeError(w, r, err, "Failed to revoke api key")
+		return
+	}
+	log.Info("Revoked api key", zap.Int64("key_id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
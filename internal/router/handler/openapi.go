@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"awesomeProject/internal/openapi"
+	"net/http"
+)
+
+// GetOpenAPI - GET /openapi.json: документ OpenAPI 3 со всеми маршрутами
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openapi.Spec)
+}
+
+// GetDocs - GET /docs: страница документации по /openapi.json
+func (h *Handler) GetDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(openapi.Docs)
+}
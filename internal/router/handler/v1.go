@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"awesomeProject/internal/models"
+	"encoding/json"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// Обработчики ресурсного API /v1. Метод запроса проверяет ServeMux по шаблону
+// маршрута, символ монеты берется из пути.
+
+// По умолчанию история отдается за последние сутки по часам
+const (
+	defaultHistoryPeriod   = 24 * time.Hour
+	defaultHistoryInterval = "1h"
+)
+
+// GetCoinBySymbol - GET /v1/coins/{symbol}: метаданные и состояние монеты
+func (h *Handler) GetCoinBySymbol(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	symbol := r.PathValue("symbol")
+	log.Info("Handling get coin by symbol", zap.String("coin", symbol))
+
+	coin, err := h.coinService.GetCoin(r.Context(), symbol)
+	if err != nil {
+		log.Warn("Failed to get coin info", zap.Error(err))
+		writeError(w, r, err, "Failed to get coin info")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(coin); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+// PutCoin - PUT /v1/coins/{symbol}: добавляет монету в список наблюдения.
+// Повторный запрос обновляет метаданные у провайдера и возобновляет опрос.
+func (h *Handler) PutCoin(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	symbol := r.PathValue("symbol")
+	log.Info("Handling put coin", zap.String("coin", symbol))
+
+	if err := h.coinService.AddCoin(r.Context(), &models.AddCoinRequest{Coin: symbol}, actorFromRequest(r)); err != nil {
+		log.Warn("Failed to add coin", zap.Error(err))
+		writeError(w, r, err, "Failed to add coin")
+		return
+	}
+	coin, err := h.coinService.GetCoin(r.Context(), symbol)
+	if err != nil {
+		log.Warn("Failed to get coin info", zap.Error(err))
+		writeError(w, r, err, "Failed to get coin info")
+		return
+	}
+	log.Info("Added coin", zap.String("coin", coin.Symbol))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(coin); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+// DeleteCoinBySymbol - DELETE /v1/coins/{symbol}: убирает монету из списка наблюдения,
+// история цен сохраняется
+func (h *Handler) DeleteCoinBySymbol(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	symbol := r.PathValue("symbol")
+	log.Info("Handling delete coin by symbol", zap.String("coin", symbol))
+
+	if err := h.coinService.RemoveCoin(r.Context(), &models.AddCoinRequest{Coin: symbol}, actorFromRequest(r)); err != nil {
+		log.Warn("Failed to remove coin", zap.Error(err))
+		writeError(w, r, err, "Failed to remove coin")
+		return
+	}
+	log.Info("Removed coin", zap.String("coin", symbol))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCoinPrice - GET /v1/coins/{symbol}/price?at=: ближайшая к моменту at цена,
+// без at - последняя известная цена
+func (h *Handler) GetCoinPrice(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	symbol := r.PathValue("symbol")
+	log.Info("Handling get coin price", zap.String("coin", symbol))
+
+	at := models.TimestampOf(time.Now())
+	if value := r.URL.Query().Get("at"); value != "" {
+		parsed, err := models.ParseTimestamp(value)
+		if err != nil || parsed <= 0 {
+			log.Warn("Invalid query parameter", zap.String("param", "at"), zap.String("value", value))
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid at")
+			return
+		}
+		at = parsed
+	}
+
+	price, err := h.coinService.GetPrice(r.Context(), &models.GetPriceRequest{Coin: symbol, Timestamp: at})
+	if err != nil {
+		log.Warn("Failed to get coin", zap.Error(err))
+		writeError(w, r, err, "Failed to get coin")
+		return
+	}
+	log.Info("Get coin", zap.String("coin", price.Symbol), zap.Stringer("price", price.Price))
+
+	response := models.GetPriceResponse{
+		Coin:      price.Symbol,
+		Price:     price.Price,
+		Timestamp: price.Timestamp,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+// GetCoinHistory - GET /v1/coins/{symbol}/history?from=&to=&interval=&timezone=:
+// OHLC-агрегаты цены за период [from, to). По умолчанию - последние сутки по часам.
+func (h *Handler) GetCoinHistory(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	symbol := r.PathValue("symbol")
+	log.Info("Handling get coin history", zap.String("coin", symbol))
+
+	query := r.URL.Query()
+	bucketsReq := models.GetBucketsRequest{
+		Coin:     symbol,
+		Interval: query.Get("interval"),
+		Timezone: query.Get("timezone"),
+	}
+	if bucketsReq.Interval == "" {
+		bucketsReq.Interval = defaultHistoryInterval
+	}
+	for name, dst := range map[string]*models.Timestamp{"from": &bucketsReq.From, "to": &bucketsReq.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := models.ParseTimestamp(value)
+			if err != nil || parsed < 0 {
+				log.Warn("Invalid query parameter", zap.String("param", name), zap.String("value", value))
+				WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid "+name)
+				return
+			}
+			*dst = parsed
+		}
+	}
+	if bucketsReq.To == 0 {
+		bucketsReq.To = models.TimestampOf(time.Now())
+	}
+	if bucketsReq.From == 0 {
+		bucketsReq.From = bucketsReq.To - models.Timestamp(defaultHistoryPeriod.Milliseconds())
+	}
+
+	buckets, err := h.coinService.GetPriceBuckets(r.Context(), &bucketsReq)
+	if err != nil {
+		log.Warn("Failed to get buckets", zap.Error(err))
+		writeError(w, r, err, "Failed to get buckets")
+		return
+	}
+	log.Info("Get buckets", zap.String("coin", bucketsReq.Coin), zap.Int("count", len(buckets)))
+
+	if buckets == nil {
+		buckets = []*models.PriceBucket{}
+	}
+	response := models.GetBucketsResponse{
+		Coin:     bucketsReq.Coin,
+		Interval: bucketsReq.Interval,
+		Timezone: bucketsReq.Timezone,
+		Buckets:  buckets,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
+
+// GetLatestPrices - GET /v1/prices: отслеживаемые монеты с последней ценой, ее временем и возрастом.
+// Ответ собирается из таблицы последних цен в памяти без запросов цен к хранилищу.
+func (h *Handler) GetLatestPrices(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	log.Info("Handling get latest prices")
+
+	prices, err := h.coinService.GetLatestPrices(r.Context())
+	if err != nil {
+		log.Warn("Failed to get latest prices", zap.Error(err))
+		writeError(w, r, err, "Failed to get latest prices")
+		return
+	}
+	log.Info("Get latest prices", zap.Int("count", len(prices)))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(prices); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+		writeError(w, r, err, "Failed to encode response")
+		return
+	}
+}
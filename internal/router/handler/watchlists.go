@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"awesomeProject/internal/models"
+	"encoding/json"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// Обработчики списков наблюдения /v1/watchlists. Владелец списка - инициатор запроса,
+// то есть имя API-ключа; списки видят владелец и пользователи, которым он их открыл.
+
+// watchlistID разбирает идентификатор списка из пути; при ошибке отвечает 400
+func watchlistID(w http.ResponseWriter, r *http.Request, log *zap.Logger) (int64, bool) {
+	value := r.PathValue("id")
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		log.Warn("Invalid watchlist id", zap.String("value", value))
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid watchlist id")
+		return 0, false
+	}
+	return id, true
+}
+
+// writeJSON отвечает значением v в JSON со статусом status
+func writeJSON(w http.ResponseWriter, r *http.Request, log *zap.Logger, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn("Failed to encode response", zap.Error(err))
+	}
+}
+
+// ListWatchlists - GET /v1/watchlists: списки пользователя и открытые ему списки
+func (h *Handler) ListWatchlists(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	log.Info("Handling list watchlists")
+
+	lists, err := h.coinService.ListWatchlists(r.Context(), actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to list watchlists", zap.Error(err))
+		writeError(w, r, err, "Failed to list watchlists")
+		return
+	}
+	writeJSON(w, r, log, http.StatusOK, lists)
+}
+
+// CreateWatchlist - POST /v1/watchlists: создает пустой список
+func (h *Handler) CreateWatchlist(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	log.Info("Handling create watchlist")
+
+	var req models.WatchlistRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		log.Warn("Invalid request body", zap.Error(err))
+		writeError(w, r, err, "Invalid request body")
+		return
+	}
+	created, err := h.coinService.CreateWatchlist(r.Context(), &req, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to create watchlist", zap.Error(err))
+		writeError(w, r, err, "Failed to create watchlist")
+		return
+	}
+	log.Info("Created watchlist", zap.Int64("watchlist_id", created.ID), zap.String("name", created.Name))
+	writeJSON(w, r, log, http.StatusCreated, created)
+}
+
+// GetWatchlist - GET /v1/watchlists/{id}: список с последними ценами монет
+func (h *Handler) GetWatchlist(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	id, ok := watchlistID(w, r, log)
+	if !ok {
+		return
+	}
+	log.Info("Handling get watchlist", zap.Int64("watchlist_id", id))
+
+	watchlist, err := h.coinService.GetWatchlist(r.Context(), id, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to get watchlist", zap.Error(err))
+		writeError(w, r, err, "Failed to get watchlist")
+		return
+	}
+	writeJSON(w, r, log, http.StatusOK, watchlist)
+}
+
+// RenameWatchlist - PATCH /v1/watchlists/{id}: переименовывает список
+func (h *Handler) RenameWatchlist(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	id, ok := watchlistID(w, r, log)
+	if !ok {
+		return
+	}
+	log.Info("Handling rename watchlist", zap.Int64("watchlist_id", id))
+
+	var req models.WatchlistRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		log.Warn("Invalid request body", zap.Error(err))
+		writeError(w, r, err, "Invalid request body")
+		return
+	}
+	watchlist, err := h.coinService.RenameWatchlist(r.Context(), id, &req, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to rename watchlist", zap.Error(err))
+		writeError(w, r, err, "Failed to rename watchlist")
+		return
+	}
+	writeJSON(w, r, log, http.StatusOK, watchlist)
+}
+
+// DeleteWatchlist - DELETE /v1/watchlists/{id}: удаляет список; монеты остаются в справочнике
+func (h *Handler) DeleteWatchlist(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	id, ok := watchlistID(w, r, log)
+	if !ok {
+		return
+	}
+	log.Info("Handling delete watchlist", zap.Int64("watchlist_id", id))
+
+	if err := h.coinService.DeleteWatchlist(r.Context(), id, actorFromRequest(r)); err != nil {
+		log.Warn("Failed to delete watchlist", zap.Error(err))
+		writeError(w, r, err, "Failed to delete watchlist")
+		return
+	}
+	log.Info("Deleted watchlist", zap.Int64("watchlist_id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PutWatchlistCoin - PUT /v1/watchlists/{id}/coins/{symbol}: добавляет монету в список
+func (h *Handler) PutWatchlistCoin(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	id, ok := watchlistID(w, r, log)
+	if !ok {
+		return
+	}
+	symbol := r.PathValue("symbol")
+	log.Info("Handling add watchlist coin", zap.Int64("watchlist_id", id), zap.String("coin", symbol))
+
+	watchlist, err := h.coinService.AddWatchlistCoin(r.Context(), id, symbol, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to add coin to watchlist", zap.Error(err))
+		writeError(w, r, err, "Failed to add coin to watchlist")
+		return
+	}
+	writeJSON(w, r, log, http.StatusOK, watchlist)
+}
+
+// DeleteWatchlistCoin - DELETE /v1/watchlists/{id}/coins/{symbol}: убирает монету из списка
+func (h *Handler) DeleteWatchlistCoin(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	id, ok := watchlistID(w, r, log)
+	if !ok {
+		return
+	}
+	symbol := r.PathValue("symbol")
+	log.Info("Handling remove watchlist coin", zap.Int64("watchlist_id", id), zap.String("coin", symbol))
+
+	watchlist, err := h.coinService.RemoveWatchlistCoin(r.Context(), id, symbol, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to remove coin from watchlist", zap.Error(err))
+		writeError(w, r, err, "Failed to remove coin from watchlist")
+		return
+	}
+	writeJSON(w, r, log, http.StatusOK, watchlist)
+}
+
+// PutWatchlistShare - PUT /v1/watchlists/{id}/shares/{user}: открывает список пользователю для чтения
+func (h *Handler) PutWatchlistShare(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	id, ok := watchlistID(w, r, log)
+	if !ok {
+		return
+	}
+	user := r.PathValue("user")
+	log.Info("Handling share watchlist", zap.Int64("watchlist_id", id), zap.String("user", user))
+
+	watchlist, err := h.coinService.ShareWatchlist(r.Context(), id, user, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to share watchlist", zap.Error(err))
+		writeError(w, r, err, "Failed to share watchlist")
+		return
+	}
+	writeJSON(w, r, log, http.StatusOK, watchlist)
+}
+
+// DeleteWatchlistShare - DELETE /v1/watchlists/{id}/shares/{user}: закрывает список пользователю
+func (h *Handler) DeleteWatchlistShare(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value("logger").(*zap.Logger)
+	id, ok := watchlistID(w, r, log)
+	if !ok {
+		return
+	}
+	user := r.PathValue("user")
+	log.Info("Handling unshare watchlist", zap.Int64("watchlist_id", id), zap.String("user", user))
+
+	watchlist, err := h.coinService.UnshareWatchlist(r.Context(), id, user, actorFromRequest(r))
+	if err != nil {
+		log.Warn("Failed to unshare watchlist", zap.Error(err))
+		writeError(w, r, err, "Failed to unshare watchlist")
+		return
+	}
+	writeJSON(w, r, log, http.StatusOK, watchlist)
+}
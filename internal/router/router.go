@@ -2,40 +2,68 @@ package router
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"awesomeProject/internal/models"
+	"awesomeProject/internal/ratelimit"
 	"awesomeProject/internal/router/handler"
+	"awesomeProject/internal/service"
 	"go.uber.org/zap"
 )
 
+// authenticator находит инициатора запроса по API-ключу
+type authenticator interface {
+	Authenticate(ctx context.Context, key string) (*models.Principal, error)
+}
+
+// RateLimits - ограничения клиентов: ключ ограничивается по наибольшему из своих прав,
+// запросы без ключа - по IP-адресу
+type RateLimits struct {
+	Anonymous ratelimit.Limit
+	Scopes    map[models.Scope]ratelimit.Limit
+}
+
 type Router struct {
 	mux         *http.ServeMux
 	log         *zap.Logger
 	coinHandler *handler.Handler
+	auth        authenticator
+	limiter     *ratelimit.Limiter
+	limits      RateLimits
 	server      *http.Server
+	adminToken  string
 }
 
-func NewRouter(coinHandler *handler.Handler, log *zap.Logger) *Router {
+// NewRouter создает маршрутизатор. Запросы подписываются API-ключами из auth;
+// adminToken - дополнительный ключ с правом admin для первого входа, пустой токен его отключает.
+func NewRouter(coinHandler *handler.Handler, auth authenticator, limiter *ratelimit.Limiter, limits RateLimits, adminToken string, log *zap.Logger) *Router {
 	return &Router{
 		mux:         http.NewServeMux(),
 		log:         log.Named("request"),
 		coinHandler: coinHandler,
+		auth:        auth,
+		limiter:     limiter,
+		limits:      limits,
+		adminToken:  adminToken,
 	}
 }
 
 func (r *Router) RunRouter(addr string) error {
-	// Настройка обработчиков
-	r.mux.HandleFunc("/currency/add", r.coinHandler.AddCoin)
-	r.mux.HandleFunc("/currency/get", r.coinHandler.GetCoin)
-	r.mux.HandleFunc("/currency/remove", r.coinHandler.DeleteCoin)
-
 	r.server = &http.Server{
 		Addr:    addr,
-		Handler: r.loggingMiddleware(r.mux),
+		Handler: r.routes(),
 	}
 
 	serverErr := make(chan error, 1)
@@ -72,17 +100,236 @@ func (r *Router) RunRouter(addr string) error {
 	return nil
 }
 
+// routes регистрирует маршруты и возвращает обработчик запросов вместе с проверкой ключей
+// и ограничением частоты. Документация открыта, остальные маршруты требуют ключа с нужным правом.
+func (r *Router) routes() http.Handler {
+	read, write, admin := models.ScopeRead, models.ScopeWrite, models.ScopeAdmin
+	r.mux.Handle("GET /v1/coins", require(read, r.coinHandler.ListCoins))
+	r.mux.Handle("GET /v1/coins/{symbol}", require(read, r.coinHandler.GetCoinBySymbol))
+	r.mux.Handle("PUT /v1/coins/{symbol}", require(write, r.coinHandler.PutCoin))
+	r.mux.Handle("DELETE /v1/coins/{symbol}", require(write, r.coinHandler.DeleteCoinBySymbol))
+	r.mux.Handle("GET /v1/coins/{symbol}/price", require(read, r.coinHandler.GetCoinPrice))
+	r.mux.Handle("GET /v1/coins/{symbol}/history", require(read, r.coinHandler.GetCoinHistory))
+	r.mux.Handle("GET /v1/prices", require(read, r.coinHandler.GetLatestPrices))
+	r.mux.Handle("GET /v1/watchlists", require(read, r.coinHandler.ListWatchlists))
+	r.mux.Handle("POST /v1/watchlists", require(write, r.coinHandler.CreateWatchlist))
+	r.mux.Handle("GET /v1/watchlists/{id}", require(read, r.coinHandler.GetWatchlist))
+	r.mux.Handle("PATCH /v1/watchlists/{id}", require(write, r.coinHandler.RenameWatchlist))
+	r.mux.Handle("DELETE /v1/watchlists/{id}", require(write, r.coinHandler.DeleteWatchlist))
+	r.mux.Handle("PUT /v1/watchlists/{id}/coins/{symbol}", require(write, r.coinHandler.PutWatchlistCoin))
+	r.mux.Handle("DELETE /v1/watchlists/{id}/coins/{symbol}", require(write, r.coinHandler.DeleteWatchlistCoin))
+	r.mux.Handle("PUT /v1/watchlists/{id}/shares/{user}", require(write, r.coinHandler.PutWatchlistShare))
+	r.mux.Handle("DELETE /v1/watchlists/{id}/shares/{user}", require(write, r.coinHandler.DeleteWatchlistShare))
+	r.mux.Handle("GET /v1/alerts", require(read, r.coinHandler.ListAlertRules))
+	r.mux.Handle("POST /v1/alerts", require(write, r.coinHandler.CreateAlertRule))
+	r.mux.Handle("GET /v1/alerts/history", require(read, r.coinHandler.GetAlertHistory))
+	r.mux.Handle("GET /v1/alerts/{id}", require(read, r.coinHandler.GetAlertRule))
+	r.mux.Handle("DELETE /v1/alerts/{id}", require(write, r.coinHandler.DeleteAlertRule))
+	r.mux.HandleFunc("GET /openapi.json", r.coinHandler.GetOpenAPI)
+	r.mux.HandleFunc("GET /docs", r.coinHandler.GetDocs)
+
+	// Старые маршруты работают до отключения, ответы помечены заголовками Deprecation и Link
+	r.mux.Handle("/currency/add", deprecated("/v1/coins/{symbol}", require(write, r.coinHandler.AddCoin)))
+	r.mux.Handle("/currency/get", deprecated("/v1/coins/{symbol}/price", require(read, r.coinHandler.GetCoin)))
+	r.mux.Handle("/currency/remove", deprecated("/v1/coins/{symbol}", require(write, r.coinHandler.DeleteCoin)))
+	r.mux.Handle("/currency/aggregate", deprecated("/v1/coins/{symbol}/history", require(read, r.coinHandler.GetBuckets)))
+	r.mux.Handle("/currency/list", deprecated("/v1/coins", require(read, r.coinHandler.ListCoins)))
+	r.mux.Handle("/currency/info", deprecated("/v1/coins/{symbol}", require(read, r.coinHandler.GetCoinInfo)))
+	r.mux.Handle("/currency/status", require(write, r.coinHandler.SetCoinStatus))
+	r.mux.Handle("/currency/metadata", require(write, r.coinHandler.UpdateCoinMetadata))
+	r.mux.Handle("/admin/currency/purge", require(admin, r.coinHandler.PurgeCoin))
+	r.mux.Handle("/admin/currency/price", require(admin, r.coinHandler.CorrectPrice))
+	r.mux.Handle("/admin/audit", require(admin, r.coinHandler.GetAuditLog))
+	r.mux.Handle("/admin/db/stats", require(admin, r.coinHandler.GetPoolStats))
+	r.mux.Handle("POST /admin/keys", require(admin, r.coinHandler.CreateAPIKey))
+	r.mux.Handle("GET /admin/keys", require(admin, r.coinHandler.ListAPIKeys))
+	r.mux.Handle("DELETE /admin/keys/{id}", require(admin, r.coinHandler.RevokeAPIKey))
+
+	return r.loggingMiddleware(r.rateLimitMiddleware(r.mux))
+}
+
 func (r *Router) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		requestLog := r.log.With(
+		requestID := req.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := context.WithValue(req.Context(), "request_id", requestID)
+
+		// Подбор ключей ограничивается отдельной корзиной адреса клиента с ограничением
+		// запросов без ключа: неудачные попытки расходуют ее, а после исчерпания ключ
+		// не проверяется, пока корзина не пополнится
+		ipClient, ipLimit := r.limits.client(nil, req)
+		ipClient = "auth:" + ipClient
+		var principal *models.Principal
+		var authErr error
+		guard := ratelimit.Decision{Allowed: true}
+		if req.Header.Get("Authorization") != "" {
+			guard = r.limiter.Peek(ipClient, ipLimit)
+		}
+		if guard.Allowed {
+			principal, authErr = r.authenticate(req)
+			if errors.Is(authErr, service.ErrUnauthorized) {
+				guard = r.limiter.Allow(ipClient, ipLimit)
+			}
+		}
+		fields := []zap.Field{
 			zap.String("method", req.Method),
 			zap.String("path", req.URL.Path),
 			zap.String("remote_addr", req.RemoteAddr),
-		)
+			zap.String("request_id", requestID),
+		}
+		actor := "anonymous"
+		if principal != nil {
+			actor = principal.Name
+			fields = append(fields, zap.String("principal", principal.Name), zap.Int64("key_id", principal.KeyID))
+			ctx = context.WithValue(ctx, "principal", principal)
+		} else {
+			fields = append(fields, zap.String("principal", actor))
+		}
+		requestLog := r.log.With(fields...)
 
 		requestLog.Info("Request started")
-		ctx := context.WithValue(req.Context(), "logger", requestLog)
-		next.ServeHTTP(w, req.WithContext(ctx))
+		ctx = context.WithValue(ctx, "logger", requestLog)
+		ctx = context.WithValue(ctx, "actor", actor)
+		req = req.WithContext(ctx)
+		switch {
+		case !guard.Allowed:
+			requestLog.Warn("Too many failed API key attempts")
+			writeLimited(w, req, guard, ipClient)
+		case errors.Is(authErr, service.ErrUnauthorized):
+			// Неверный ключ отклоняется и на открытых маршрутах: клиент должен узнать об ошибке
+			requestLog.Warn("Invalid API key")
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			handler.WriteProblem(w, req, http.StatusUnauthorized, handler.CodeUnauthorized, "Invalid API key")
+		case authErr != nil:
+			requestLog.Error("Failed to authenticate", zap.Error(authErr))
+			handler.WriteProblem(w, req, http.StatusServiceUnavailable, handler.CodeUnavailable, "Failed to authenticate")
+		default:
+			next.ServeHTTP(w, req)
+		}
 		requestLog.Info("Request completed")
 	})
 }
+
+// authenticate находит инициатора по заголовку Authorization: Bearer <ключ>.
+// Запрос без заголовка - анонимный (nil без ошибки).
+func (r *Router) authenticate(req *http.Request) (*models.Principal, error) {
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return nil, nil
+	}
+	key, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || key == "" {
+		return nil, service.ErrUnauthorized
+	}
+	if r.adminToken != "" && subtle.ConstantTimeCompare([]byte(key), []byte(r.adminToken)) == 1 {
+		return &models.Principal{Name: "admin", Scopes: []models.Scope{models.ScopeAdmin}}, nil
+	}
+	return r.auth.Authenticate(req.Context(), key)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// rateLimitMiddleware ограничивает частоту запросов и суточную квоту клиента.
+// Ответы ограниченным клиентам несут заголовки RateLimit-Limit, RateLimit-Remaining
+// и RateLimit-Reset, отказ - 429 с Retry-After.
+func (r *Router) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		principal, _ := req.Context().Value("principal").(*models.Principal)
+		client, limit := r.limits.client(principal, req)
+		decision := r.limiter.Allow(client, limit)
+		if decision.Allowed {
+			setRateLimitHeaders(w, decision)
+			next.ServeHTTP(w, req)
+			return
+		}
+		writeLimited(w, req, decision, client)
+	})
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, decision ratelimit.Decision) {
+	if decision.Limited {
+		w.Header().Set("RateLimit-Limit", strconv.FormatInt(decision.Limit, 10))
+		w.Header().Set("RateLimit-Remaining", strconv.FormatInt(decision.Remaining, 10))
+		w.Header().Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(decision.Reset), 10))
+	}
+}
+
+// writeLimited отвечает 429 клиенту, которому ограничение отказало в запросе
+func writeLimited(w http.ResponseWriter, req *http.Request, decision ratelimit.Decision, client string) {
+	log := req.Context().Value("logger").(*zap.Logger)
+	setRateLimitHeaders(w, decision)
+	w.Header().Set("Retry-After", strconv.FormatInt(max(ceilSeconds(decision.RetryAfter), 1), 10))
+	if decision.Quota {
+		log.Warn("Daily quota exceeded", zap.String("client", client))
+		handler.WriteProblem(w, req, http.StatusTooManyRequests, handler.CodeQuotaExceeded, "Daily request quota exceeded")
+		return
+	}
+	log.Warn("Rate limit exceeded", zap.String("client", client))
+	handler.WriteProblem(w, req, http.StatusTooManyRequests, handler.CodeRateLimited, "Too many requests")
+}
+
+// client определяет клиента для учета запросов и его ограничение.
+// Адрес берется из соединения: X-Forwarded-For не учитывается, его может подделать клиент.
+func (l RateLimits) client(principal *models.Principal, req *http.Request) (string, ratelimit.Limit) {
+	if principal == nil {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			host = req.RemoteAddr
+		}
+		return "ip:" + host, l.Anonymous
+	}
+	client := "admin"
+	if principal.KeyID != 0 {
+		client = "key:" + strconv.FormatInt(principal.KeyID, 10)
+	}
+	for _, scope := range []models.Scope{models.ScopeAdmin, models.ScopeWrite, models.ScopeRead} {
+		if principal.Allows(scope) {
+			return client, l.Scopes[scope]
+		}
+	}
+	return client, l.Anonymous
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// require пропускает запросы инициатора с правом scope: без ключа - 401, с ключом без права - 403
+func require(scope models.Scope, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log := req.Context().Value("logger").(*zap.Logger)
+		principal, _ := req.Context().Value("principal").(*models.Principal)
+		if principal == nil {
+			log.Warn("Missing API key")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			handler.WriteProblem(w, req, http.StatusUnauthorized, handler.CodeUnauthorized, "API key is required")
+			return
+		}
+		if !principal.Allows(scope) {
+			log.Warn("Insufficient scope", zap.String("scope", string(scope)))
+			handler.WriteProblem(w, req, http.StatusForbidden, handler.CodeForbidden, "API key lacks scope "+string(scope))
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// deprecated помечает ответы устаревшего маршрута ссылкой на маршрут /v1, который его заменяет
+func deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log := req.Context().Value("logger").(*zap.Logger)
+		log.Warn("Deprecated route", zap.String("successor", successor))
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+		next.ServeHTTP(w, req)
+	})
+}
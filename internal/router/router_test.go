@@ -0,0 +1,347 @@
+package router
+
+import (
+	"awesomeProject/internal/models"
+	"awesomeProject/internal/ratelimit"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/router/handler"
+	"awesomeProject/internal/service"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const testAdminToken = "test-admin-token"
+
+// testProvider отдает метаданные любой монеты без обращения к CoinGecko
+type testProvider struct{}
+
+func (testProvider) GetCoinMetadata(symbol string) (*models.CoinMetadata, error) {
+	return &models.CoinMetadata{Name: symbol + " coin", ProviderID: strings.ToLower(symbol)}, nil
+}
+
+// testServer - маршрутизатор поверх хранилища в памяти
+type testServer struct {
+	handler http.Handler
+	repo    *repository.MemoryRepository
+	coins   *service.CoinService
+	auth    *service.AuthService
+}
+
+func newTestServer(t *testing.T, limits RateLimits) *testServer {
+	t.Helper()
+	repo := repository.NewMemoryRepository()
+	coins := service.NewCoinService(repo, testProvider{}, "UTC")
+	auth := service.NewAuthService(repo)
+	r := NewRouter(handler.NewHandler(coins, auth), auth, ratelimit.New(repo), limits, testAdminToken, zap.NewNop())
+	return &testServer{handler: r.routes(), repo: repo, coins: coins, auth: auth}
+}
+
+// createKey выпускает ключ и возвращает его открытое значение и идентификатор
+func (s *testServer) createKey(t *testing.T, name string, scopes ...models.Scope) (string, int64) {
+	t.Helper()
+	created, err := s.auth.CreateAPIKey(t.Context(), &models.CreateAPIKeyRequest{Name: name, Scopes: scopes}, &models.Actor{Name: "admin"})
+	if err != nil {
+		t.Fatalf("CreateAPIKey(%s): %v", name, err)
+	}
+	return created.Key, created.ID
+}
+
+// do выполняет запрос с заголовком Authorization (пустой - без заголовка) с адреса remoteAddr
+func (s *testServer) do(method, target, authorization, body, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	if remoteAddr != "" {
+		req.RemoteAddr = remoteAddr
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// problem разбирает ответ с ошибкой RFC 7807
+func problem(t *testing.T, rec *httptest.ResponseRecorder) models.Problem {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q, want application/problem+json; body %s", ct, rec.Body)
+	}
+	var p models.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("failed to decode problem %s: %v", rec.Body, err)
+	}
+	if p.Status != rec.Code {
+		t.Errorf("problem status = %d, response status = %d", p.Status, rec.Code)
+	}
+	return p
+}
+
+func TestAuthentication(t *testing.T) {
+	s := newTestServer(t, RateLimits{})
+	readKey, _ := s.createKey(t, "grafana", models.ScopeRead)
+	writeKey, _ := s.createKey(t, "loader", models.ScopeWrite)
+	adminKey, _ := s.createKey(t, "ops", models.ScopeAdmin)
+	revokedKey, revokedID := s.createKey(t, "old", models.ScopeAdmin)
+
+	// Ключ проверен и закэширован до отзыва: отзыв сбрасывает кэш
+	if rec := s.do(http.MethodGet, "/admin/keys", "Bearer "+revokedKey, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("GET /admin/keys before revoke = %d, want 200", rec.Code)
+	}
+	if err := s.auth.RevokeAPIKey(t.Context(), revokedID, &models.Actor{Name: "admin"}); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		method, path  string
+		authorization string
+		status        int
+		code          string
+		challenge     string // Ожидаемый WWW-Authenticate
+	}{
+		{"no key", http.MethodGet, "/v1/coins", "", http.StatusUnauthorized, handler.CodeUnauthorized, "Bearer"},
+		{"not bearer", http.MethodGet, "/v1/coins", "Basic " + readKey, http.StatusUnauthorized, handler.CodeUnauthorized, `Bearer error="invalid_token"`},
+		{"empty bearer", http.MethodGet, "/v1/coins", "Bearer ", http.StatusUnauthorized, handler.CodeUnauthorized, `Bearer error="invalid_token"`},
+		{"unknown key", http.MethodGet, "/v1/coins", "Bearer cct_unknown", http.StatusUnauthorized, handler.CodeUnauthorized, `Bearer error="invalid_token"`},
+		{"revoked key", http.MethodGet, "/v1/coins", "Bearer " + revokedKey, http.StatusUnauthorized, handler.CodeUnauthorized, `Bearer error="invalid_token"`},
+		{"invalid key on open route", http.MethodGet, "/openapi.json", "Bearer cct_unknown", http.StatusUnauthorized, handler.CodeUnauthorized, `Bearer error="invalid_token"`},
+		{"no key on open route", http.MethodGet, "/openapi.json", "", http.StatusOK, "", ""},
+		{"read", http.MethodGet, "/v1/coins", "Bearer " + readKey, http.StatusOK, "", ""},
+		{"read without write", http.MethodPut, "/v1/coins/BTC", "Bearer " + readKey, http.StatusForbidden, handler.CodeForbidden, ""},
+		{"write includes read", http.MethodGet, "/v1/coins", "Bearer " + writeKey, http.StatusOK, "", ""},
+		{"write without admin", http.MethodGet, "/admin/keys", "Bearer " + writeKey, http.StatusForbidden, handler.CodeForbidden, ""},
+		{"admin", http.MethodGet, "/admin/keys", "Bearer " + adminKey, http.StatusOK, "", ""},
+		{"admin token", http.MethodGet, "/admin/keys", "Bearer " + testAdminToken, http.StatusOK, "", ""},
+		{"admin token includes read", http.MethodGet, "/v1/coins", "Bearer " + testAdminToken, http.StatusOK, "", ""},
+		{"wrong admin token", http.MethodGet, "/admin/keys", "Bearer " + testAdminToken + "x", http.StatusUnauthorized, handler.CodeUnauthorized, `Bearer error="invalid_token"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(tt.method, tt.path, tt.authorization, "", "")
+			if rec.Code != tt.status {
+				t.Fatalf("%s %s = %d, want %d; body %s", tt.method, tt.path, rec.Code, tt.status, rec.Body)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.challenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.challenge)
+			}
+			if tt.code == "" {
+				return
+			}
+			if p := problem(t, rec); p.Code != tt.code {
+				t.Errorf("problem code = %q, want %q", p.Code, tt.code)
+			}
+		})
+	}
+}
+
+// Неудачные попытки ключа расходуют отдельную корзину адреса клиента: после ее исчерпания
+// ключи с этого адреса не проверяются, а запросы без ключа и с других адресов проходят
+func TestFailedKeyRateLimit(t *testing.T) {
+	s := newTestServer(t, RateLimits{Anonymous: ratelimit.Limit{Rate: 0.001, Burst: 2}})
+	readKey, _ := s.createKey(t, "grafana", models.ScopeRead)
+	const attacker, other = "192.0.2.1:1234", "198.51.100.7:4321"
+
+	// Верный ключ не расходует корзину неудачных попыток
+	for i := 0; i < 3; i++ {
+		if rec := s.do(http.MethodGet, "/v1/coins", "Bearer "+readKey, "", attacker); rec.Code != http.StatusOK {
+			t.Fatalf("valid key request %d = %d, want 200", i, rec.Code)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if rec := s.do(http.MethodGet, "/v1/coins", "Bearer cct_guess", "", attacker); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("failed attempt %d = %d, want 401", i, rec.Code)
+		}
+	}
+
+	for _, authorization := range []string{"Bearer cct_guess", "Bearer " + readKey} {
+		rec := s.do(http.MethodGet, "/v1/coins", authorization, "", attacker)
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("attempt after limit with %q = %d, want 429", authorization, rec.Code)
+		}
+		if p := problem(t, rec); p.Code != handler.CodeRateLimited {
+			t.Errorf("problem code = %q, want %q", p.Code, handler.CodeRateLimited)
+		}
+		if rec.Header().Get("Retry-After") == "" {
+			t.Errorf("429 without Retry-After")
+		}
+	}
+
+	if rec := s.do(http.MethodGet, "/openapi.json", "", "", attacker); rec.Code != http.StatusOK {
+		t.Errorf("request without key after limit = %d, want 200", rec.Code)
+	}
+	if rec := s.do(http.MethodGet, "/v1/coins", "Bearer "+readKey, "", other); rec.Code != http.StatusOK {
+		t.Errorf("valid key from another address = %d, want 200", rec.Code)
+	}
+}
+
+// decodeJSON разбирает ответ 200 в v, сохраняя числа как json.Number
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	decoder := json.NewDecoder(rec.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	s := newTestServer(t, RateLimits{})
+	key, _ := s.createKey(t, "loader", models.ScopeWrite)
+	bearer := "Bearer " + key
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		return s.do(method, target, bearer, body, "")
+	}
+
+	// Монеты добавляются через /v1 и через старый маршрут
+	if rec := do(http.MethodPut, "/v1/coins/BTC", ""); rec.Code != http.StatusOK {
+		t.Fatalf("PUT /v1/coins/BTC = %d; body %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/currency/add", `{"coin":"ETH"}`); rec.Code != http.StatusOK && rec.Code != http.StatusCreated {
+		t.Fatalf("POST /currency/add = %d; body %s", rec.Code, rec.Body)
+	}
+
+	btc, err := s.repo.GetCoin(t.Context(), "BTC")
+	if err != nil {
+		t.Fatalf("GetCoin(BTC): %v", err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var prices []*models.CryptoPrice
+	for i, price := range []string{"100", "110", "120"} {
+		// Миллисекунды второго тика видны только в ответах /v1
+		ts := base.Add(time.Duration(i)*30*time.Minute + time.Duration(i%2)*250*time.Millisecond)
+		tick := &models.CryptoPrice{CoinID: btc.ID, Symbol: "BTC", Price: decimal.RequireFromString(price), Timestamp: models.TimestampOf(ts)}
+		if err := s.repo.AddNewPrice(t.Context(), tick); err != nil {
+			t.Fatalf("AddNewPrice: %v", err)
+		}
+		prices = append(prices, tick)
+	}
+	s.coins.UpdateLatestPrices(prices)
+	at := strconv.FormatInt(base.Add(40*time.Minute).Unix(), 10)
+	from, to := strconv.FormatInt(base.Unix(), 10), strconv.FormatInt(base.Add(2*time.Hour).Unix(), 10)
+
+	t.Run("list", func(t *testing.T) {
+		for _, path := range []string{"/v1/coins", "/currency/list"} {
+			var coins []models.TrackedCoin
+			decodeJSON(t, do(http.MethodGet, path, ""), &coins)
+			if len(coins) != 2 {
+				t.Errorf("GET %s = %d coins, want 2", path, len(coins))
+			}
+		}
+	})
+
+	t.Run("info", func(t *testing.T) {
+		for _, path := range []string{"/v1/coins/BTC", "/currency/info?coin=BTC"} {
+			var coin models.TrackedCoin
+			decodeJSON(t, do(http.MethodGet, path, ""), &coin)
+			if coin.Symbol != "BTC" || coin.Name == "" {
+				t.Errorf("GET %s = %+v, want BTC", path, coin)
+			}
+		}
+		for _, path := range []string{"/v1/coins/DOGE", "/currency/info?coin=DOGE"} {
+			rec := do(http.MethodGet, path, "")
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("GET %s = %d, want 404", path, rec.Code)
+			}
+			if p := problem(t, rec); p.Code != handler.CodeNotFound {
+				t.Errorf("GET %s problem code = %q, want %q", path, p.Code, handler.CodeNotFound)
+			}
+		}
+	})
+
+	t.Run("price", func(t *testing.T) {
+		var v1 map[string]any
+		decodeJSON(t, do(http.MethodGet, "/v1/coins/BTC/price?at="+at, ""), &v1)
+		if v1["coin"] != "BTC" || v1["price"] != "110" || v1["timestamp"] != json.Number("1704069000.25") {
+			t.Errorf("GET /v1/coins/BTC/price = %v, want BTC 110 at 1704069000.25", v1)
+		}
+
+		// Старый маршрут отдает цену числом, а время - целыми секундами
+		var legacy map[string]any
+		decodeJSON(t, do(http.MethodPost, "/currency/get", `{"coin":"BTC","timestamp":`+at+`}`), &legacy)
+		if legacy["coin"] != "BTC" || legacy["price"] != json.Number("110") || legacy["timestamp"] != json.Number("1704069000") {
+			t.Errorf("POST /currency/get = %v, want BTC 110 at 1704069000", legacy)
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		var v1 struct {
+			Buckets []map[string]any `json:"buckets"`
+		}
+		decodeJSON(t, do(http.MethodGet, "/v1/coins/BTC/history?interval=1h&from="+from+"&to="+to, ""), &v1)
+		var legacy struct {
+			Buckets []map[string]any `json:"buckets"`
+		}
+		decodeJSON(t, do(http.MethodPost, "/currency/aggregate", `{"coin":"BTC","interval":"1h","from":`+from+`,"to":`+to+`}`), &legacy)
+		if len(v1.Buckets) != 2 || len(legacy.Buckets) != 2 {
+			t.Fatalf("buckets: /v1 %v, legacy %v, want 2 each", v1.Buckets, legacy.Buckets)
+		}
+		if v1.Buckets[0]["open"] != "100" || v1.Buckets[0]["close"] != "110" || v1.Buckets[0]["count"] != json.Number("2") {
+			t.Errorf("/v1 first bucket = %v, want open 100, close 110, count 2", v1.Buckets[0])
+		}
+		if legacy.Buckets[0]["open"] != json.Number("100") || legacy.Buckets[0]["close"] != json.Number("110") ||
+			legacy.Buckets[0]["start"] != json.Number(from) {
+			t.Errorf("legacy first bucket = %v, want numeric open 100, close 110 at %s", legacy.Buckets[0], from)
+		}
+	})
+
+	t.Run("latest prices", func(t *testing.T) {
+		var latest []map[string]any
+		decodeJSON(t, do(http.MethodGet, "/v1/prices", ""), &latest)
+		found := false
+		for _, entry := range latest {
+			if entry["coin"] == "BTC" {
+				found = true
+				if entry["price"] != "120" {
+					t.Errorf("latest BTC price = %v, want 120", entry["price"])
+				}
+			}
+		}
+		if len(latest) != 2 || !found {
+			t.Errorf("GET /v1/prices = %v, want BTC and ETH", latest)
+		}
+	})
+
+	t.Run("deprecation headers", func(t *testing.T) {
+		rec := do(http.MethodGet, "/currency/list", "")
+		if rec.Header().Get("Deprecation") != "true" || rec.Header().Get("Link") != `</v1/coins>; rel="successor-version"` {
+			t.Errorf("GET /currency/list headers: Deprecation %q, Link %q", rec.Header().Get("Deprecation"), rec.Header().Get("Link"))
+		}
+		if rec := do(http.MethodGet, "/v1/coins", ""); rec.Header().Get("Deprecation") != "" {
+			t.Errorf("GET /v1/coins is marked deprecated")
+		}
+	})
+
+	t.Run("methods", func(t *testing.T) {
+		if rec := do(http.MethodGet, "/currency/get", ""); rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("GET /currency/get = %d, want 405", rec.Code)
+		}
+		if rec := do(http.MethodPost, "/v1/coins/BTC", ""); rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("POST /v1/coins/BTC = %d, want 405", rec.Code)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		if rec := do(http.MethodDelete, "/v1/coins/ETH", ""); rec.Code != http.StatusNoContent {
+			t.Errorf("DELETE /v1/coins/ETH = %d, want 204; body %s", rec.Code, rec.Body)
+		}
+		if rec := do(http.MethodPost, "/currency/remove", `{"coin":"BTC"}`); rec.Code != http.StatusOK && rec.Code != http.StatusNoContent {
+			t.Errorf("POST /currency/remove = %d; body %s", rec.Code, rec.Body)
+		}
+		var coins []models.TrackedCoin
+		decodeJSON(t, do(http.MethodGet, "/v1/coins", ""), &coins)
+		if len(coins) != 0 {
+			t.Errorf("coins after removal = %+v, want none", coins)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+type partitionStore interface {
+	EnsurePartitions(ctx context.Context, from, to time.Time) ([]string, error)
+	DropPartitionsBefore(ctx context.Context, before time.Time, drop bool) ([]string, error)
+	OldestPriceBefore(ctx context.Context, before time.Time) (time.Time, bool, error)
+}
+
+// PartitionMaintainer заранее создает месячные секции coin_prices
+// и отсоединяет секции старше срока хранения. При архивации тиков секция
+// отсоединяется, только когда ее тики уже перенесены в архив.
+type PartitionMaintainer struct {
+	store           partitionStore
+	premakeMonths   int
+	retentionMonths int
+	dropExpired     bool
+	archived        bool // Тики переносятся в архив
+	checkInterval   time.Duration
+	log             *zap.Logger
+}
+
+func NewPartitionMaintainer(store partitionStore, premakeMonths int, retentionMonths int, dropExpired bool, archived bool, checkInterval time.Duration, log *zap.Logger) *PartitionMaintainer {
+	if checkInterval <= 0 {
+		checkInterval = 24 * time.Hour
+	}
+	return &PartitionMaintainer{
+		store:           store,
+		premakeMonths:   premakeMonths,
+		retentionMonths: retentionMonths,
+		dropExpired:     dropExpired,
+		archived:        archived,
+		checkInterval:   checkInterval,
+		log:             log.Named("PartitionMaintainer"),
+	}
+}
+
+func (m *PartitionMaintainer) Start() {
+	for {
+		m.maintain(time.Now().UTC())
+		time.Sleep(m.checkInterval)
+	}
+}
+
+func (m *PartitionMaintainer) maintain(now time.Time) {
+	created, err := m.store.EnsurePartitions(context.Background(), now, now.AddDate(0, m.premakeMonths, 0))
+	if err != nil {
+		m.log.Error("Error creating partitions", zap.Error(err))
+	} else if len(created) > 0 {
+		m.log.Info("Created partitions", zap.Strings("partitions", created))
+	}
+
+	// retentionMonths = 0 - история хранится бессрочно
+	if m.retentionMonths <= 0 {
+		return
+	}
+	cutoff := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -m.retentionMonths, 0)
+	if m.archived {
+		// Архивация удаляет секции выгруженных месяцев: секция, в которой остались тики, еще не в архиве
+		oldest, ok, err := m.store.OldestPriceBefore(context.Background(), cutoff)
+		if err != nil {
+			m.log.Error("Error finding unarchived prices", zap.Error(err))
+			return
+		}
+		if ok {
+			oldest = oldest.UTC()
+			cutoff = time.Date(oldest.Year(), oldest.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+	expired, err := m.store.DropPartitionsBefore(context.Background(), cutoff, m.dropExpired)
+	if err != nil {
+		m.log.Error("Error expiring partitions", zap.Error(err))
+	} else if len(expired) > 0 {
+		m.log.Info("Expired partitions", zap.Strings("partitions", expired), zap.Bool("dropped", m.dropExpired))
+	}
+}
@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"awesomeProject/internal/archive"
+	"awesomeProject/internal/models"
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+type priceArchiveStore interface {
+	OldestPriceBefore(ctx context.Context, before time.Time) (time.Time, bool, error)
+	ArchiveMonth(ctx context.Context, month time.Time, write func(coinID int64, day time.Time, prices []*models.CryptoPrice) error) (int64, error)
+	ArchiveOrphans(ctx context.Context) ([]int64, error)
+	DeleteArchiveOrphan(ctx context.Context, coinID int64) error
+}
+
+// PriceArchiver переносит тики старше срока хранения из coin_prices в архив
+// по одному месяцу UTC за раз, удаляя секцию месяца после выгрузки, и дочищает
+// архив монет, удаленных безвозвратно
+type PriceArchiver struct {
+	store         priceArchiveStore
+	archive       *archive.Archive
+	checkInterval time.Duration
+	log           *zap.Logger
+}
+
+func NewPriceArchiver(store priceArchiveStore, archive *archive.Archive, checkInterval time.Duration, log *zap.Logger) *PriceArchiver {
+	if checkInterval <= 0 {
+		checkInterval = 24 * time.Hour
+	}
+	return &PriceArchiver{
+		store:         store,
+		archive:       archive,
+		checkInterval: checkInterval,
+		log:           log.Named("PriceArchiver"),
+	}
+}
+
+func (a *PriceArchiver) Start() {
+	for {
+		a.deleteOrphans(context.Background())
+		a.archiveExpired(context.Background(), time.Now().UTC())
+		time.Sleep(a.checkInterval)
+	}
+}
+
+func (a *PriceArchiver) archiveExpired(ctx context.Context, now time.Time) {
+	cutoff := a.archive.Cutoff(now)
+	for {
+		oldest, ok, err := a.store.OldestPriceBefore(ctx, cutoff)
+		if err != nil {
+			a.log.Error("Error finding prices to archive", zap.Error(err))
+			return
+		}
+		if !ok {
+			return
+		}
+
+		// Месяц переносится целиком, когда весь он старше срока хранения
+		oldest = oldest.UTC()
+		month := time.Date(oldest.Year(), oldest.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if month.AddDate(0, 1, 0).After(cutoff) {
+			return
+		}
+		archived, err := a.store.ArchiveMonth(ctx, month, func(coinID int64, day time.Time, prices []*models.CryptoPrice) error {
+			return a.archive.Write(ctx, coinID, day, prices)
+		})
+		if err != nil {
+			a.log.Error("Error archiving prices", zap.Time("month", month), zap.Error(err))
+			return
+		}
+		a.log.Info("Archived prices", zap.Time("month", month), zap.Int64("count", archived))
+	}
+}
+
+// deleteOrphans удаляет архив монет, который не удалось удалить вместе с монетой
+func (a *PriceArchiver) deleteOrphans(ctx context.Context) {
+	orphans, err := a.store.ArchiveOrphans(ctx)
+	if err != nil {
+		a.log.Error("Error getting orphaned archives", zap.Error(err))
+		return
+	}
+	for _, coinID := range orphans {
+		files, err := a.archive.DeleteCoin(ctx, coinID)
+		if err != nil {
+			a.log.Error("Error deleting orphaned archive", zap.Int64("coin_id", coinID), zap.Error(err))
+			continue
+		}
+		if err := a.store.DeleteArchiveOrphan(ctx, coinID); err != nil {
+			a.log.Error("Error deleting archive orphan", zap.Int64("coin_id", coinID), zap.Error(err))
+			continue
+		}
+		a.log.Info("Deleted orphaned archive", zap.Int64("coin_id", coinID), zap.Int("files", files))
+	}
+}
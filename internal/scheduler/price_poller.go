@@ -2,8 +2,10 @@ package scheduler
 
 import (
 	"awesomeProject/api"
+	"awesomeProject/internal/alerts"
 	"awesomeProject/internal/models"
 	"awesomeProject/internal/service"
+	"context"
 	"go.uber.org/zap"
 	"sync"
 	"time"
@@ -11,25 +13,32 @@ import (
 
 type PricePoller struct {
 	coinService  *service.CoinService
+	alerts       *alerts.Engine
 	priceUpdates time.Duration
 	coinGeckoApi *api.CoinGeckoApi
 	log          *zap.Logger
 }
 
-func NewPricePoller(coinService *service.CoinService, priceUpdates time.Duration, coinGeckoApi *api.CoinGeckoApi, log *zap.Logger) *PricePoller {
-	return &PricePoller{coinService: coinService, priceUpdates: priceUpdates, coinGeckoApi: coinGeckoApi, log: log.Named("PricePoller")}
+func NewPricePoller(coinService *service.CoinService, alertEngine *alerts.Engine, priceUpdates time.Duration, coinGeckoApi *api.CoinGeckoApi, log *zap.Logger) *PricePoller {
+	return &PricePoller{coinService: coinService, alerts: alertEngine, priceUpdates: priceUpdates, coinGeckoApi: coinGeckoApi, log: log.Named("PricePoller")}
 }
 
 func (p *PricePoller) Start(maxConcurrent int) {
 	for {
-		coins, err := p.coinService.GetAllCoins()
+		coins, err := p.coinService.GetActiveCoins(context.Background())
 		if err != nil {
-			p.log.Fatal("Error getting all coins", zap.Error(err))
-			return
+			// Сбой хранилища не останавливает опрос: цены соберет следующий цикл
+			p.log.Error("Error getting active coins, skipping tick", zap.Error(err))
+			time.Sleep(p.priceUpdates)
+			continue
 		}
 		var wg sync.WaitGroup
 		wg.Add(len(coins))
 
+		// Цены цикла собираются и записываются одним пакетом
+		var mu sync.Mutex
+		prices := make([]*models.CryptoPrice, 0, len(coins))
+
 		semaphore := make(chan struct{}, maxConcurrent)
 		for i := range coins {
 			semaphore <- struct{}{} // Занимаем слот
@@ -39,38 +48,51 @@ func (p *PricePoller) Start(maxConcurrent int) {
 				price, err := p.coinGeckoApi.GetPriceCoin(coin.Symbol)
 				if err != nil {
 					p.log.Error("Error getting price coin", zap.String("symbol", coin.Symbol), zap.Error(err))
+					return
 				}
-				p.log.Debug("Coin", zap.Int64("ID", coin.ID), zap.String("Symbol", coin.Symbol), zap.Float64("Price", price))
-				err = p.coinService.AddNewPrice(&models.CryptoPrice{
+				p.log.Debug("Coin", zap.Int64("ID", coin.ID), zap.String("Symbol", coin.Symbol), zap.Stringer("Price", price))
+				mu.Lock()
+				prices = append(prices, &models.CryptoPrice{
 					CoinID:    coin.ID,
 					Symbol:    coin.Symbol,
 					Price:     price,
-					Timestamp: time.Now().Unix(),
+					Timestamp: models.TimestampOf(time.Now()),
 				})
-				if err != nil {
-					p.log.Error("Error adding new price", zap.String("symbol", coin.Symbol), zap.Error(err))
-				}
+				mu.Unlock()
 			}(coins[i])
-			//price, err := p.coinGeckoApi.GetPriceCoin(coin.Symbol)
-			//if err != nil {
-			//	p.log.Error("Error getting price coin", zap.String("symbol", coin.Symbol), zap.Error(err))
-			//}
-			//p.log.Debug("Coin", zap.Int64("ID", coin.ID), zap.String("Symbol", coin.Symbol), zap.Float64("Price", price))
-			//err = p.coinService.AddNewPrice(&models.CryptoPrice{
-			//	CoinID:    coin.ID,
-			//	Symbol:    coin.Symbol,
-			//	Price:     price,
-			//	Timestamp: time.Now().Unix(),
-			//})
-			//if err != nil {
-			//	p.log.Error("Error adding new price", zap.String("symbol", coin.Symbol), zap.Error(err))
-			//}
-
 		}
 
 		wg.Wait()
 		close(semaphore)
 
+		p.savePrices(prices)
+
 		time.Sleep(p.priceUpdates)
 	}
 }
+
+// savePrices записывает цены цикла и проверяет по ним правила оповещения;
+// ошибка одной монеты не мешает остальным
+func (p *PricePoller) savePrices(prices []*models.CryptoPrice) {
+	if len(prices) == 0 {
+		return
+	}
+	errs, err := p.coinService.AddNewPrices(context.Background(), prices)
+	if err != nil {
+		p.log.Error("Error adding new prices", zap.Int("count", len(prices)), zap.Error(err))
+		return
+	}
+	saved := make([]*models.CryptoPrice, 0, len(prices))
+	for i, err := range errs {
+		if err != nil {
+			p.log.Error("Error adding new price", zap.String("symbol", prices[i].Symbol), zap.Error(err))
+			continue
+		}
+		saved = append(saved, prices[i])
+	}
+	p.coinService.UpdateLatestPrices(saved)
+
+	if err := p.alerts.Evaluate(context.Background(), saved); err != nil {
+		p.log.Error("Error evaluating alert rules", zap.Error(err))
+	}
+}
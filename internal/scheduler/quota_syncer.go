@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+type quotaStore interface {
+	Sync(ctx context.Context) error
+}
+
+// QuotaSyncer периодически сохраняет суточные квоты запросов к API и загружает
+// запросы, сделанные через остальные экземпляры сервиса
+type QuotaSyncer struct {
+	limiter  quotaStore
+	interval time.Duration
+	log      *zap.Logger
+}
+
+func NewQuotaSyncer(limiter quotaStore, interval time.Duration, log *zap.Logger) *QuotaSyncer {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &QuotaSyncer{limiter: limiter, interval: interval, log: log.Named("QuotaSyncer")}
+}
+
+func (s *QuotaSyncer) Start() {
+	for {
+		if err := s.limiter.Sync(context.Background()); err != nil {
+			s.log.Error("Error syncing quotas", zap.Error(err))
+		}
+		time.Sleep(s.interval)
+	}
+}
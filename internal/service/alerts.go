@@ -0,0 +1,193 @@
+package service
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	maxAlertRules    = 100                // Правил оповещения у одного пользователя
+	maxAlertEvents   = 1000               // Ограничивает размер выборки истории срабатываний
+	minAlertWindow   = time.Minute        // Окно короче интервала опроса не имеет смысла
+	maxAlertWindow   = 7 * 24 * time.Hour // Тики окна держатся в памяти
+	defaultCooldown  = 15 * time.Minute
+	maxAlertCooldown = 7 * 24 * time.Hour
+)
+
+var (
+	defaultHysteresis = decimal.NewFromInt(1)
+	maxHysteresis     = decimal.NewFromInt(100)
+)
+
+// validateAlertRule проверяет запрос и собирает из него правило без монеты и владельца
+func validateAlertRule(req *models.CreateAlertRuleRequest) (*models.AlertRule, error) {
+	rule := &models.AlertRule{Condition: req.Condition, Hysteresis: defaultHysteresis, Cooldown: models.Duration(defaultCooldown)}
+	if !req.Condition.Valid() {
+		return nil, fmt.Errorf("%w: unknown condition %q", ErrInvalidInput, req.Condition)
+	}
+
+	switch req.Condition {
+	case models.AlertPriceAbove, models.AlertPriceBelow, models.AlertChangeAbove:
+		if req.Threshold == nil || !req.Threshold.IsPositive() {
+			return nil, fmt.Errorf("%w: %s requires a positive threshold", ErrInvalidInput, req.Condition)
+		}
+		rule.Threshold = *req.Threshold
+	case models.AlertChangeBelow:
+		if req.Threshold == nil || !req.Threshold.IsNegative() {
+			return nil, fmt.Errorf("%w: %s requires a negative threshold", ErrInvalidInput, req.Condition)
+		}
+		rule.Threshold = *req.Threshold
+	default:
+		if req.Threshold != nil {
+			return nil, fmt.Errorf("%w: %s does not take a threshold", ErrInvalidInput, req.Condition)
+		}
+	}
+
+	if !req.Condition.Windowed() {
+		if req.Window != "" {
+			return nil, fmt.Errorf("%w: %s does not take a window", ErrInvalidInput, req.Condition)
+		}
+	} else {
+		if req.Window == "" {
+			return nil, fmt.Errorf("%w: %s requires a window", ErrInvalidInput, req.Condition)
+		}
+		window, err := parseInterval(req.Window)
+		if err != nil {
+			return nil, err
+		}
+		if window < minAlertWindow || window > maxAlertWindow {
+			return nil, fmt.Errorf("%w: window must be between %s and %s", ErrInvalidInput,
+				models.Duration(minAlertWindow), models.Duration(maxAlertWindow))
+		}
+		rule.Window = models.Duration(window)
+	}
+
+	if req.Hysteresis != nil {
+		if req.Hysteresis.IsNegative() || req.Hysteresis.GreaterThanOrEqual(maxHysteresis) {
+			return nil, fmt.Errorf("%w: hysteresis must be at least 0 and less than %s", ErrInvalidInput, maxHysteresis)
+		}
+		rule.Hysteresis = *req.Hysteresis
+	}
+	if req.Cooldown != "" {
+		cooldown, err := parseInterval(req.Cooldown)
+		if err != nil {
+			return nil, err
+		}
+		if cooldown > maxAlertCooldown {
+			return nil, fmt.Errorf("%w: cooldown must be at most %s", ErrInvalidInput, models.Duration(maxAlertCooldown))
+		}
+		rule.Cooldown = models.Duration(cooldown)
+	}
+	return rule, nil
+}
+
+// CreateAlertRule создает взведенное правило оповещения пользователя actor
+func (c *CoinService) CreateAlertRule(ctx context.Context, req *models.CreateAlertRuleRequest, actor *models.Actor) (*models.AlertRule, error) {
+	if !validateSymbol(req.Coin) {
+		return nil, fmt.Errorf("%w: invalid coin", ErrInvalidInput)
+	}
+	symbol := strings.ToUpper(req.Coin)
+	rule, err := validateAlertRule(req)
+	if err != nil {
+		return nil, err
+	}
+
+	coin, err := c.repo.GetCoin(ctx, symbol)
+	if err != nil {
+		if err = storageError(err, "coin "+symbol); errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: coin %s is not tracked", ErrInvalidInput, symbol)
+		}
+		return nil, err
+	}
+	// Правило по монете, цена которой не опрашивается, никогда бы не сработало
+	if coin.Status != models.CoinStatusActive {
+		watched, err := c.repo.IsCoinWatched(ctx, coin.ID)
+		if err != nil {
+			return nil, storageError(err, "coin "+symbol)
+		}
+		if !watched {
+			return nil, fmt.Errorf("%w: coin %s is %s and not in any watchlist", ErrInvalidInput, symbol, coin.Status)
+		}
+	}
+	rules, err := c.repo.ListAlertRules(ctx, actor.Name)
+	if err != nil {
+		return nil, storageError(err, "alert rules")
+	}
+	if len(rules) >= maxAlertRules {
+		return nil, fmt.Errorf("%w: at most %d alert rules per user", ErrConflict, maxAlertRules)
+	}
+
+	now := models.TimestampOf(time.Now())
+	rule.Owner = actor.Name
+	rule.CoinID = coin.ID
+	rule.Coin = coin.Symbol
+	rule.State = models.AlertArmed
+	rule.StateChangedAt = now
+	rule.CreatedAt = now
+	if err := c.repo.CreateAlertRule(ctx, rule); err != nil {
+		return nil, storageError(err, "alert rule")
+	}
+	return rule, nil
+}
+
+// ListAlertRules возвращает правила пользователя в порядке создания
+func (c *CoinService) ListAlertRules(ctx context.Context, actor *models.Actor) ([]*models.AlertRule, error) {
+	rules, err := c.repo.ListAlertRules(ctx, actor.Name)
+	if err != nil {
+		return nil, storageError(err, "alert rules")
+	}
+	if rules == nil {
+		rules = []*models.AlertRule{}
+	}
+	return rules, nil
+}
+
+// GetAlertRule возвращает правило пользователя; чужое правило неотличимо от несуществующего
+func (c *CoinService) GetAlertRule(ctx context.Context, id int64, actor *models.Actor) (*models.AlertRule, error) {
+	rule, err := c.repo.GetAlertRule(ctx, id)
+	if err != nil {
+		return nil, storageError(err, fmt.Sprintf("alert rule %d", id))
+	}
+	if rule.Owner != actor.Name {
+		return nil, fmt.Errorf("%w: alert rule %d", ErrNotFound, id)
+	}
+	return rule, nil
+}
+
+// DeleteAlertRule удаляет правило пользователя вместе с историей его срабатываний
+func (c *CoinService) DeleteAlertRule(ctx context.Context, id int64, actor *models.Actor) error {
+	if _, err := c.GetAlertRule(ctx, id, actor); err != nil {
+		return err
+	}
+	return storageError(c.repo.DeleteAlertRule(ctx, id), fmt.Sprintf("alert rule %d", id))
+}
+
+// GetAlertEvents возвращает срабатывания правил пользователя от новых к старым
+func (c *CoinService) GetAlertEvents(ctx context.Context, filter *models.AlertEventFilter, actor *models.Actor) ([]*models.AlertEvent, error) {
+	if filter.To > 0 && filter.To <= filter.From {
+		return nil, fmt.Errorf("%w: invalid time range", ErrInvalidInput)
+	}
+	if filter.Limit < 0 || filter.Limit > maxAlertEvents {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidInput, maxAlertEvents)
+	}
+	if filter.RuleID > 0 {
+		if _, err := c.GetAlertRule(ctx, filter.RuleID, actor); err != nil {
+			return nil, err
+		}
+	}
+	filter.Owner = actor.Name
+	events, err := c.repo.GetAlertEvents(ctx, filter)
+	if err != nil {
+		return nil, storageError(err, "alert events")
+	}
+	if events == nil {
+		events = []*models.AlertEvent{}
+	}
+	return events, nil
+}
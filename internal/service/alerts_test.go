@@ -0,0 +1,41 @@
+package service
+
+import (
+	"awesomeProject/internal/models"
+	storage "awesomeProject/internal/repository"
+	"errors"
+	"testing"
+)
+
+func TestCreateAlertRuleRequiresPolledCoin(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	coins := NewCoinService(repo, nil, "UTC")
+	admin := &models.Actor{Name: "admin"}
+	alice := &models.Actor{Name: "alice"}
+	req := &models.CreateAlertRuleRequest{Coin: "btc", Condition: models.AlertCrossAboveMA, Window: "1h"}
+
+	if err := repo.AddCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, admin); err != nil {
+		t.Fatalf("AddCoin: %v", err)
+	}
+	if _, err := coins.CreateAlertRule(t.Context(), req, alice); err != nil {
+		t.Fatalf("CreateAlertRule(active coin): %v", err)
+	}
+
+	// Удаленная из общего списка монета не опрашивается, пока ее нет в списках пользователей
+	if err := repo.RemoveCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, admin); err != nil {
+		t.Fatalf("RemoveCoin: %v", err)
+	}
+	if _, err := coins.CreateAlertRule(t.Context(), req, alice); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("CreateAlertRule(untracked coin) = %v, want ErrInvalidInput", err)
+	}
+	w := &models.Watchlist{Owner: "alice", Name: "majors"}
+	if err := repo.CreateWatchlist(t.Context(), w); err != nil {
+		t.Fatalf("CreateWatchlist: %v", err)
+	}
+	if err := repo.AddWatchlistCoin(t.Context(), w.ID, &models.TrackedCoin{Symbol: "BTC"}); err != nil {
+		t.Fatalf("AddWatchlistCoin: %v", err)
+	}
+	if _, err := coins.CreateAlertRule(t.Context(), req, alice); err != nil {
+		t.Errorf("CreateAlertRule(watched coin): %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyRepository хранит API-ключи
+type keyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64, revokedBy string, at models.Timestamp) error
+}
+
+const (
+	keyPrefix      = "cct_"             // Начало всех ключей, чтобы их было легко найти в утекших конфигурациях
+	keyBytes       = 32                 // Случайная часть ключа
+	keyShownLength = len(keyPrefix) + 8 // Начало ключа, которое хранится открыто
+	maxKeyName     = 100
+	// authCacheTTL - сколько проверенный ключ принимается без обращения к хранилищу.
+	// Отзыв сбрасывает кэш сразу на этом экземпляре, на остальных - не позже TTL.
+	authCacheTTL = 30 * time.Second
+)
+
+// reservedKeyNames - имена инициаторов, которые не принадлежат ключам: admin_token и запросы без ключа.
+// Имя ключа - владелец списков и правил, поэтому оно уникально и не совпадает с ними.
+var reservedKeyNames = []string{"admin", "anonymous"}
+
+type cachedPrincipal struct {
+	principal *models.Principal
+	expires   time.Time
+}
+
+// AuthService выпускает, проверяет и отзывает API-ключи
+type AuthService struct {
+	repo keyRepository
+
+	mu    sync.Mutex
+	cache map[string]cachedPrincipal // по хешу ключа
+	epoch int                        // растет при отзыве, чтобы не закэшировать ключ, прочитанный до отзыва
+}
+
+func NewAuthService(repo keyRepository) *AuthService {
+	return &AuthService{repo: repo, cache: make(map[string]cachedPrincipal)}
+}
+
+// CreateAPIKey выпускает ключ. Открытый ключ есть только в ответе,
+// в хранилище попадает его хеш.
+func (a *AuthService) CreateAPIKey(ctx context.Context, req *models.CreateAPIKeyRequest, actor *models.Actor) (*models.CreateAPIKeyResponse, error) {
+	if req.Name == "" || len(req.Name) > maxKeyName {
+		return nil, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidInput, maxKeyName)
+	}
+	for _, reserved := range reservedKeyNames {
+		if strings.EqualFold(req.Name, reserved) {
+			return nil, fmt.Errorf("%w: name %q is reserved", ErrInvalidInput, req.Name)
+		}
+	}
+	if len(req.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: scopes are required", ErrInvalidInput)
+	}
+	var scopes []models.Scope
+	seen := make(map[models.Scope]bool)
+	for _, scope := range req.Scopes {
+		if !scope.Valid() {
+			return nil, fmt.Errorf("%w: invalid scope %q", ErrInvalidInput, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	secret := make([]byte, keyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	plain := keyPrefix + hex.EncodeToString(secret)
+
+	key := models.APIKey{
+		Name:      req.Name,
+		Prefix:    plain[:keyShownLength],
+		Scopes:    scopes,
+		CreatedBy: actor.Name,
+		CreatedAt: models.TimestampOf(time.Now()),
+	}
+	// Имя уникально без учета регистра, включая отозванные ключи: им принадлежат списки
+	// и правила прежнего владельца. Уникальность проверяет индекс хранилища.
+	if err := a.repo.CreateAPIKey(ctx, &key, hashKey(plain)); err != nil {
+		return nil, storageError(err, fmt.Sprintf("api key %q", req.Name))
+	}
+	return &models.CreateAPIKeyResponse{APIKey: key, Key: plain}, nil
+}
+
+func (a *AuthService) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	keys, err := a.repo.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, storageError(err, "api keys")
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает ключ; отозванный ключ перестает приниматься сразу
+func (a *AuthService) RevokeAPIKey(ctx context.Context, id int64, actor *models.Actor) error {
+	if err := a.repo.RevokeAPIKey(ctx, id, actor.Name, models.TimestampOf(time.Now())); err != nil {
+		return storageError(err, fmt.Sprintf("api key %d", id))
+	}
+	a.mu.Lock()
+	clear(a.cache)
+	a.epoch++
+	a.mu.Unlock()
+	return nil
+}
+
+// Authenticate находит инициатора запроса по ключу. Неизвестный или отозванный
+// ключ - ErrUnauthorized.
+func (a *AuthService) Authenticate(ctx context.Context, plain string) (*models.Principal, error) {
+	hash := hashKey(plain)
+	now := time.Now()
+
+	a.mu.Lock()
+	cached, ok := a.cache[hash]
+	epoch := a.epoch
+	a.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.principal, nil
+	}
+
+	key, err := a.repo.GetAPIKeyByHash(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnauthorized
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to check api key: %w", ErrUnavailable, err)
+	}
+	principal := &models.Principal{Name: key.Name, KeyID: key.ID, Scopes: key.Scopes}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.epoch != epoch {
+		return principal, nil
+	}
+	// Просроченные записи убираются при добавлении, чтобы в кэше не копились ключи, которыми больше не пользуются
+	for h, entry := range a.cache {
+		if !now.Before(entry.expires) {
+			delete(a.cache, h)
+		}
+	}
+	a.cache[hash] = cachedPrincipal{principal: principal, expires: now.Add(authCacheTTL)}
+	return principal, nil
+}
+
+func hashKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
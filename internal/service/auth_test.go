@@ -0,0 +1,35 @@
+package service
+
+import (
+	"awesomeProject/internal/models"
+	storage "awesomeProject/internal/repository"
+	"errors"
+	"testing"
+)
+
+func TestCreateAPIKeyName(t *testing.T) {
+	auth := NewAuthService(storage.NewMemoryRepository())
+	actor := &models.Actor{Name: "admin"}
+	create := func(name string) error {
+		_, err := auth.CreateAPIKey(t.Context(), &models.CreateAPIKeyRequest{Name: name, Scopes: []models.Scope{models.ScopeRead}}, actor)
+		return err
+	}
+
+	if err := create("grafana"); err != nil {
+		t.Fatalf("CreateAPIKey(grafana): %v", err)
+	}
+	for _, tt := range []struct {
+		name string
+		want error
+	}{
+		{"grafana", ErrConflict},
+		{"Grafana", ErrConflict},
+		{"admin", ErrInvalidInput},
+		{"Anonymous", ErrInvalidInput},
+		{"", ErrInvalidInput},
+	} {
+		if err := create(tt.name); !errors.Is(err, tt.want) {
+			t.Errorf("CreateAPIKey(%q) = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
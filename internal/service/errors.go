@@ -0,0 +1,50 @@
+package service
+
+import (
+	"awesomeProject/api"
+	"awesomeProject/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Ошибки сервиса, по которым обработчики выбирают статус ответа.
+// Конкретная ошибка оборачивает одну из них: fmt.Errorf("%w: invalid coin", ErrInvalidInput).
+var (
+	ErrNotFound     = errors.New("not found")            // Монета или цена не найдена
+	ErrInvalidInput = errors.New("invalid input")        // Запрос не прошел проверку
+	ErrConflict     = errors.New("conflict")             // Запрос противоречит состоянию монеты
+	ErrUnavailable  = errors.New("upstream unavailable") // Провайдер цен или хранилище не отвечает
+	ErrUnauthorized = errors.New("unauthorized")         // API-ключ неизвестен или отозван
+	ErrForbidden    = errors.New("forbidden")            // Действие доступно только владельцу списка
+)
+
+// storageError относит ошибку хранилища к ошибкам сервиса: отсутствие записи - ErrNotFound
+// с описанием what, нарушение уникальности или ссылки между записями - ErrConflict,
+// истекший таймаут запроса - ErrUnavailable.
+// Остальные ошибки не меняются.
+func storageError(err error, what string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %s", ErrNotFound, what)
+	case errors.Is(err, models.ErrDuplicate):
+		return fmt.Errorf("%w: %s already exists", ErrConflict, what)
+	case errors.Is(err, models.ErrReferenced):
+		return fmt.Errorf("%w: %s was changed concurrently", ErrConflict, what)
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
+
+// providerError относит ошибку провайдера цен к ошибкам сервиса: неизвестная провайдеру
+// монета - ErrInvalidInput, остальные ошибки - ErrUnavailable
+func providerError(err error, symbol string) error {
+	if errors.Is(err, api.ErrCoinNotFound) {
+		return fmt.Errorf("%w: unknown coin %s", ErrInvalidInput, symbol)
+	}
+	return fmt.Errorf("%w: failed to get coin metadata: %w", ErrUnavailable, err)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"sync"
+	"time"
+)
+
+// latestCoinsTTL - сколько хранится список монет снимка. Список, измененный другим
+// экземпляром сервиса или напрямую в базе, появляется в снимке не позже этого срока.
+const latestCoinsTTL = 30 * time.Second
+
+// latestPrices - таблица последних цен монет в памяти. Цены обновляет PricePoller
+// после записи в хранилище, список монет перечитывается после изменений списка
+// наблюдения и по истечении latestCoinsTTL, поэтому снимок цен не обращается
+// к базе на каждый запрос.
+type latestPrices struct {
+	mu       sync.RWMutex
+	prices   map[int64]*models.CryptoPrice // По идентификатору монеты
+	coins    []*models.TrackedCoin         // nil - список нужно перечитать
+	loadedAt time.Time                     // Когда прочитан список coins
+	epoch    uint64                        // Число изменений списка наблюдения
+}
+
+func newLatestPrices() *latestPrices {
+	return &latestPrices{prices: make(map[int64]*models.CryptoPrice)}
+}
+
+// update запоминает цены, которые новее уже известных
+func (l *latestPrices) update(prices ...*models.CryptoPrice) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, price := range prices {
+		if current, ok := l.prices[price.CoinID]; ok && current.Timestamp > price.Timestamp {
+			continue
+		}
+		l.prices[price.CoinID] = price
+	}
+}
+
+// forget удаляет цену монеты, например после безвозвратного удаления
+func (l *latestPrices) forget(coinID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.prices, coinID)
+}
+
+// invalidateCoins сбрасывает список монет после изменения списка наблюдения
+func (l *latestPrices) invalidateCoins() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.coins = nil
+	l.epoch++
+}
+
+// UpdateLatestPrices обновляет таблицу последних цен ценами, успешно записанными в хранилище
+func (c *CoinService) UpdateLatestPrices(prices []*models.CryptoPrice) {
+	c.latest.update(prices...)
+}
+
+// LoadLatestPrices заполняет таблицу последних цен из хранилища при запуске сервиса:
+// монет общего списка и монет, которые опрашиваются только для списков пользователей.
+// Монеты без истории цен остаются без цены до первого опроса.
+func (c *CoinService) LoadLatestPrices(ctx context.Context) error {
+	coins, err := c.repo.GetAllCoins(ctx)
+	if err != nil {
+		return err
+	}
+	active, err := c.repo.GetActiveCoins(ctx)
+	if err != nil {
+		return err
+	}
+	now := models.TimestampOf(time.Now())
+	loaded := make(map[int64]bool, len(coins))
+	for _, coin := range append(coins, active...) {
+		if loaded[coin.ID] {
+			continue
+		}
+		loaded[coin.ID] = true
+		price, err := c.repo.GetPrice(ctx, &models.GetPriceRequest{Coin: coin.Symbol, Timestamp: now})
+		if err != nil {
+			continue
+		}
+		c.latest.update(price)
+	}
+	return nil
+}
+
+// GetLatestPrices возвращает отслеживаемые монеты с последней известной ценой и ее возрастом
+func (c *CoinService) GetLatestPrices(ctx context.Context) ([]*models.LatestPrice, error) {
+	c.latest.mu.RLock()
+	coins, loadedAt, epoch := c.latest.coins, c.latest.loadedAt, c.latest.epoch
+	c.latest.mu.RUnlock()
+	if coins == nil || time.Since(loadedAt) > latestCoinsTTL {
+		var err error
+		loadedAt = time.Now()
+		coins, err = c.repo.GetAllCoins(ctx)
+		if err != nil {
+			return nil, storageError(err, "coins")
+		}
+		if coins == nil {
+			coins = []*models.TrackedCoin{}
+		}
+		// Список, прочитанный до изменения списка наблюдения, не запоминается
+		c.latest.mu.Lock()
+		if c.latest.epoch == epoch {
+			c.latest.coins, c.latest.loadedAt = coins, loadedAt
+		}
+		c.latest.mu.Unlock()
+	}
+
+	return c.latest.entries(coins), nil
+}
+
+// entries возвращает монеты coins с последней известной ценой и ее возрастом
+func (l *latestPrices) entries(coins []*models.TrackedCoin) []*models.LatestPrice {
+	now := time.Now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	result := make([]*models.LatestPrice, 0, len(coins))
+	for _, coin := range coins {
+		entry := &models.LatestPrice{Coin: coin.Symbol, Name: coin.Name, Status: coin.Status}
+		if price, ok := l.prices[coin.ID]; ok {
+			entry.Price = &price.Price
+			entry.Timestamp = &price.Timestamp
+			entry.Age = now.Sub(price.Timestamp.Time()).Round(time.Millisecond).Seconds()
+		}
+		result = append(result, entry)
+	}
+	return result
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"awesomeProject/internal/models"
+	storage "awesomeProject/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestLatestPricesCoinsExpire(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	coins := NewCoinService(repo, nil, "UTC")
+	admin := &models.Actor{Name: "admin"}
+
+	if err := repo.AddCoin(t.Context(), &models.TrackedCoin{Symbol: "BTC"}, admin); err != nil {
+		t.Fatalf("AddCoin(BTC): %v", err)
+	}
+	if got, err := coins.GetLatestPrices(t.Context()); err != nil || len(got) != 1 {
+		t.Fatalf("GetLatestPrices() = %d coins, %v, want 1", len(got), err)
+	}
+
+	// Монету добавил другой экземпляр сервиса: до истечения срока снимок ее не видит
+	if err := repo.AddCoin(t.Context(), &models.TrackedCoin{Symbol: "ETH"}, admin); err != nil {
+		t.Fatalf("AddCoin(ETH): %v", err)
+	}
+	if got, err := coins.GetLatestPrices(t.Context()); err != nil || len(got) != 1 {
+		t.Fatalf("GetLatestPrices() = %d coins, %v, want cached 1", len(got), err)
+	}
+	coins.latest.loadedAt = time.Now().Add(-latestCoinsTTL - time.Second)
+	if got, err := coins.GetLatestPrices(t.Context()); err != nil || len(got) != 2 {
+		t.Errorf("GetLatestPrices() after TTL = %d coins, %v, want 2", len(got), err)
+	}
+}
@@ -2,66 +2,357 @@ package service
 
 import (
 	"awesomeProject/internal/models"
+	"context"
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type repository interface {
-	AddCoin(coin *models.TrackedCoin) error
-	RemoveCoin(coin *models.TrackedCoin) error
-	GetPrice(coin *models.GetPriceRequest) (*models.CryptoPrice, error)
-	GetAllCoins() ([]*models.TrackedCoin, error)
-	AddNewPrice(coin *models.CryptoPrice) error
+	AddCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error
+	RemoveCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) error
+	PurgeCoin(ctx context.Context, coin *models.TrackedCoin, actor *models.Actor) (int64, error)
+	GetPrice(ctx context.Context, coin *models.GetPriceRequest) (*models.CryptoPrice, error)
+	GetAllCoins(ctx context.Context) ([]*models.TrackedCoin, error)
+	GetActiveCoins(ctx context.Context) ([]*models.TrackedCoin, error)
+	GetCoin(ctx context.Context, symbol string) (*models.TrackedCoin, error)
+	SetCoinStatus(ctx context.Context, symbol string, status models.CoinStatus, actor *models.Actor) error
+	UpdateCoinMetadata(ctx context.Context, req *models.UpdateMetadataRequest, actor *models.Actor) (*models.TrackedCoin, error)
+	CorrectPrice(ctx context.Context, price *models.CryptoPrice, actor *models.Actor) (*models.CryptoPrice, error)
+	GetAuditLog(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error)
+	AddNewPrice(ctx context.Context, coin *models.CryptoPrice) error
+	AddNewPrices(ctx context.Context, prices []*models.CryptoPrice) ([]error, error)
+	GetPriceBuckets(ctx context.Context, q *models.BucketQuery) ([]*models.PriceBucket, error)
+	CreateWatchlist(ctx context.Context, w *models.Watchlist) error
+	GetWatchlist(ctx context.Context, id int64) (*models.Watchlist, error)
+	ListWatchlists(ctx context.Context, user string) ([]*models.Watchlist, error)
+	RenameWatchlist(ctx context.Context, id int64, name string) error
+	DeleteWatchlist(ctx context.Context, id int64) error
+	AddWatchlistCoin(ctx context.Context, id int64, coin *models.TrackedCoin) error
+	RemoveWatchlistCoin(ctx context.Context, id, coinID int64) error
+	ShareWatchlist(ctx context.Context, id int64, user string) error
+	UnshareWatchlist(ctx context.Context, id int64, user string) error
+	ListAPIKeys(ctx context.Context) ([]*models.APIKey, error)
+	IsCoinWatched(ctx context.Context, coinID int64) (bool, error)
+	CreateAlertRule(ctx context.Context, rule *models.AlertRule) error
+	GetAlertRule(ctx context.Context, id int64) (*models.AlertRule, error)
+	ListAlertRules(ctx context.Context, owner string) ([]*models.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, id int64) error
+	GetAlertEvents(ctx context.Context, filter *models.AlertEventFilter) ([]*models.AlertEvent, error)
 }
 
+// metadataProvider получает описание монеты у провайдера цен
+type metadataProvider interface {
+	GetCoinMetadata(symbol string) (*models.CoinMetadata, error)
+}
+
+// poolStatsProvider - хранилище, сообщающее состояние пула соединений
+type poolStatsProvider interface {
+	PoolStats() *models.PoolStats
+}
+
+const (
+	maxBuckets      = 10000 // Ограничивает размер ответа агрегации
+	maxAuditEntries = 1000  // Ограничивает размер выборки журнала аудита
+
+	maxInterval = 366 * 24 * time.Hour // Самый длинный интервал агрегации и окна правил
+)
+
 type CoinService struct {
-	repo repository
+	repo     repository
+	provider metadataProvider
+	timezone string
+	latest   *latestPrices
 }
 
-func NewCoinService(repo repository) *CoinService {
-	return &CoinService{repo: repo}
+// NewCoinService создает сервис монет; timezone - часовой пояс
+// календарных интервалов агрегации по умолчанию
+func NewCoinService(repo repository, provider metadataProvider, timezone string) *CoinService {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	return &CoinService{repo: repo, provider: provider, timezone: timezone, latest: newLatestPrices()}
 }
 
-func (c *CoinService) AddCoin(req *models.AddCoinRequest) error {
+func (c *CoinService) AddCoin(ctx context.Context, req *models.AddCoinRequest, actor *models.Actor) error {
+	if !validateSymbol(req.Coin) {
+		return fmt.Errorf("%w: invalid coin", ErrInvalidInput)
+	}
+	metadata, err := c.provider.GetCoinMetadata(strings.ToLower(req.Coin))
+	if err != nil {
+		return providerError(err, req.Coin)
+	}
+	coin := models.TrackedCoin{
+		Symbol:       strings.ToUpper(req.Coin),
+		CoinMetadata: *metadata,
+	}
+	defer c.latest.invalidateCoins()
+	return storageError(c.repo.AddCoin(ctx, &coin, actor), "coin "+coin.Symbol)
+}
+func (c *CoinService) RemoveCoin(ctx context.Context, req *models.AddCoinRequest, actor *models.Actor) error {
 	if !validateSymbol(req.Coin) {
-		return errors.New("invalid coin")
+		return fmt.Errorf("%w: invalid coin", ErrInvalidInput)
 	}
 	coin := models.TrackedCoin{
 		Symbol: strings.ToUpper(req.Coin),
 	}
-	return c.repo.AddCoin(&coin)
+	defer c.latest.invalidateCoins()
+	return storageError(c.repo.RemoveCoin(ctx, &coin, actor), "coin "+coin.Symbol)
 }
-func (c *CoinService) RemoveCoin(req *models.AddCoinRequest) error {
+
+// PurgeCoin безвозвратно удаляет убранную из списка наблюдения монету и ее историю цен
+func (c *CoinService) PurgeCoin(ctx context.Context, req *models.AddCoinRequest, actor *models.Actor) (int64, error) {
 	if !validateSymbol(req.Coin) {
-		return errors.New("invalid coin")
+		return 0, fmt.Errorf("%w: invalid coin", ErrInvalidInput)
 	}
 	coin := models.TrackedCoin{
 		Symbol: strings.ToUpper(req.Coin),
 	}
-	return c.repo.RemoveCoin(&coin)
+	existing, err := c.repo.GetCoin(ctx, coin.Symbol)
+	if err != nil {
+		return 0, storageError(err, "coin "+coin.Symbol)
+	}
+	if existing.Status != models.CoinStatusUntracked {
+		return 0, fmt.Errorf("%w: coin %s is still tracked, remove it first", ErrConflict, coin.Symbol)
+	}
+	// Монету из списков пользователей хранилище не удаляет: проверка идет в транзакции
+	// удаления, чтобы одновременное добавление монеты в список не привело к ошибке ссылки
+	deleted, err := c.repo.PurgeCoin(ctx, &coin, actor)
+	if errors.Is(err, models.ErrReferenced) {
+		return 0, fmt.Errorf("%w: coin %s is in users' watchlists", ErrConflict, coin.Symbol)
+	}
+	if err != nil {
+		return 0, storageError(err, "coin "+coin.Symbol)
+	}
+	c.latest.forget(existing.ID)
+	return deleted, nil
 }
-func (c *CoinService) GetPrice(coin *models.GetPriceRequest) (*models.CryptoPrice, error) {
+func (c *CoinService) GetPrice(ctx context.Context, coin *models.GetPriceRequest) (*models.CryptoPrice, error) {
 	if !validateSymbol(coin.Coin) {
-		return nil, errors.New("invalid coin")
+		return nil, fmt.Errorf("%w: invalid coin", ErrInvalidInput)
 	}
 	coin.Coin = strings.ToUpper(coin.Coin)
-	return c.repo.GetPrice(coin)
+	price, err := c.repo.GetPrice(ctx, coin)
+	if err != nil {
+		return nil, storageError(err, "no prices of "+coin.Coin)
+	}
+	return price, nil
+}
+func (c *CoinService) GetAllCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	coins, err := c.repo.GetAllCoins(ctx)
+	return coins, storageError(err, "coins")
+}
+
+// GetActiveCoins возвращает монеты, цены которых опрашивает PricePoller
+func (c *CoinService) GetActiveCoins(ctx context.Context) ([]*models.TrackedCoin, error) {
+	return c.repo.GetActiveCoins(ctx)
+}
+
+func (c *CoinService) GetCoin(ctx context.Context, symbol string) (*models.TrackedCoin, error) {
+	if !validateSymbol(symbol) {
+		return nil, fmt.Errorf("%w: invalid coin", ErrInvalidInput)
+	}
+	symbol = strings.ToUpper(symbol)
+	coin, err := c.repo.GetCoin(ctx, symbol)
+	if err != nil {
+		return nil, storageError(err, "coin "+symbol)
+	}
+	return coin, nil
+}
+
+// SetCoinStatus приостанавливает или возобновляет опрос монеты без удаления ее истории
+func (c *CoinService) SetCoinStatus(ctx context.Context, req *models.SetStatusRequest, actor *models.Actor) error {
+	if !validateSymbol(req.Coin) {
+		return fmt.Errorf("%w: invalid coin", ErrInvalidInput)
+	}
+	if !req.Status.Valid() {
+		return fmt.Errorf("%w: invalid status", ErrInvalidInput)
+	}
+	req.Coin = strings.ToUpper(req.Coin)
+	defer c.latest.invalidateCoins()
+	return storageError(c.repo.SetCoinStatus(ctx, req.Coin, req.Status, actor), "coin "+req.Coin)
+}
+
+// UpdateCoinMetadata вручную меняет метаданные монеты
+func (c *CoinService) UpdateCoinMetadata(ctx context.Context, req *models.UpdateMetadataRequest, actor *models.Actor) (*models.TrackedCoin, error) {
+	if !validateSymbol(req.Coin) {
+		return nil, fmt.Errorf("%w: invalid coin", ErrInvalidInput)
+	}
+	if req.Decimals != nil && (*req.Decimals < 0 || *req.Decimals > 36) {
+		return nil, fmt.Errorf("%w: invalid decimals", ErrInvalidInput)
+	}
+	req.Coin = strings.ToUpper(req.Coin)
+	defer c.latest.invalidateCoins()
+	coin, err := c.repo.UpdateCoinMetadata(ctx, req, actor)
+	if err != nil {
+		return nil, storageError(err, "coin "+req.Coin)
+	}
+	return coin, nil
+}
+
+// CorrectPrice вручную исправляет цену монеты в конкретный момент
+func (c *CoinService) CorrectPrice(ctx context.Context, req *models.CorrectPriceRequest, actor *models.Actor) (*models.CryptoPrice, error) {
+	price := &models.CryptoPrice{
+		Symbol:    strings.ToUpper(req.Coin),
+		Price:     req.Price,
+		Timestamp: req.Timestamp,
+	}
+	if err := validatePrice(price); err != nil {
+		return nil, err
+	}
+	corrected, err := c.repo.CorrectPrice(ctx, price, actor)
+	if err != nil {
+		return nil, storageError(err, "coin "+price.Symbol)
+	}
+	// Исправление последней цены сразу видно в снимке последних цен
+	c.latest.update(corrected)
+	return corrected, nil
+}
+
+// GetAuditLog возвращает журнал изменений с учетом фильтра
+func (c *CoinService) GetAuditLog(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	if filter.Coin != "" {
+		if !validateSymbol(filter.Coin) {
+			return nil, fmt.Errorf("%w: invalid coin", ErrInvalidInput)
+		}
+		filter.Coin = strings.ToUpper(filter.Coin)
+	}
+	if filter.To > 0 && filter.To <= filter.From {
+		return nil, fmt.Errorf("%w: invalid time range", ErrInvalidInput)
+	}
+	if filter.Limit < 0 || filter.Limit > maxAuditEntries {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidInput, maxAuditEntries)
+	}
+	entries, err := c.repo.GetAuditLog(ctx, filter)
+	return entries, storageError(err, "audit entries")
+}
+
+// PoolStats возвращает состояние пула соединений хранилища
+func (c *CoinService) PoolStats() (*models.PoolStats, error) {
+	provider, ok := c.repo.(poolStatsProvider)
+	if !ok {
+		return nil, fmt.Errorf("%w: storage has no connection pool", ErrNotFound)
+	}
+	return provider.PoolStats(), nil
 }
-func (c *CoinService) GetAllCoins() ([]*models.TrackedCoin, error) {
-	return c.repo.GetAllCoins()
+
+func (c *CoinService) AddNewPrice(ctx context.Context, coin *models.CryptoPrice) error {
+	if err := validatePrice(coin); err != nil {
+		return err
+	}
+	return c.repo.AddNewPrice(ctx, coin)
 }
-func (c *CoinService) AddNewPrice(coin *models.CryptoPrice) error {
+
+// AddNewPrices записывает пакет цен. Некорректные строки не попадают в запрос
+// к хранилищу; ошибки возвращаются по строкам в порядке входного среза.
+func (c *CoinService) AddNewPrices(ctx context.Context, prices []*models.CryptoPrice) ([]error, error) {
+	errs := make([]error, len(prices))
+	valid := make([]*models.CryptoPrice, 0, len(prices))
+	index := make([]int, 0, len(prices))
+	for i, price := range prices {
+		if err := validatePrice(price); err != nil {
+			errs[i] = err
+			continue
+		}
+		valid = append(valid, price)
+		index = append(index, i)
+	}
+	if len(valid) == 0 {
+		return errs, nil
+	}
+
+	repoErrs, err := c.repo.AddNewPrices(ctx, valid)
+	if err != nil {
+		return nil, err
+	}
+	for i, err := range repoErrs {
+		errs[index[i]] = err
+	}
+	return errs, nil
+}
+
+func validatePrice(coin *models.CryptoPrice) error {
 	if !validateSymbol(coin.Symbol) {
-		return errors.New("invalid symbol")
+		return fmt.Errorf("%w: invalid symbol", ErrInvalidInput)
 	}
-	if coin.Price <= 0 {
-		return errors.New("invalid price")
+	if !coin.Price.IsPositive() {
+		return fmt.Errorf("%w: invalid price", ErrInvalidInput)
 	}
 	if coin.Timestamp == 0 {
-		return errors.New("invalid timestamp")
+		return fmt.Errorf("%w: invalid timestamp", ErrInvalidInput)
+	}
+	return nil
+}
+
+// GetPriceBuckets возвращает OHLC-агрегаты цены монеты за период [from, to)
+func (c *CoinService) GetPriceBuckets(ctx context.Context, req *models.GetBucketsRequest) ([]*models.PriceBucket, error) {
+	if !validateSymbol(req.Coin) {
+		return nil, fmt.Errorf("%w: invalid coin", ErrInvalidInput)
+	}
+	from, to := req.From, req.To
+	if from < 0 || to <= from {
+		return nil, fmt.Errorf("%w: invalid time range", ErrInvalidInput)
+	}
+	interval, err := parseInterval(req.Interval)
+	if err != nil {
+		return nil, err
+	}
+	if int64(to-from)/interval.Milliseconds() > maxBuckets {
+		return nil, fmt.Errorf("%w: too many buckets, maximum is %d", ErrInvalidInput, maxBuckets)
+	}
+	if req.Timezone == "" {
+		req.Timezone = c.timezone
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		return nil, fmt.Errorf("%w: invalid timezone %q", ErrInvalidInput, req.Timezone)
+	}
+	req.Coin = strings.ToUpper(req.Coin)
+
+	return c.repo.GetPriceBuckets(ctx, &models.BucketQuery{
+		Symbol:   req.Coin,
+		From:     from,
+		To:       to,
+		Interval: interval,
+		Timezone: req.Timezone,
+	})
+}
+
+// parseInterval разбирает длину интервала вида 30s, 15m, 1h, 1d, 1w
+func parseInterval(interval string) (time.Duration, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("%w: invalid interval", ErrInvalidInput)
+	}
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%w: invalid interval", ErrInvalidInput)
+	}
+	var unit time.Duration
+	switch interval[len(interval)-1] {
+	case 's':
+		unit = time.Second
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("%w: invalid interval", ErrInvalidInput)
+	}
+	// Проверка до умножения: переполненная длительность может стать нулевой или отрицательной
+	if time.Duration(n) > maxInterval/unit {
+		return 0, fmt.Errorf("%w: interval must be at most %s", ErrInvalidInput, models.Duration(maxInterval))
+	}
+	d := time.Duration(n) * unit
+	if d < time.Second {
+		return 0, fmt.Errorf("%w: invalid interval", ErrInvalidInput)
 	}
-	return c.repo.AddNewPrice(coin)
+	return d, nil
 }
 
 func validateSymbol(symbol string) bool {
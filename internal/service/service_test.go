@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		interval string
+		want     time.Duration // 0 - интервал отклоняется
+	}{
+		{"30s", 30 * time.Second},
+		{"15m", 15 * time.Minute},
+		{"1h", time.Hour},
+		{"1d", 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"366d", maxInterval},
+		{"367d", 0},
+		{"53w", 0},
+		{"36028797018963968s", 0}, // 2^55 с переполняет time.Duration до нуля
+		{"9223372036854775807s", 0},
+		{"0s", 0},
+		{"-1h", 0},
+		{"1y", 0},
+		{"h", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseInterval(tt.interval)
+		if tt.want == 0 {
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("parseInterval(%q) = %v, %v, want ErrInvalidInput", tt.interval, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseInterval(%q) = %v, %v, want %v", tt.interval, got, err, tt.want)
+		}
+	}
+}
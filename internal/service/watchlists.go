@@ -0,0 +1,255 @@
+package service
+
+import (
+	"awesomeProject/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxWatchlistName ограничивает длину названия списка и имени пользователя, которому он открыт
+const maxWatchlistName = 100
+
+// validateWatchlistName проверяет название списка или имя пользователя what и возвращает его без пробелов по краям
+func validateWatchlistName(value, what string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || utf8.RuneCountInString(value) > maxWatchlistName {
+		return "", fmt.Errorf("%w: %s must be 1 to %d characters", ErrInvalidInput, what, maxWatchlistName)
+	}
+	return value, nil
+}
+
+// watchlistFor возвращает список id, если пользователь user может его читать, а при modify - изменять.
+// Список, который пользователю не открыт, неотличим от несуществующего.
+func (c *CoinService) watchlistFor(ctx context.Context, id int64, user string, modify bool) (*models.Watchlist, error) {
+	w, err := c.repo.GetWatchlist(ctx, id)
+	if err != nil {
+		return nil, storageError(err, fmt.Sprintf("watchlist %d", id))
+	}
+	if w.Owner == user {
+		return w, nil
+	}
+	if !slices.Contains(w.SharedWith, user) {
+		return nil, fmt.Errorf("%w: watchlist %d", ErrNotFound, id)
+	}
+	if modify {
+		return nil, fmt.Errorf("%w: only the owner can change watchlist %d", ErrForbidden, id)
+	}
+	return w, nil
+}
+
+// watchlistResponse дополняет монеты списка последними известными ценами
+func (c *CoinService) watchlistResponse(w *models.Watchlist) *models.WatchlistResponse {
+	return &models.WatchlistResponse{Watchlist: *w, Coins: c.latest.entries(w.Coins)}
+}
+
+// reloadWatchlist перечитывает список после изменения
+func (c *CoinService) reloadWatchlist(ctx context.Context, id int64) (*models.WatchlistResponse, error) {
+	w, err := c.repo.GetWatchlist(ctx, id)
+	if err != nil {
+		return nil, storageError(err, fmt.Sprintf("watchlist %d", id))
+	}
+	return c.watchlistResponse(w), nil
+}
+
+// checkWatchlistName возвращает ErrConflict, если у владельца уже есть другой список с названием name.
+// Это лишь быстрая проверка: одновременные запросы отсекает ограничение уникальности хранилища.
+func (c *CoinService) checkWatchlistName(ctx context.Context, owner, name string, id int64) error {
+	lists, err := c.repo.ListWatchlists(ctx, owner)
+	if err != nil {
+		return storageError(err, "watchlists")
+	}
+	for _, w := range lists {
+		if w.Owner == owner && w.Name == name && w.ID != id {
+			return fmt.Errorf("%w: watchlist %q already exists", ErrConflict, name)
+		}
+	}
+	return nil
+}
+
+// CreateWatchlist создает пустой список наблюдения пользователя actor
+func (c *CoinService) CreateWatchlist(ctx context.Context, req *models.WatchlistRequest, actor *models.Actor) (*models.WatchlistResponse, error) {
+	name, err := validateWatchlistName(req.Name, "name")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkWatchlistName(ctx, actor.Name, name, 0); err != nil {
+		return nil, err
+	}
+	w := &models.Watchlist{
+		Owner:      actor.Name,
+		Name:       name,
+		SharedWith: []string{},
+		CreatedAt:  models.TimestampOf(time.Now()),
+	}
+	if err := c.repo.CreateWatchlist(ctx, w); err != nil {
+		return nil, storageError(err, fmt.Sprintf("watchlist %q", name))
+	}
+	return c.watchlistResponse(w), nil
+}
+
+// ListWatchlists возвращает списки пользователя и открытые ему списки
+func (c *CoinService) ListWatchlists(ctx context.Context, actor *models.Actor) ([]*models.WatchlistResponse, error) {
+	lists, err := c.repo.ListWatchlists(ctx, actor.Name)
+	if err != nil {
+		return nil, storageError(err, "watchlists")
+	}
+	result := make([]*models.WatchlistResponse, 0, len(lists))
+	for _, w := range lists {
+		result = append(result, c.watchlistResponse(w))
+	}
+	return result, nil
+}
+
+// GetWatchlist возвращает список владельцу или пользователю, которому он открыт
+func (c *CoinService) GetWatchlist(ctx context.Context, id int64, actor *models.Actor) (*models.WatchlistResponse, error) {
+	w, err := c.watchlistFor(ctx, id, actor.Name, false)
+	if err != nil {
+		return nil, err
+	}
+	return c.watchlistResponse(w), nil
+}
+
+// RenameWatchlist переименовывает список; переименовать может только владелец
+func (c *CoinService) RenameWatchlist(ctx context.Context, id int64, req *models.WatchlistRequest, actor *models.Actor) (*models.WatchlistResponse, error) {
+	name, err := validateWatchlistName(req.Name, "name")
+	if err != nil {
+		return nil, err
+	}
+	w, err := c.watchlistFor(ctx, id, actor.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	if w.Name == name {
+		return c.watchlistResponse(w), nil
+	}
+	if err := c.checkWatchlistName(ctx, w.Owner, name, id); err != nil {
+		return nil, err
+	}
+	if err := c.repo.RenameWatchlist(ctx, id, name); errors.Is(err, models.ErrDuplicate) {
+		return nil, storageError(err, fmt.Sprintf("watchlist %q", name))
+	} else if err != nil {
+		return nil, storageError(err, fmt.Sprintf("watchlist %d", id))
+	}
+	return c.reloadWatchlist(ctx, id)
+}
+
+// DeleteWatchlist удаляет список. Его монеты остаются в справочнике и
+// перестают опрашиваться, если их нет в общем списке и в других списках.
+func (c *CoinService) DeleteWatchlist(ctx context.Context, id int64, actor *models.Actor) error {
+	if _, err := c.watchlistFor(ctx, id, actor.Name, true); err != nil {
+		return err
+	}
+	return storageError(c.repo.DeleteWatchlist(ctx, id), fmt.Sprintf("watchlist %d", id))
+}
+
+// AddWatchlistCoin добавляет монету в список. Монета, которой еще нет в справочнике,
+// добавляется в него по описанию провайдера цен и опрашивается со следующего опроса.
+func (c *CoinService) AddWatchlistCoin(ctx context.Context, id int64, symbol string, actor *models.Actor) (*models.WatchlistResponse, error) {
+	if !validateSymbol(symbol) {
+		return nil, fmt.Errorf("%w: invalid coin", ErrInvalidInput)
+	}
+	symbol = strings.ToUpper(symbol)
+	if _, err := c.watchlistFor(ctx, id, actor.Name, true); err != nil {
+		return nil, err
+	}
+
+	coin, err := c.repo.GetCoin(ctx, symbol)
+	if err = storageError(err, "coin "+symbol); errors.Is(err, ErrNotFound) {
+		metadata, err := c.provider.GetCoinMetadata(strings.ToLower(symbol))
+		if err != nil {
+			return nil, providerError(err, symbol)
+		}
+		coin = &models.TrackedCoin{Symbol: symbol, CoinMetadata: *metadata}
+	} else if err != nil {
+		return nil, err
+	}
+	if err := c.repo.AddWatchlistCoin(ctx, id, coin); err != nil {
+		return nil, storageError(err, fmt.Sprintf("watchlist %d", id))
+	}
+	return c.reloadWatchlist(ctx, id)
+}
+
+// RemoveWatchlistCoin убирает монету из списка; история ее цен сохраняется
+func (c *CoinService) RemoveWatchlistCoin(ctx context.Context, id int64, symbol string, actor *models.Actor) (*models.WatchlistResponse, error) {
+	if !validateSymbol(symbol) {
+		return nil, fmt.Errorf("%w: invalid coin", ErrInvalidInput)
+	}
+	symbol = strings.ToUpper(symbol)
+	w, err := c.watchlistFor(ctx, id, actor.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(w.Coins, func(coin *models.TrackedCoin) bool { return coin.Symbol == symbol })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: coin %s is not in watchlist %d", ErrNotFound, symbol, id)
+	}
+	if err := c.repo.RemoveWatchlistCoin(ctx, id, w.Coins[i].ID); err != nil {
+		return nil, storageError(err, fmt.Sprintf("coin %s in watchlist %d", symbol, id))
+	}
+	return c.reloadWatchlist(ctx, id)
+}
+
+// ShareWatchlist открывает список пользователю user для чтения
+func (c *CoinService) ShareWatchlist(ctx context.Context, id int64, user string, actor *models.Actor) (*models.WatchlistResponse, error) {
+	user, err := validateWatchlistName(user, "user")
+	if err != nil {
+		return nil, err
+	}
+	w, err := c.watchlistFor(ctx, id, actor.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	if user == w.Owner {
+		return nil, fmt.Errorf("%w: watchlist %d is owned by %s", ErrInvalidInput, id, user)
+	}
+	if err := c.checkUser(ctx, user); err != nil {
+		return nil, err
+	}
+	if err := c.repo.ShareWatchlist(ctx, id, user); err != nil {
+		return nil, storageError(err, fmt.Sprintf("watchlist %d", id))
+	}
+	return c.reloadWatchlist(ctx, id)
+}
+
+// checkUser возвращает ErrInvalidInput, если нет действующего API-ключа с именем user
+func (c *CoinService) checkUser(ctx context.Context, user string) error {
+	keys, err := c.repo.ListAPIKeys(ctx)
+	if err != nil {
+		return storageError(err, "api keys")
+	}
+	for _, key := range keys {
+		if key.Name == user && key.RevokedAt == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown user %s", ErrInvalidInput, user)
+}
+
+// UnshareWatchlist закрывает список пользователю user. Закрыть список может
+// владелец, а пользователь, которому список открыт, - отказаться от него сам.
+func (c *CoinService) UnshareWatchlist(ctx context.Context, id int64, user string, actor *models.Actor) (*models.WatchlistResponse, error) {
+	user, err := validateWatchlistName(user, "user")
+	if err != nil {
+		return nil, err
+	}
+	w, err := c.watchlistFor(ctx, id, actor.Name, user != actor.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(w.SharedWith, user) {
+		return nil, fmt.Errorf("%w: watchlist %d is not shared with %s", ErrNotFound, id, user)
+	}
+	if err := c.repo.UnshareWatchlist(ctx, id, user); err != nil {
+		return nil, storageError(err, fmt.Sprintf("watchlist %d", id))
+	}
+	if user == actor.Name {
+		w.SharedWith = slices.DeleteFunc(w.SharedWith, func(name string) bool { return name == user })
+		return c.watchlistResponse(w), nil
+	}
+	return c.reloadWatchlist(ctx, id)
+}
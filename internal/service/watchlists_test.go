@@ -0,0 +1,73 @@
+package service
+
+import (
+	"awesomeProject/internal/models"
+	storage "awesomeProject/internal/repository"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestShareWatchlistWithKnownUser(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	coins := NewCoinService(repo, nil, "UTC")
+	auth := NewAuthService(repo)
+	alice := &models.Actor{Name: "alice"}
+
+	w, err := coins.CreateWatchlist(t.Context(), &models.WatchlistRequest{Name: "majors"}, alice)
+	if err != nil {
+		t.Fatalf("CreateWatchlist: %v", err)
+	}
+	if _, err := coins.ShareWatchlist(t.Context(), w.ID, "bob", alice); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ShareWatchlist(unknown user) = %v, want ErrInvalidInput", err)
+	}
+
+	key, err := auth.CreateAPIKey(t.Context(), &models.CreateAPIKeyRequest{Name: "bob", Scopes: []models.Scope{models.ScopeRead}}, &models.Actor{Name: "admin"})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	shared, err := coins.ShareWatchlist(t.Context(), w.ID, "bob", alice)
+	if err != nil {
+		t.Fatalf("ShareWatchlist(bob): %v", err)
+	}
+	if !slices.Equal(shared.SharedWith, []string{"bob"}) {
+		t.Errorf("shared with = %v, want [bob]", shared.SharedWith)
+	}
+
+	if err := auth.RevokeAPIKey(t.Context(), key.ID, &models.Actor{Name: "admin"}); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if _, err := coins.ShareWatchlist(t.Context(), w.ID, "bob", alice); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("ShareWatchlist(revoked user) = %v, want ErrInvalidInput", err)
+	}
+}
+
+// staleWatchlists не видит существующих списков, как проверка названия,
+// опередившая одновременное создание списка
+type staleWatchlists struct {
+	*storage.MemoryRepository
+}
+
+func (staleWatchlists) ListWatchlists(ctx context.Context, user string) ([]*models.Watchlist, error) {
+	return nil, nil
+}
+
+func TestWatchlistNameConflict(t *testing.T) {
+	coins := NewCoinService(staleWatchlists{storage.NewMemoryRepository()}, nil, "UTC")
+	alice := &models.Actor{Name: "alice"}
+
+	if _, err := coins.CreateWatchlist(t.Context(), &models.WatchlistRequest{Name: "majors"}, alice); err != nil {
+		t.Fatalf("CreateWatchlist: %v", err)
+	}
+	if _, err := coins.CreateWatchlist(t.Context(), &models.WatchlistRequest{Name: "majors"}, alice); !errors.Is(err, ErrConflict) {
+		t.Errorf("CreateWatchlist(duplicate) = %v, want ErrConflict", err)
+	}
+	defi, err := coins.CreateWatchlist(t.Context(), &models.WatchlistRequest{Name: "defi"}, alice)
+	if err != nil {
+		t.Fatalf("CreateWatchlist: %v", err)
+	}
+	if _, err := coins.RenameWatchlist(t.Context(), defi.ID, &models.WatchlistRequest{Name: "majors"}, alice); !errors.Is(err, ErrConflict) {
+		t.Errorf("RenameWatchlist(duplicate) = %v, want ErrConflict", err)
+	}
+}